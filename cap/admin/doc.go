@@ -0,0 +1,82 @@
+/*
+Package admin offers an http.Handler that exposes the operational surface of a
+running supervision tree built with the cap package.
+
+The handler serves the live supervision tree, the recent events of the tree,
+the restart count and health of every node, and it allows an authorized
+operator to restart or terminate a node, or to spawn and cancel nodes on a
+DynSupervisor.
+
+Recorder
+
+The events of the supervision tree are kept by a Recorder, which must be
+registered as a notifier of the root supervisor before it starts:
+
+	rec := admin.NewRecorder(admin.WithBufferSize(200))
+
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(apiSubtree, dbSubtree),
+		cap.WithNotifier(rec.HandleEvent),
+	).Start(ctx)
+
+Handler
+
+Once the supervisor started, the handler may be mounted on any path of an
+http.ServeMux:
+
+	mux.Handle(
+		"/admin/",
+		http.StripPrefix("/admin", admin.NewHandler(sup, rec, admin.WithAuthorizer(isOperator))),
+	)
+
+The handler serves the following endpoints, all of them use JSON:
+
+	GET  /tree            the supervision tree, with the status of every node
+	GET  /events?limit=N  the most recent events, the oldest first
+	GET  /health          the health of the tree (503 when unhealthy)
+	GET  /dyn             the registered DynSupervisors and their nodes
+	POST /restart         restarts a node, e.g. {"node": "root/api/listener"}
+	POST /terminate       terminates a node, e.g. {"node": "root/api/listener"}
+	POST /spawn           spawns a node on a DynSupervisor, e.g.
+	                      {"supervisor": "jobs", "factory": "job", "name": "job-1"}
+	POST /cancel          cancels a spawned node, e.g.
+	                      {"supervisor": "jobs", "name": "job-1"}
+
+Authorization
+
+Read endpoints are always served; mount the handler behind an authentication
+middleware if the tree must not be public. The endpoints that change the tree
+(POST) are only served when the Authorizer given with WithAuthorizer accepts
+the request; without an Authorizer, they are always rejected.
+
+The restart and terminate endpoints call the RestartNode and TerminateNode
+methods of the supervisor (or the functions given with WithRestartNode and
+WithTerminateNode); the spawn and cancel endpoints operate on the
+DynSupervisors given with WithDynSupervisor.
+
+Unix Socket
+
+The same operational surface (except for the DynSupervisor operations) may be
+served on a local unix socket, without exposing an HTTP port; WithUnixSocket
+is a cap.Opt that makes a root supervisor listen on the socket for as long as
+it runs:
+
+	cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(apiSubtree, dbSubtree),
+		cap.WithNotifier(rec.HandleEvent),
+		admin.WithUnixSocket("/var/run/myapp.sock", rec),
+	)
+
+The protocol uses line-delimited JSON: a client writes a single Request line,
+and the server writes Response lines until it closes the connection (check the
+Commands of the protocol). The socket file is only accessible by the owner of
+the process; the Authorizer is not used on the socket, every process of the
+owner may restart or terminate nodes through it. The capctl command
+(cmd/capctl) is a client of this protocol:
+
+	capctl -socket /var/run/myapp.sock tree
+	capctl -socket /var/run/myapp.sock events -follow
+*/
+package admin
@@ -0,0 +1,409 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/capatazlib/go-capataz/cap"
+)
+
+// Authorizer indicates if the given request may change the supervision tree
+type Authorizer func(*http.Request) bool
+
+// NodeFn executes an operation over the node with the given runtime name (e.g.
+// a restart)
+type NodeFn func(runtimeName string) error
+
+// NodeFactory creates a node with the given name and parameters; it is used to
+// spawn nodes on a DynSupervisor from a request
+type NodeFactory func(name string, params map[string]string) (cap.Node, error)
+
+// settings contains the operations a Handler (or a SocketServer) is allowed to
+// execute over a supervision tree
+type settings struct {
+	authorize     Authorizer
+	restartNode   NodeFn
+	terminateNode NodeFn
+	dynSups       map[string]*dynSupervisor
+}
+
+// Opt is used to configure a Handler or a SocketServer
+type Opt func(*settings)
+
+// buildSettings returns the settings given by the options; nodes are restarted
+// and terminated with the methods of the given supervisor by default
+func buildSettings(sup cap.Supervisor, opts []Opt) settings {
+	s := settings{
+		restartNode:   sup.RestartNode,
+		terminateNode: sup.TerminateNode,
+		dynSups:       make(map[string]*dynSupervisor),
+	}
+	for _, optFn := range opts {
+		optFn(&s)
+	}
+	return s
+}
+
+// WithAuthorizer specifies the Authorizer of the requests that change the
+// supervision tree; without it, these requests are always rejected.
+func WithAuthorizer(authorize Authorizer) Opt {
+	return func(s *settings) {
+		s.authorize = authorize
+	}
+}
+
+// WithRestartNode specifies the function that restarts a node of the
+// supervision tree; by default, the RestartNode method of the cap.Supervisor is
+// used. When the given function is nil, the restart endpoint is not available.
+func WithRestartNode(restartNode NodeFn) Opt {
+	return func(s *settings) {
+		s.restartNode = restartNode
+	}
+}
+
+// WithTerminateNode specifies the function that terminates a node of the
+// supervision tree; by default, the TerminateNode method of the cap.Supervisor
+// is used. When the given function is nil, the terminate endpoint is not
+// available.
+func WithTerminateNode(terminateNode NodeFn) Opt {
+	return func(s *settings) {
+		s.terminateNode = terminateNode
+	}
+}
+
+// WithDynSupervisor registers a DynSupervisor on which nodes may be spawned and
+// cancelled, the given factories (indexed by name) build the spawned nodes. A
+// request may only cancel the nodes that were spawned by the same Handler.
+func WithDynSupervisor(dyn *cap.DynSupervisor, factories map[string]NodeFactory) Opt {
+	return func(s *settings) {
+		s.dynSups[dyn.GetName()] = &dynSupervisor{
+			dyn:       dyn,
+			factories: factories,
+			cancels:   make(map[string]func() error),
+		}
+	}
+}
+
+// dynSupervisor is a DynSupervisor registered on a Handler, with the nodes
+// that were spawned through the Handler
+type dynSupervisor struct {
+	dyn       *cap.DynSupervisor
+	factories map[string]NodeFactory
+
+	mux     sync.Mutex
+	cancels map[string]func() error
+}
+
+// Handler is an http.Handler that serves the operational surface of a running
+// supervision tree. Check the package documentation for the list of endpoints.
+type Handler struct {
+	settings
+
+	sup cap.Supervisor
+	rec *Recorder
+	mux *http.ServeMux
+}
+
+// NewHandler creates a Handler for the given supervisor; the given Recorder
+// must be registered as a notifier of the supervisor.
+func NewHandler(sup cap.Supervisor, rec *Recorder, opts ...Opt) *Handler {
+	h := &Handler{
+		settings: buildSettings(sup, opts),
+		sup:      sup,
+		rec:      rec,
+		mux:      http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("/tree", h.get(h.serveTree))
+	h.mux.HandleFunc("/events", h.get(h.serveEvents))
+	h.mux.HandleFunc("/health", h.get(h.serveHealth))
+	h.mux.HandleFunc("/dyn", h.get(h.serveDyn))
+	h.mux.HandleFunc("/restart", h.post(h.serveRestart))
+	h.mux.HandleFunc("/terminate", h.post(h.serveTerminate))
+	h.mux.HandleFunc("/spawn", h.post(h.serveSpawn))
+	h.mux.HandleFunc("/cancel", h.post(h.serveCancel))
+	return h
+}
+
+// ServeHTTP dispatches the request to the endpoint that serves it
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// httpError is an error with the HTTP status of the response
+type httpError struct {
+	status int
+	err    error
+}
+
+// Error returns the message of the error
+func (err *httpError) Error() string {
+	return err.err.Error()
+}
+
+// newHTTPError creates an error with the given HTTP status
+func newHTTPError(status int, format string, args ...interface{}) error {
+	return &httpError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+// endpointFn serves a request, it returns the HTTP status and the body of the
+// response
+type endpointFn func(*http.Request) (int, interface{}, error)
+
+// writeJSON writes the given value as the JSON body of the response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// serve writes the response of the given endpoint, errors are written as a
+// JSON object with an error field
+func serve(w http.ResponseWriter, r *http.Request, endpoint endpointFn) {
+	status, body, err := endpoint(r)
+	if err != nil {
+		status = http.StatusInternalServerError
+		var hErr *httpError
+		if errors.As(err, &hErr) {
+			status = hErr.status
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, status, body)
+}
+
+// get returns a handler that only serves the GET requests of the endpoint
+func (h *Handler) get(endpoint endpointFn) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		serve(w, r, endpoint)
+	}
+}
+
+// post returns a handler that only serves the authorized POST requests of the
+// endpoint
+func (h *Handler) post(endpoint endpointFn) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		if h.authorize == nil || !h.authorize(r) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "operation not authorized"})
+			return
+		}
+		serve(w, r, endpoint)
+	}
+}
+
+// decodeBody decodes the JSON body of the request on the given value
+func decodeBody(r *http.Request, body interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		return newHTTPError(http.StatusBadRequest, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+// serveTree serves the supervision tree
+func (h *Handler) serveTree(*http.Request) (int, interface{}, error) {
+	return http.StatusOK, newNode(h.sup.Describe(), h.rec, h.rec.GetHealthReport()), nil
+}
+
+// serveEvents serves the most recent events, the limit query parameter
+// specifies the maximum number of events (zero means all of them)
+func (h *Handler) serveEvents(r *http.Request) (int, interface{}, error) {
+	var limit int
+	if input := r.URL.Query().Get("limit"); input != "" {
+		var err error
+		limit, err = strconv.Atoi(input)
+		if err != nil || limit < 0 {
+			return 0, nil, newHTTPError(http.StatusBadRequest, "invalid limit %q", input)
+		}
+	}
+	evs := h.rec.getLastEvents(limit)
+	jsonEvs := make([]Event, 0, len(evs))
+	for _, ev := range evs {
+		jsonEvs = append(jsonEvs, newEvent(ev))
+	}
+	return http.StatusOK, jsonEvs, nil
+}
+
+// serveHealth serves the health of the tree, the status of the response is 503
+// when the tree is not healthy
+func (h *Handler) serveHealth(*http.Request) (int, interface{}, error) {
+	health := newHealth(h.rec)
+	if !health.Healthy {
+		return http.StatusServiceUnavailable, health, nil
+	}
+	return http.StatusOK, health, nil
+}
+
+// DynSupervisor is the JSON representation of a DynSupervisor registered on a
+// Handler
+type DynSupervisor struct {
+	Name         string   `json:"name"`
+	Factories    []string `json:"factories"`
+	RunningNodes []string `json:"runningNodes"`
+}
+
+// serveDyn serves the registered DynSupervisors
+func (h *Handler) serveDyn(*http.Request) (int, interface{}, error) {
+	dynSups := make([]DynSupervisor, 0, len(h.dynSups))
+	for name, ds := range h.dynSups {
+		factories := make([]string, 0, len(ds.factories))
+		for factory := range ds.factories {
+			factories = append(factories, factory)
+		}
+		sort.Strings(factories)
+		runningNodes := ds.dyn.GetRunningNodes()
+		sort.Strings(runningNodes)
+		dynSups = append(dynSups, DynSupervisor{
+			Name:         name,
+			Factories:    factories,
+			RunningNodes: runningNodes,
+		})
+	}
+	sort.Slice(dynSups, func(i, j int) bool { return dynSups[i].Name < dynSups[j].Name })
+	return http.StatusOK, dynSups, nil
+}
+
+// nodeRequest is the body of the restart and terminate requests
+type nodeRequest struct {
+	Node string `json:"node"`
+}
+
+// serveNodeFn executes the given function over the node of the request
+func serveNodeFn(r *http.Request, name string, nodeFn NodeFn) (int, interface{}, error) {
+	if nodeFn == nil {
+		return 0, nil, newHTTPError(http.StatusNotImplemented, "%s is not available", name)
+	}
+	var req nodeRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+	if req.Node == "" {
+		return 0, nil, newHTTPError(http.StatusBadRequest, "missing node")
+	}
+	if err := nodeFn(req.Node); err != nil {
+		var nodeErr *cap.UnknownNodeError
+		if errors.As(err, &nodeErr) {
+			return 0, nil, &httpError{status: http.StatusNotFound, err: err}
+		}
+		return 0, nil, err
+	}
+	return http.StatusOK, req, nil
+}
+
+// serveRestart restarts the node of the request
+func (h *Handler) serveRestart(r *http.Request) (int, interface{}, error) {
+	return serveNodeFn(r, "restart", h.restartNode)
+}
+
+// serveTerminate terminates the node of the request
+func (h *Handler) serveTerminate(r *http.Request) (int, interface{}, error) {
+	return serveNodeFn(r, "terminate", h.terminateNode)
+}
+
+// dynRequest is the body of the spawn and cancel requests
+type dynRequest struct {
+	Supervisor string            `json:"supervisor"`
+	Factory    string            `json:"factory,omitempty"`
+	Name       string            `json:"name"`
+	Params     map[string]string `json:"params,omitempty"`
+}
+
+// decodeDynRequest decodes the request and returns the DynSupervisor it
+// operates on
+func (h *Handler) decodeDynRequest(r *http.Request) (dynRequest, *dynSupervisor, error) {
+	var req dynRequest
+	if err := decodeBody(r, &req); err != nil {
+		return req, nil, err
+	}
+	if req.Name == "" {
+		return req, nil, newHTTPError(http.StatusBadRequest, "missing name")
+	}
+	ds, ok := h.dynSups[req.Supervisor]
+	if !ok {
+		return req, nil, newHTTPError(http.StatusNotFound, "unknown supervisor %q", req.Supervisor)
+	}
+	return req, ds, nil
+}
+
+// dropStaleCancels removes the cancel functions of the spawned nodes that are
+// not running anymore (e.g. they completed or their start failed), this way,
+// nodes with the same name may be spawned again. It must be called while
+// holding the lock of the dynSupervisor.
+func (ds *dynSupervisor) dropStaleCancels() {
+	running := make(map[string]bool)
+	for _, runtimeName := range ds.dyn.GetRunningNodes() {
+		running[runtimeName] = true
+	}
+	for name := range ds.cancels {
+		if !running[fmt.Sprintf("%s/%s", ds.dyn.GetName(), name)] {
+			delete(ds.cancels, name)
+		}
+	}
+}
+
+// serveSpawn spawns a node on a DynSupervisor
+func (h *Handler) serveSpawn(r *http.Request) (int, interface{}, error) {
+	req, ds, err := h.decodeDynRequest(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	factory, ok := ds.factories[req.Factory]
+	if !ok {
+		return 0, nil, newHTTPError(http.StatusNotFound, "unknown factory %q", req.Factory)
+	}
+
+	ds.mux.Lock()
+	defer ds.mux.Unlock()
+
+	ds.dropStaleCancels()
+	if _, ok := ds.cancels[req.Name]; ok {
+		return 0, nil, newHTTPError(http.StatusConflict, "node %q already spawned", req.Name)
+	}
+	node, err := factory(req.Name, req.Params)
+	if err != nil {
+		return 0, nil, newHTTPError(http.StatusBadRequest, "factory %q failed: %v", req.Factory, err)
+	}
+	cancel, err := ds.dyn.Spawn(node)
+	if err != nil {
+		return 0, nil, err
+	}
+	ds.cancels[req.Name] = cancel
+	return http.StatusCreated, req, nil
+}
+
+// serveCancel cancels a node that was spawned on a DynSupervisor
+func (h *Handler) serveCancel(r *http.Request) (int, interface{}, error) {
+	req, ds, err := h.decodeDynRequest(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	ds.mux.Lock()
+	ds.dropStaleCancels()
+	cancel, ok := ds.cancels[req.Name]
+	delete(ds.cancels, req.Name)
+	ds.mux.Unlock()
+
+	if !ok {
+		return 0, nil, newHTTPError(http.StatusNotFound, "unknown spawned node %q", req.Name)
+	}
+	// the cancel function blocks until the node terminates, other requests must
+	// not wait on it
+	if err := cancel(); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, req, nil
+}
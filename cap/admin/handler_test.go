@@ -0,0 +1,335 @@
+package admin_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	"github.com/capatazlib/go-capataz/cap/admin"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// failOnceWorker creates a worker that fails on its first run, and blocks
+// until its context is done afterwards
+func failOnceWorker(name string) cap.Node {
+	var runs int32
+	return cap.NewWorker(name, func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			return errors.New("first run failure")
+		}
+		<-ctx.Done()
+		return nil
+	})
+}
+
+// startSupervisor starts a supervision tree that reports its events to the
+// returned Recorder
+func startSupervisor(t *testing.T, opts ...admin.RecorderOpt) (cap.Supervisor, *admin.Recorder) {
+	rec := admin.NewRecorder(opts...)
+	b0 := cap.NewSupervisorSpec(
+		"api",
+		cap.WithNodes(WaitDoneWorker("listener"), failOnceWorker("flaky")),
+	)
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(cap.Subtree(b0), WaitDoneWorker("cache")),
+		cap.WithNotifier(rec.HandleEvent),
+	).Start(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the flaky worker restarts right after the tree starts
+	waitFor(t, func() bool { return rec.GetRestartCount("root/api/flaky") == 1 })
+	return sup, rec
+}
+
+// waitFor blocks until the given condition is true, or fails the test after a
+// second
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition was not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// request executes a request on the given handler, and decodes the JSON body
+// of the response on the given value
+func request(
+	t *testing.T,
+	h http.Handler,
+	method, target, body string,
+	out interface{},
+) int {
+	t.Helper()
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, target, nil)
+	} else {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if out != nil {
+		if err := json.NewDecoder(rr.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rr.Code
+}
+
+func TestHandlerTree(t *testing.T) {
+	sup, rec := startSupervisor(t)
+	defer sup.Terminate()
+
+	h := admin.NewHandler(sup, rec)
+
+	var tree admin.Node
+	assert.Equal(t, http.StatusOK, request(t, h, "GET", "/tree", "", &tree))
+
+	assert.Equal(t, "root", tree.RuntimeName)
+	assert.Equal(t, "Running", tree.Status)
+	assert.Nil(t, tree.Tolerance)
+	if assert.Len(t, tree.Children, 2) {
+		api := tree.Children[0]
+		assert.Equal(t, "root/api", api.RuntimeName)
+		assert.Equal(t, "Supervisor", api.Tag)
+		assert.Equal(t, "LeftToRight", api.StartOrder)
+		if assert.Len(t, api.Children, 2) {
+			flaky := api.Children[1]
+			assert.Equal(t, "root/api/flaky", flaky.RuntimeName)
+			assert.Equal(t, "Running", flaky.Status)
+			assert.Equal(t, admin.HealthyNode, flaky.Health)
+			assert.Equal(t, uint32(1), flaky.Restarts)
+		}
+		assert.Equal(t, uint32(0), tree.Children[1].Restarts)
+	}
+
+	assert.Equal(t, http.StatusMethodNotAllowed, request(t, h, "POST", "/tree", "", nil))
+}
+
+func TestHandlerEventsAndHealth(t *testing.T) {
+	sup, rec := startSupervisor(t)
+	defer sup.Terminate()
+
+	h := admin.NewHandler(sup, rec)
+
+	var evs []admin.Event
+	assert.Equal(t, http.StatusOK, request(t, h, "GET", "/events", "", &evs))
+
+	failures := []admin.Event{}
+	for _, ev := range evs {
+		if ev.Tag == "ProcessFailed" {
+			failures = append(failures, ev)
+		}
+	}
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, "root/api/flaky", failures[0].RuntimeName)
+		assert.Equal(t, "Worker", failures[0].NodeTag)
+		assert.Equal(t, "first run failure", failures[0].Error)
+	}
+
+	var lastEvs []admin.Event
+	assert.Equal(t, http.StatusOK, request(t, h, "GET", "/events?limit=2", "", &lastEvs))
+	assert.Equal(t, evs[len(evs)-2:], lastEvs)
+	assert.Equal(t, http.StatusBadRequest, request(t, h, "GET", "/events?limit=x", "", nil))
+
+	var health admin.Health
+	assert.Equal(t, http.StatusOK, request(t, h, "GET", "/health", "", &health))
+	assert.True(t, health.Healthy)
+	assert.Equal(t, map[string]uint32{"root/api/flaky": 1}, health.Restarts)
+}
+
+func TestRecorderBufferSize(t *testing.T) {
+	sup, rec := startSupervisor(t, admin.WithBufferSize(3))
+
+	// the tree reports more than three events when it starts
+	assert.Len(t, rec.GetEvents(), 3)
+
+	assert.NoError(t, sup.Terminate())
+
+	evs := rec.GetEvents()
+	if assert.Len(t, evs, 3) {
+		assert.Equal(t, "root/api", evs[1].GetProcessRuntimeName())
+		assert.Equal(t, cap.ProcessTerminated, evs[2].GetTag())
+		assert.Equal(t, "root", evs[2].GetProcessRuntimeName())
+	}
+}
+
+func TestHandlerNodeOperations(t *testing.T) {
+	sup, rec := startSupervisor(t)
+	defer sup.Terminate()
+
+	restarted := []string{}
+	restartNode := func(runtimeName string) error {
+		if runtimeName != "root/cache" {
+			return errors.New("unknown node")
+		}
+		restarted = append(restarted, runtimeName)
+		return nil
+	}
+
+	// mutations are rejected without an authorizer
+	h := admin.NewHandler(sup, rec, admin.WithRestartNode(restartNode))
+	body := `{"node": "root/cache"}`
+	assert.Equal(t, http.StatusForbidden, request(t, h, "POST", "/restart", body, nil))
+
+	h = admin.NewHandler(
+		sup,
+		rec,
+		admin.WithRestartNode(restartNode),
+		admin.WithAuthorizer(func(r *http.Request) bool {
+			return r.Header.Get("X-Operator") == "on-call"
+		}),
+	)
+	assert.Equal(t, http.StatusForbidden, request(t, h, "POST", "/restart", body, nil))
+
+	authorized := admin.NewHandler(
+		sup,
+		rec,
+		admin.WithRestartNode(restartNode),
+		admin.WithAuthorizer(func(*http.Request) bool { return true }),
+	)
+	assert.Equal(t, http.StatusOK, request(t, authorized, "POST", "/restart", body, nil))
+	assert.Equal(t, []string{"root/cache"}, restarted)
+
+	var errBody map[string]string
+	assert.Equal(
+		t,
+		http.StatusInternalServerError,
+		request(t, authorized, "POST", "/restart", `{"node": "root/unknown"}`, &errBody),
+	)
+	assert.Equal(t, "unknown node", errBody["error"])
+	assert.Equal(t, http.StatusBadRequest, request(t, authorized, "POST", "/restart", `{}`, nil))
+	assert.Equal(t, http.StatusBadRequest, request(t, authorized, "POST", "/restart", `{`, nil))
+
+	// terminate uses the TerminateNode method of the supervisor by default
+	assert.Equal(t, http.StatusOK, request(t, authorized, "POST", "/terminate", body, nil))
+	assert.Equal(t, http.StatusNotFound, request(t, authorized, "POST", "/terminate", body, &errBody))
+	assert.Equal(t, "unknown node root/cache", errBody["error"])
+}
+
+func TestHandlerDynSupervisor(t *testing.T) {
+	sup, rec := startSupervisor(t)
+	defer sup.Terminate()
+
+	dyn, err := cap.NewDynSupervisor(context.TODO(), "jobs", cap.WithNotifier(rec.HandleEvent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dyn.Terminate()
+
+	h := admin.NewHandler(
+		sup,
+		rec,
+		admin.WithAuthorizer(func(*http.Request) bool { return true }),
+		admin.WithDynSupervisor(&dyn, map[string]admin.NodeFactory{
+			"wait": func(name string, params map[string]string) (cap.Node, error) {
+				if params["fail"] != "" {
+					return nil, errors.New("invalid params")
+				}
+				return WaitDoneWorker(name), nil
+			},
+		}),
+	)
+
+	spawn := `{"supervisor": "jobs", "factory": "wait", "name": "job-1"}`
+	assert.Equal(t, http.StatusCreated, request(t, h, "POST", "/spawn", spawn, nil))
+	assert.Equal(t, http.StatusConflict, request(t, h, "POST", "/spawn", spawn, nil))
+	assert.Equal(
+		t,
+		http.StatusBadRequest,
+		request(
+			t, h, "POST", "/spawn",
+			`{"supervisor": "jobs", "factory": "wait", "name": "job-2", "params": {"fail": "yes"}}`,
+			nil,
+		),
+	)
+	assert.Equal(
+		t,
+		http.StatusNotFound,
+		request(t, h, "POST", "/spawn", `{"supervisor": "jobs", "factory": "other", "name": "job-2"}`, nil),
+	)
+	assert.Equal(
+		t,
+		http.StatusNotFound,
+		request(t, h, "POST", "/spawn", `{"supervisor": "other", "factory": "wait", "name": "job-2"}`, nil),
+	)
+
+	var dynSups []admin.DynSupervisor
+	assert.Equal(t, http.StatusOK, request(t, h, "GET", "/dyn", "", &dynSups))
+	assert.Equal(
+		t,
+		[]admin.DynSupervisor{
+			{Name: "jobs", Factories: []string{"wait"}, RunningNodes: []string{"jobs", "jobs/job-1"}},
+		},
+		dynSups,
+	)
+
+	cancel := `{"supervisor": "jobs", "name": "job-1"}`
+	assert.Equal(t, http.StatusOK, request(t, h, "POST", "/cancel", cancel, nil))
+	assert.Equal(t, http.StatusNotFound, request(t, h, "POST", "/cancel", cancel, nil))
+}
+
+func TestHandlerDynSupervisorRespawn(t *testing.T) {
+	sup, rec := startSupervisor(t)
+	defer sup.Terminate()
+
+	dyn, err := cap.NewDynSupervisor(context.TODO(), "jobs", cap.WithNotifier(rec.HandleEvent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dyn.Terminate()
+
+	doneCh := make(chan struct{})
+	h := admin.NewHandler(
+		sup,
+		rec,
+		admin.WithAuthorizer(func(*http.Request) bool { return true }),
+		admin.WithDynSupervisor(&dyn, map[string]admin.NodeFactory{
+			"once": func(name string, params map[string]string) (cap.Node, error) {
+				return cap.NewWorker(name, func(ctx context.Context) error {
+					select {
+					case <-doneCh:
+					case <-ctx.Done():
+					}
+					return nil
+				}, cap.WithRestart(cap.Temporary)), nil
+			},
+		}),
+	)
+
+	spawn := `{"supervisor": "jobs", "factory": "once", "name": "job-1"}`
+	assert.Equal(t, http.StatusCreated, request(t, h, "POST", "/spawn", spawn, nil))
+	assert.Equal(t, http.StatusConflict, request(t, h, "POST", "/spawn", spawn, nil))
+
+	// the node completes, it may be spawned again with the same name
+	close(doneCh)
+	deadline := time.Now().Add(time.Second)
+	for len(dyn.GetRunningNodes()) > 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, []string{"jobs"}, dyn.GetRunningNodes())
+
+	cancel := `{"supervisor": "jobs", "name": "job-1"}`
+	assert.Equal(t, http.StatusNotFound, request(t, h, "POST", "/cancel", cancel, nil))
+	assert.Equal(t, http.StatusCreated, request(t, h, "POST", "/spawn", spawn, nil))
+}
+
+func TestRecorderInvalidBufferSize(t *testing.T) {
+	assert.Panics(t, func() {
+		admin.WithBufferSize(0)
+	})
+}
@@ -0,0 +1,140 @@
+package admin
+
+// This file contains the JSON representation of the supervision tree, its
+// events and its health.
+
+import (
+	"sort"
+	"time"
+
+	"github.com/capatazlib/go-capataz/cap"
+)
+
+// Node health values
+const (
+	// HealthyNode is the health of a node that has not failed
+	HealthyNode = "Healthy"
+	// FailedNode is the health of a node that failed and has not started
+	// again, when the tree has more failures than the allowed ones
+	FailedNode = "Failed"
+	// DelayedRestartNode is the health of a node that failed and is taking
+	// too long to start again
+	DelayedRestartNode = "DelayedRestart"
+)
+
+// Tolerance is the JSON representation of the error tolerance of a node
+type Tolerance struct {
+	MaxErrCount uint32 `json:"maxErrCount"`
+	ErrWindow   string `json:"errWindow"`
+}
+
+// Node is the JSON representation of a node of a running supervision tree
+type Node struct {
+	Name        string     `json:"name"`
+	RuntimeName string     `json:"runtimeName"`
+	Tag         string     `json:"tag"`
+	Restart     string     `json:"restart,omitempty"`
+	Shutdown    string     `json:"shutdown"`
+	Tolerance   *Tolerance `json:"tolerance,omitempty"`
+	DependsOn   []string   `json:"dependsOn,omitempty"`
+	StartOrder  string     `json:"startOrder,omitempty"`
+	Status      string     `json:"status"`
+	Health      string     `json:"health"`
+	Restarts    uint32     `json:"restarts"`
+	Children    []Node     `json:"children,omitempty"`
+}
+
+// Event is the JSON representation of an event of a supervision tree
+type Event struct {
+	Tag         string    `json:"tag"`
+	NodeTag     string    `json:"nodeTag"`
+	RuntimeName string    `json:"runtimeName"`
+	Error       string    `json:"error,omitempty"`
+	Created     time.Time `json:"created"`
+	Duration    string    `json:"duration,omitempty"`
+}
+
+// Health is the JSON representation of the health of a supervision tree
+type Health struct {
+	Healthy             bool              `json:"healthy"`
+	FailedNodes         []string          `json:"failedNodes"`
+	DelayedRestartNodes []string          `json:"delayedRestartNodes"`
+	Restarts            map[string]uint32 `json:"restarts"`
+}
+
+// newNode returns the JSON representation of the given node and its
+// descendants
+func newNode(info cap.NodeInfo, rec *Recorder, report cap.HealthReport) Node {
+	node := Node{
+		Name:        info.GetName(),
+		RuntimeName: info.GetRuntimeName(),
+		Tag:         info.GetTag().String(),
+		Shutdown:    info.GetShutdown().String(),
+		DependsOn:   info.GetDependencies(),
+		Status:      info.GetStatus().String(),
+		Health:      nodeHealth(info.GetRuntimeName(), report),
+		Restarts:    rec.GetRestartCount(info.GetRuntimeName()),
+	}
+	if !info.IsRoot() {
+		maxErrCount, errWindow := info.GetTolerance()
+		node.Restart = info.GetRestart().String()
+		node.Tolerance = &Tolerance{MaxErrCount: maxErrCount, ErrWindow: errWindow.String()}
+	}
+	if info.GetTag() == cap.SupervisorT {
+		node.StartOrder = info.GetStartOrder().String()
+	}
+	for _, child := range info.GetChildren() {
+		node.Children = append(node.Children, newNode(child, rec, report))
+	}
+	return node
+}
+
+// nodeHealth returns the health of the node with the given runtime name
+func nodeHealth(runtimeName string, report cap.HealthReport) string {
+	switch {
+	case report.GetDelayedRestartProcesses()[runtimeName]:
+		return DelayedRestartNode
+	case report.GetFailedProcesses()[runtimeName]:
+		return FailedNode
+	default:
+		return HealthyNode
+	}
+}
+
+// newEvent returns the JSON representation of the given event
+func newEvent(ev cap.Event) Event {
+	jsonEv := Event{
+		Tag:         ev.GetTag().String(),
+		NodeTag:     ev.GetNodeTag().String(),
+		RuntimeName: ev.GetProcessRuntimeName(),
+		Created:     ev.GetCreated(),
+	}
+	if ev.Err() != nil {
+		jsonEv.Error = ev.Err().Error()
+	}
+	if ev.GetDuration() > 0 {
+		jsonEv.Duration = ev.GetDuration().String()
+	}
+	return jsonEv
+}
+
+// newHealth returns the JSON representation of the health of the tree
+func newHealth(rec *Recorder) Health {
+	report := rec.GetHealthReport()
+	return Health{
+		Healthy:             report.IsHealthyReport(),
+		FailedNodes:         sortedKeys(report.GetFailedProcesses()),
+		DelayedRestartNodes: sortedKeys(report.GetDelayedRestartProcesses()),
+		Restarts:            rec.GetRestartCounts(),
+	}
+}
+
+// sortedKeys returns the keys of the given set in lexicographical order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
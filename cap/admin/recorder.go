@@ -0,0 +1,187 @@
+package admin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/capatazlib/go-capataz/cap"
+)
+
+// defaultBufferSize is the number of events a Recorder keeps by default
+const defaultBufferSize = 100
+
+// subscriberBufferSize is the number of events a subscriber of a Recorder may
+// have pending, newer events are dropped for the subscriber when it is full
+const subscriberBufferSize = 64
+
+// Recorder keeps the most recent events of a supervision tree in a ring
+// buffer, the number of times every node of the tree restarted, and a
+// cap.HealthcheckMonitor with the health of the tree.
+//
+// The HandleEvent method of the Recorder must be registered on the root
+// supervisor with cap.WithNotifier.
+type Recorder struct {
+	mux    sync.Mutex
+	events []cap.Event
+	next   int
+	full   bool
+	starts map[string]uint32
+	health *cap.HealthcheckMonitor
+
+	subscribers map[int]chan cap.Event
+	nextSubID   int
+}
+
+// RecorderOpt is used to configure a Recorder
+type RecorderOpt func(*Recorder)
+
+// WithBufferSize specifies how many of the most recent events the Recorder
+// keeps; by default it keeps 100 events.
+//
+// The given size must be greater than zero, otherwise, the system will panic.
+func WithBufferSize(size int) RecorderOpt {
+	if size <= 0 {
+		panic(fmt.Sprintf("invalid recorder buffer size %d", size))
+	}
+	return func(rec *Recorder) {
+		rec.events = make([]cap.Event, size)
+	}
+}
+
+// WithHealthcheckMonitor specifies the cap.HealthcheckMonitor that assess the
+// health of the tree; by default, the tree is unhealthy as soon as a node
+// fails, and until it restarts.
+func WithHealthcheckMonitor(health *cap.HealthcheckMonitor) RecorderOpt {
+	return func(rec *Recorder) {
+		rec.health = health
+	}
+}
+
+// NewRecorder creates a new Recorder
+func NewRecorder(opts ...RecorderOpt) *Recorder {
+	rec := &Recorder{
+		events:      make([]cap.Event, defaultBufferSize),
+		starts:      make(map[string]uint32),
+		health:      cap.NewHealthcheckMonitor(0, time.Minute),
+		subscribers: make(map[int]chan cap.Event),
+	}
+	for _, optFn := range opts {
+		optFn(rec)
+	}
+	return rec
+}
+
+// HandleEvent registers the given event on the Recorder
+func (rec *Recorder) HandleEvent(ev cap.Event) {
+	rec.health.HandleEvent(ev)
+
+	rec.mux.Lock()
+	defer rec.mux.Unlock()
+
+	rec.events[rec.next] = ev
+	rec.next = (rec.next + 1) % len(rec.events)
+	if rec.next == 0 {
+		rec.full = true
+	}
+
+	if ev.GetTag() == cap.ProcessStarted {
+		rec.starts[ev.GetProcessRuntimeName()]++
+	}
+
+	for _, evCh := range rec.subscribers {
+		// the supervision tree must never block on a slow subscriber
+		select {
+		case evCh <- ev:
+		default:
+		}
+	}
+}
+
+// subscribe returns the given number of most recent events (check
+// getLastEvents), a channel that receives the events registered after the call,
+// and a function that cancels the subscription. Events are dropped for
+// subscribers that do not keep up.
+func (rec *Recorder) subscribe(limit int) ([]cap.Event, <-chan cap.Event, func()) {
+	rec.mux.Lock()
+	defer rec.mux.Unlock()
+
+	evs := lastEvents(rec.bufferedEvents(), limit)
+
+	subID := rec.nextSubID
+	rec.nextSubID++
+	evCh := make(chan cap.Event, subscriberBufferSize)
+	rec.subscribers[subID] = evCh
+
+	return evs, evCh, func() {
+		rec.mux.Lock()
+		defer rec.mux.Unlock()
+		delete(rec.subscribers, subID)
+	}
+}
+
+// GetEvents returns the events kept on the Recorder, the oldest first
+func (rec *Recorder) GetEvents() []cap.Event {
+	rec.mux.Lock()
+	defer rec.mux.Unlock()
+	return rec.bufferedEvents()
+}
+
+// bufferedEvents returns a copy of the events of the ring buffer, the oldest
+// first; it must be called with the mutex locked
+func (rec *Recorder) bufferedEvents() []cap.Event {
+	if !rec.full {
+		return append([]cap.Event{}, rec.events[:rec.next]...)
+	}
+	return append(
+		append([]cap.Event{}, rec.events[rec.next:]...),
+		rec.events[:rec.next]...,
+	)
+}
+
+// getLastEvents returns the given number of most recent events, the oldest
+// first; all the events are returned when the limit is zero
+func (rec *Recorder) getLastEvents(limit int) []cap.Event {
+	return lastEvents(rec.GetEvents(), limit)
+}
+
+// lastEvents returns the given number of events from the end of the given
+// events, or all of them when the limit is zero
+func lastEvents(evs []cap.Event, limit int) []cap.Event {
+	if limit > 0 && limit < len(evs) {
+		evs = evs[len(evs)-limit:]
+	}
+	return evs
+}
+
+// GetRestartCount returns the number of times the node with the given runtime
+// name started again after its first start
+func (rec *Recorder) GetRestartCount(runtimeName string) uint32 {
+	rec.mux.Lock()
+	defer rec.mux.Unlock()
+
+	if starts := rec.starts[runtimeName]; starts > 1 {
+		return starts - 1
+	}
+	return 0
+}
+
+// GetRestartCounts returns the restart count of every node that restarted at
+// least once, indexed by runtime name
+func (rec *Recorder) GetRestartCounts() map[string]uint32 {
+	rec.mux.Lock()
+	defer rec.mux.Unlock()
+
+	counts := make(map[string]uint32)
+	for runtimeName, starts := range rec.starts {
+		if starts > 1 {
+			counts[runtimeName] = starts - 1
+		}
+	}
+	return counts
+}
+
+// GetHealthReport returns the health report of the tree
+func (rec *Recorder) GetHealthReport() cap.HealthReport {
+	return rec.health.GetHealthReport()
+}
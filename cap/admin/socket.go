@@ -0,0 +1,386 @@
+package admin
+
+// This file contains the unix socket server of a supervision tree and its
+// client. The protocol uses line-delimited JSON: the client writes a single
+// Request line, and the server writes Response lines until it closes the
+// connection.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/capatazlib/go-capataz/cap"
+)
+
+// Commands of the socket protocol
+const (
+	// TreeCommand requests the supervision tree, the server writes a single
+	// Response with a Tree
+	TreeCommand = "tree"
+	// EventsCommand requests the most recent events, the server writes a
+	// Response with an Event per event; when the request has Follow set, the
+	// server keeps writing the new events until the connection gets closed
+	EventsCommand = "events"
+	// HealthCommand requests the health of the tree, the server writes a
+	// single Response with a Health
+	HealthCommand = "health"
+	// RestartCommand restarts the Node of the request, the server writes a
+	// single Response with the Node
+	RestartCommand = "restart"
+	// TerminateCommand terminates the Node of the request, the server writes a
+	// single Response with the Node
+	TerminateCommand = "terminate"
+)
+
+// Request is the message a client sends to a SocketServer
+type Request struct {
+	Command string `json:"command"`
+	Node    string `json:"node,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+	Follow  bool   `json:"follow,omitempty"`
+}
+
+// Response is a message a SocketServer sends to a client, only the field of
+// the requested command is set (or the Error field when the command failed)
+type Response struct {
+	Error  string  `json:"error,omitempty"`
+	Tree   *Node   `json:"tree,omitempty"`
+	Event  *Event  `json:"event,omitempty"`
+	Health *Health `json:"health,omitempty"`
+	Node   string  `json:"node,omitempty"`
+}
+
+// SocketServer serves the operational surface of a running supervision tree on
+// a unix socket. Check the Commands of the protocol for the available
+// operations.
+//
+// The socket file is only accessible by the owner of the process, this is the
+// only protection of the socket: unlike the Handler, the restart and terminate
+// commands do not go through an Authorizer (even when one is given with
+// WithAuthorizer), every process that may connect to the socket may use them.
+type SocketServer struct {
+	settings
+
+	sup      cap.Supervisor
+	rec      *Recorder
+	path     string
+	listener net.Listener
+
+	doneCh    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	mux   sync.Mutex
+	conns map[net.Conn]bool
+}
+
+// ListenUnix creates a SocketServer for the given supervisor that listens on
+// the unix socket with the given path; the given Recorder must be registered as
+// a notifier of the supervisor. A stale socket file (e.g. left by a crashed
+// process) on the given path gets replaced.
+func ListenUnix(path string, sup cap.Supervisor, rec *Recorder, opts ...Opt) (*SocketServer, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	listener, err := listenPrivateUnix(path)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &SocketServer{
+		settings: buildSettings(sup, opts),
+		sup:      sup,
+		rec:      rec,
+		path:     path,
+		listener: listener,
+		doneCh:   make(chan struct{}),
+		conns:    make(map[net.Conn]bool),
+	}
+	srv.wg.Add(1)
+	go srv.acceptLoop()
+	return srv, nil
+}
+
+// WithUnixSocket is a cap.Opt that makes a root supervisor serve its tree on
+// the unix socket with the given path (check ListenUnix) for as long as it
+// runs. The given Recorder must be registered as a notifier of the supervisor.
+//
+// Example:
+//
+//   rec := admin.NewRecorder()
+//   spec := cap.NewSupervisorSpec(
+//     "root",
+//     cap.WithNodes(apiSubtree, dbSubtree),
+//     cap.WithNotifier(rec.HandleEvent),
+//     admin.WithUnixSocket("/var/run/myapp.sock", rec),
+//   )
+//
+func WithUnixSocket(path string, rec *Recorder, opts ...Opt) cap.Opt {
+	return cap.WithStartHook(func(sup cap.Supervisor) (func(), error) {
+		srv, err := ListenUnix(path, sup, rec, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return func() { _ = srv.Close() }, nil
+	})
+}
+
+// removeStaleSocket removes the socket file on the given path when there is no
+// process listening on it
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and it is not a socket", path)
+	}
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("socket %s is already in use", path)
+	}
+	return os.Remove(path)
+}
+
+// listenPrivateUnix listens on a unix socket with the given path that is only
+// accessible by the owner of the process. The socket gets created inside a
+// private directory, and it is moved to the given path once its permissions are
+// restricted; this way, no other user may connect to it in between.
+//
+// The socket file is not removed when the returned listener gets closed.
+func listenPrivateUnix(path string) (net.Listener, error) {
+	// the directory is created with 0700 permissions; it is on the same
+	// directory of the socket, so that the rename does not cross file systems
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".cap")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, "s")
+	listener, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	// the socket file is moved, we remove it ourselves on Close
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Close stops the server, closing all its connections, and removes the socket
+// file
+func (srv *SocketServer) Close() error {
+	var err error
+	srv.closeOnce.Do(func() {
+		close(srv.doneCh)
+		err = srv.listener.Close()
+		if rmErr := os.Remove(srv.path); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) && err == nil {
+			err = rmErr
+		}
+
+		srv.mux.Lock()
+		for conn := range srv.conns {
+			_ = conn.Close()
+		}
+		srv.mux.Unlock()
+
+		srv.wg.Wait()
+	})
+	return err
+}
+
+// acceptLoop serves the connections of the listener until it gets closed
+func (srv *SocketServer) acceptLoop() {
+	defer srv.wg.Done()
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			return
+		}
+		srv.mux.Lock()
+		select {
+		case <-srv.doneCh:
+			// the server got closed while we accepted the connection
+			srv.mux.Unlock()
+			_ = conn.Close()
+			return
+		default:
+		}
+		srv.conns[conn] = true
+		srv.wg.Add(1)
+		srv.mux.Unlock()
+
+		go srv.serveConn(conn)
+	}
+}
+
+// serveConn serves the request of the given connection
+func (srv *SocketServer) serveConn(conn net.Conn) {
+	defer srv.wg.Done()
+	defer func() {
+		srv.mux.Lock()
+		delete(srv.conns, conn)
+		srv.mux.Unlock()
+		_ = conn.Close()
+	}()
+
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+
+	var req Request
+	if err := dec.Decode(&req); err != nil {
+		_ = enc.Encode(Response{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	switch req.Command {
+	case TreeCommand:
+		tree := newNode(srv.sup.Describe(), srv.rec, srv.rec.GetHealthReport())
+		_ = enc.Encode(Response{Tree: &tree})
+	case HealthCommand:
+		health := newHealth(srv.rec)
+		_ = enc.Encode(Response{Health: &health})
+	case EventsCommand:
+		srv.serveEvents(conn, enc, req)
+	case RestartCommand:
+		_ = enc.Encode(runNodeFn(RestartCommand, srv.restartNode, req.Node))
+	case TerminateCommand:
+		_ = enc.Encode(runNodeFn(TerminateCommand, srv.terminateNode, req.Node))
+	default:
+		_ = enc.Encode(Response{Error: fmt.Sprintf("unknown command %q", req.Command)})
+	}
+}
+
+// runNodeFn executes the given function over the given node
+func runNodeFn(name string, nodeFn NodeFn, node string) Response {
+	if nodeFn == nil {
+		return Response{Error: fmt.Sprintf("%s is not available", name)}
+	}
+	if node == "" {
+		return Response{Error: "missing node"}
+	}
+	if err := nodeFn(node); err != nil {
+		return Response{Error: err.Error()}
+	}
+	return Response{Node: node}
+}
+
+// serveEvents writes the most recent events, and the new ones when the request
+// follows the events
+func (srv *SocketServer) serveEvents(conn net.Conn, enc *json.Encoder, req Request) {
+	if !req.Follow {
+		for _, ev := range srv.rec.getLastEvents(req.Limit) {
+			jsonEv := newEvent(ev)
+			if err := enc.Encode(Response{Event: &jsonEv}); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	evs, evCh, cancel := srv.rec.subscribe(req.Limit)
+	defer cancel()
+
+	for _, ev := range evs {
+		jsonEv := newEvent(ev)
+		if err := enc.Encode(Response{Event: &jsonEv}); err != nil {
+			return
+		}
+	}
+
+	// the client does not write after its request, a read returns once the
+	// client closes the connection
+	closedCh := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, conn)
+		close(closedCh)
+	}()
+
+	for {
+		select {
+		case <-srv.doneCh:
+			return
+		case <-closedCh:
+			return
+		case ev := <-evCh:
+			jsonEv := newEvent(ev)
+			if err := enc.Encode(Response{Event: &jsonEv}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// SocketRequest sends the given request to the SocketServer listening on the
+// unix socket with the given path, and calls the given function with every
+// Response. It returns once the server closes the connection, the given
+// context is done, or a Response has an error.
+func SocketRequest(
+	ctx context.Context,
+	path string,
+	req Request,
+	onResponse func(Response) error,
+) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// the connection gets closed when the context is done (e.g. a followed
+	// events request)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-stopCh:
+		}
+	}()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(conn)
+	for {
+		var resp Response
+		if err := dec.Decode(&resp); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		if err := onResponse(resp); err != nil {
+			return err
+		}
+	}
+}
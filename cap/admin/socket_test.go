@@ -0,0 +1,285 @@
+package admin_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	"github.com/capatazlib/go-capataz/cap/admin"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// tempSocketPath returns the path of a unix socket on a temporary directory,
+// and a function that removes the directory
+func tempSocketPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "capataz-admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "admin.sock"), func() { _ = os.RemoveAll(dir) }
+}
+
+// collectResponses sends the given request and returns all the responses
+func collectResponses(t *testing.T, socketPath string, req admin.Request) ([]admin.Response, error) {
+	resps := []admin.Response{}
+	err := admin.SocketRequest(
+		context.TODO(),
+		socketPath,
+		req,
+		func(resp admin.Response) error {
+			resps = append(resps, resp)
+			return nil
+		},
+	)
+	return resps, err
+}
+
+func TestSocketServerCommands(t *testing.T) {
+	socketPath, cleanup := tempSocketPath(t)
+	defer cleanup()
+
+	sup, rec := startSupervisor(t)
+	defer sup.Terminate()
+
+	restartedCh := make(chan string, 1)
+	srv, err := admin.ListenUnix(
+		socketPath,
+		sup,
+		rec,
+		admin.WithRestartNode(func(runtimeName string) error {
+			restartedCh <- runtimeName
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(socketPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+	// the private directory used to create the socket is removed
+	entries, err := ioutil.ReadDir(filepath.Dir(socketPath))
+	if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+		assert.Equal(t, "admin.sock", entries[0].Name())
+	}
+
+	resps, err := collectResponses(t, socketPath, admin.Request{Command: admin.TreeCommand})
+	assert.NoError(t, err)
+	if assert.Len(t, resps, 1) && assert.NotNil(t, resps[0].Tree) {
+		assert.Equal(t, "root", resps[0].Tree.RuntimeName)
+		assert.Len(t, resps[0].Tree.Children, 2)
+	}
+
+	resps, err = collectResponses(t, socketPath, admin.Request{Command: admin.HealthCommand})
+	assert.NoError(t, err)
+	if assert.Len(t, resps, 1) && assert.NotNil(t, resps[0].Health) {
+		assert.True(t, resps[0].Health.Healthy)
+		assert.Equal(t, map[string]uint32{"root/api/flaky": 1}, resps[0].Health.Restarts)
+	}
+
+	resps, err = collectResponses(t, socketPath, admin.Request{Command: admin.EventsCommand, Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, resps, 2)
+
+	resps, err = collectResponses(
+		t,
+		socketPath,
+		admin.Request{Command: admin.RestartCommand, Node: "root/cache"},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []admin.Response{{Node: "root/cache"}}, resps)
+	assert.Equal(t, "root/cache", <-restartedCh)
+
+	// terminate uses the TerminateNode method of the supervisor by default
+	resps, err = collectResponses(
+		t,
+		socketPath,
+		admin.Request{Command: admin.TerminateCommand, Node: "root/cache"},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []admin.Response{{Node: "root/cache"}}, resps)
+
+	_, err = collectResponses(t, socketPath, admin.Request{Command: admin.TerminateCommand, Node: "root/cache"})
+	assert.EqualError(t, err, "unknown node root/cache")
+
+	_, err = collectResponses(t, socketPath, admin.Request{Command: "unknown"})
+	assert.EqualError(t, err, `unknown command "unknown"`)
+
+	assert.NoError(t, srv.Close())
+	_, err = os.Stat(socketPath)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestSocketServerFollowEvents(t *testing.T) {
+	socketPath, cleanup := tempSocketPath(t)
+	defer cleanup()
+
+	rec := admin.NewRecorder()
+	dyn, err := cap.NewDynSupervisor(
+		context.TODO(),
+		"jobs",
+		cap.WithNotifier(rec.HandleEvent),
+		admin.WithUnixSocket(socketPath, rec),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	evCh := make(chan admin.Event)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- admin.SocketRequest(
+			ctx,
+			socketPath,
+			admin.Request{Command: admin.EventsCommand, Follow: true},
+			func(resp admin.Response) error {
+				evCh <- *resp.Event
+				return nil
+			},
+		)
+	}()
+
+	// the recorded start of the supervisor is written first
+	ev := <-evCh
+	assert.Equal(t, "ProcessStarted", ev.Tag)
+	assert.Equal(t, "jobs", ev.RuntimeName)
+
+	_, err = dyn.Spawn(WaitDoneWorker("job-1"))
+	assert.NoError(t, err)
+
+	select {
+	case ev = <-evCh:
+		assert.Equal(t, "ProcessStarted", ev.Tag)
+		assert.Equal(t, "jobs/job-1", ev.RuntimeName)
+	case <-time.After(time.Second):
+		t.Fatal("expected followed event")
+	}
+
+	cancel()
+	assert.True(t, errors.Is(<-errCh, context.Canceled))
+
+	// the socket is removed once the supervisor terminates
+	assert.NoError(t, dyn.Terminate())
+	_, err = os.Stat(socketPath)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestWithUnixSocketSocketInUse(t *testing.T) {
+	socketPath, cleanup := tempSocketPath(t)
+	defer cleanup()
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	rec := admin.NewRecorder()
+	_, err = cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(WaitDoneWorker("child0")),
+		cap.WithNotifier(rec.HandleEvent),
+		admin.WithUnixSocket(socketPath, rec),
+	).Start(context.TODO())
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "already in use")
+}
+
+func TestListenUnixReplacesStaleSocket(t *testing.T) {
+	socketPath, cleanup := tempSocketPath(t)
+	defer cleanup()
+
+	// a listener that does not remove its file on close leaves a stale socket
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = listener.Close()
+
+	sup, rec := startSupervisor(t)
+	defer sup.Terminate()
+
+	srv, err := admin.ListenUnix(socketPath, sup, rec)
+	if assert.NoError(t, err) {
+		assert.NoError(t, srv.Close())
+	}
+}
+
+func TestWithUnixSocketTerminateDuringRestart(t *testing.T) {
+	socketPath, cleanup := tempSocketPath(t)
+	defer cleanup()
+
+	// the termination of the slow worker blocks the monitor loop of the root
+	// supervisor until it gets released
+	terminatingCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	slow := cap.NewWorker("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(terminatingCh)
+		<-releaseCh
+		return nil
+	})
+
+	var sup cap.Supervisor
+	rec := admin.NewRecorder()
+	_, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(WaitDoneWorker("child0"), slow),
+		cap.WithNotifier(rec.HandleEvent),
+		// the supervisor is registered before the socket server starts
+		cap.WithStartHook(func(started cap.Supervisor) (func(), error) {
+			sup = started
+			return func() {}, nil
+		}),
+		admin.WithUnixSocket(
+			socketPath,
+			rec,
+			admin.WithRestartNode(func(runtimeName string) error {
+				return sup.RestartNode(runtimeName)
+			}),
+		),
+	).Start(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	terminateErrCh := make(chan error, 1)
+	go func() {
+		terminateErrCh <- sup.Terminate()
+	}()
+	<-terminatingCh
+
+	// the restart request arrives while the supervisor is terminating
+	restartErrCh := make(chan error, 1)
+	go func() {
+		_, err := collectResponses(
+			t,
+			socketPath,
+			admin.Request{Command: admin.RestartCommand, Node: "root/child0"},
+		)
+		restartErrCh <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(releaseCh)
+
+	select {
+	case err := <-terminateErrCh:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not terminate")
+	}
+	assert.Error(t, <-restartErrCh)
+}
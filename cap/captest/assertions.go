@@ -1,10 +1,12 @@
-package stest
+package captest
 
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/capatazlib/go-capataz/cap"
 )
@@ -42,6 +44,16 @@ func verifyExactMatch(preds []EventP, given []cap.Event) error {
 	return nil
 }
 
+// VerifyExactMatch checks the input slice of EventP predicate match 1 to 1
+// with a given list of supervision system events; it returns an error that
+// describes the first mismatch.
+//
+// This function is useful outside regular tests (e.g. property tests), check
+// AssertExactMatch otherwise.
+func VerifyExactMatch(evs []cap.Event, preds []EventP) error {
+	return verifyExactMatch(preds, evs)
+}
+
 // AssertExactMatch is an assertion that checks the input slice of EventP
 // predicate match 1 to 1 with a given list of supervision system events.
 func AssertExactMatch(t *testing.T, evs []cap.Event, preds []EventP) {
@@ -90,18 +102,13 @@ func verifyPartialMatch(preds []EventP, given []cap.Event) []EventP {
 	return preds
 }
 
-// AssertPartialMatch is an assertion that matches in order a list of EventP
-// predicates to a list of supervision system events.
+// VerifyPartialMatch matches in order a list of EventP predicates to a list of
+// supervision system events (check AssertPartialMatch for details); it returns
+// an error that describes the predicates that did not match.
 //
-// The input events need to match in the predicate order, but the events do not
-// need to be a one to one match (e.g. the input events slice length may be
-// bigger than the predicates slice length).
-//
-// This function is useful when we want to test that some events are present in
-// the expected order. This is useful in test-cases where a supervision system
-// emits an overwhelming number of events.
-func AssertPartialMatch(t *testing.T, evs []cap.Event, preds []EventP) {
-	t.Helper()
+// This function is useful outside regular tests (e.g. property tests), check
+// AssertPartialMatch otherwise.
+func VerifyPartialMatch(evs []cap.Event, preds []EventP) error {
 	pendingPreds := verifyPartialMatch(preds, evs)
 
 	if len(pendingPreds) > 0 {
@@ -115,13 +122,78 @@ func AssertPartialMatch(t *testing.T, evs []cap.Event, preds []EventP) {
 			evStrs = append(evStrs, ev.String())
 		}
 
-		t.Errorf(
+		return fmt.Errorf(
 			"Last match(es) didn't work - pending count: %d:\n%s\nInput events:\n%s",
 			len(pendingPreds),
 			strings.Join(pendingPredStrs, "\n"),
 			strings.Join(evStrs, "\n"),
 		)
 	}
+	return nil
+}
+
+// AssertPartialMatch is an assertion that matches in order a list of EventP
+// predicates to a list of supervision system events.
+//
+// The input events need to match in the predicate order, but the events do not
+// need to be a one to one match (e.g. the input events slice length may be
+// bigger than the predicates slice length).
+//
+// This function is useful when we want to test that some events are present in
+// the expected order. This is useful in test-cases where a supervision system
+// emits an overwhelming number of events.
+func AssertPartialMatch(t *testing.T, evs []cap.Event, preds []EventP) {
+	t.Helper()
+	err := VerifyPartialMatch(evs, preds)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// WaitForEvent blocks until an event that matches the given predicate is
+// reported to the given iterator, and returns it. The test fails immediately
+// if the event does not happen within the given timeout.
+//
+// This function must be called from the goroutine running the test.
+func WaitForEvent(
+	t *testing.T,
+	evIt *EventIterator,
+	pred EventP,
+	timeout time.Duration,
+) cap.Event {
+	t.Helper()
+	ev, err := evIt.WaitTill(pred, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+// RunningNodesReporter is implemented by the supervisors that report the
+// nodes of their supervision tree that are running (e.g. cap.Supervisor and
+// cap.DynSupervisor)
+type RunningNodesReporter interface {
+	GetRunningNodes() []string
+}
+
+// AssertRunningNodes is an assertion that checks the nodes that are running
+// on a supervision tree match (in any order) the given runtime names.
+//
+// The running nodes get updated before an event is reported, once an event is
+// observed with an EventIterator, the snapshot of the tree reflects it.
+func AssertRunningNodes(t *testing.T, sup RunningNodesReporter, names []string) {
+	t.Helper()
+	want := append(names[:0:0], names...)
+	sort.Strings(want)
+	given := sup.GetRunningNodes()
+
+	if strings.Join(want, "\n") != strings.Join(given, "\n") {
+		t.Errorf(
+			"Expecting running nodes to match:\nwant:\n  %s\ngiven:\n  %s",
+			strings.Join(want, "\n  "),
+			strings.Join(given, "\n  "),
+		)
+	}
 }
 
 // ObserveDynSupervisor is an utility function that receives all the arguments
@@ -189,9 +261,9 @@ func ObserveDynSupervisor(
 	// once tests are done, we stop the supervisor
 	terminateErr := sup.Terminate()
 
-	// We wait till all the events have been reported (event from root must be the
-	// last event)
-	evIt.SkipTill(ProcessName(rootName))
+	// We wait till all the events have been reported (the termination event from
+	// root must be the last event)
+	evIt.SkipTill(processFinished(rootName))
 
 	if terminateErr != nil {
 		return evManager.Snapshot(), []error{terminateErr}
@@ -295,9 +367,9 @@ func ObserveSupervisorWithNotifiers(
 	// once tests are done, we stop the supervisor
 	terminateErr := sup.Terminate()
 
-	// We wait till all the events have been reported (event from root must be the
-	// last event)
-	evIt.SkipTill(ProcessName(rootName))
+	// We wait till all the events have been reported (the termination event from
+	// root must be the last event)
+	evIt.SkipTill(processFinished(rootName))
 
 	if terminateErr != nil {
 		return evManager.Snapshot(), terminateErr
@@ -0,0 +1,106 @@
+package captest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/capatazlib/go-capataz/cap"
+)
+
+// fakeTimer is a channel returned by FakeClock.After that is waiting for the
+// clock to reach its deadline
+type fakeTimer struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// FakeClock is a cap.Clock that only moves forward when the Advance method is
+// called; it allows to test time-based behaviors of a supervision tree (e.g.
+// error tolerance windows, shutdown timeouts) without real delays.
+//
+// Use cap.WithClock to give a FakeClock to a supervision tree.
+type FakeClock struct {
+	mux    *sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []fakeTimer
+}
+
+// NewFakeClock returns a FakeClock that reports the given time until it is
+// advanced
+func NewFakeClock(now time.Time) *FakeClock {
+	var mux sync.Mutex
+	return &FakeClock{
+		mux:    &mux,
+		cond:   sync.NewCond(&mux),
+		now:    now,
+		timers: make([]fakeTimer, 0),
+	}
+}
+
+// Now returns the current time of the fake clock
+func (fc *FakeClock) Now() time.Time {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	return fc.now
+}
+
+// After returns a channel that receives the current time of the fake clock
+// once the clock is advanced by the given duration (or more)
+func (fc *FakeClock) After(d time.Duration) <-chan time.Time {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- fc.now
+		return ch
+	}
+
+	fc.timers = append(fc.timers, fakeTimer{deadline: fc.now.Add(d), ch: ch})
+	fc.cond.Broadcast()
+	return ch
+}
+
+// Advance moves the time of the fake clock forward by the given duration,
+// triggering the channels returned by After that reached their deadline
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+
+	fc.now = fc.now.Add(d)
+
+	pending := fc.timers[:0:0]
+	for _, timer := range fc.timers {
+		if fc.now.Before(timer.deadline) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.ch <- fc.now
+	}
+	fc.timers = pending
+	fc.cond.Broadcast()
+}
+
+// GetPendingTimers returns the number of channels returned by After that did
+// not reach their deadline yet
+func (fc *FakeClock) GetPendingTimers() int {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	return len(fc.timers)
+}
+
+// WaitForTimers blocks until there are at least the given number of channels
+// returned by After waiting for their deadline.
+//
+// This function is useful to make sure the supervision tree is waiting on the
+// clock (e.g. a shutdown timeout) before calling Advance.
+func (fc *FakeClock) WaitForTimers(n int) {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	for len(fc.timers) < n {
+		fc.cond.Wait()
+	}
+}
+
+var _ cap.Clock = &FakeClock{}
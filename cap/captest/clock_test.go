@@ -0,0 +1,46 @@
+package captest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/capatazlib/go-capataz/cap/captest"
+)
+
+func TestFakeClockAdvance(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	afterCh := clock.After(5 * time.Second)
+	assert.Equal(t, 1, clock.GetPendingTimers())
+
+	clock.Advance(4 * time.Second)
+	select {
+	case <-afterCh:
+		t.Fatal("timer fired before its deadline")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(5*time.Second), <-afterCh)
+	assert.Equal(t, start.Add(5*time.Second), clock.Now())
+	assert.Equal(t, 0, clock.GetPendingTimers())
+
+	// timers without duration fire right away
+	assert.Equal(t, clock.Now(), <-clock.After(0))
+}
+
+func TestFakeClockWaitForTimers(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+
+	afterCh := make(chan (<-chan time.Time))
+	go func() {
+		afterCh <- clock.After(time.Minute)
+	}()
+
+	clock.WaitForTimers(1)
+	clock.Advance(time.Minute)
+	<-<-afterCh
+}
@@ -0,0 +1,54 @@
+/*
+Package captest offers utilities to test supervision trees built with the cap
+package.
+
+The supervision system emits an event for every node that starts, stops,
+terminates or fails. This package collects those events, and offers
+predicates and assertions to check they happen in the expected order.
+
+Observing a Supervision Tree
+
+ObserveSupervisor (and ObserveDynSupervisor) start a supervision tree with an
+EventManager that collects all the events of the tree. They receive a callback
+that runs once the tree is up; this is the place to trigger the side-effects
+under test (e.g. make a worker fail).
+
+Before triggering a new side-effect, wait for the events of the previous one
+with an EventIterator (SkipTill, TakeTill or WaitTill); this way, tests do not
+rely on time delays.
+
+	events, err := captest.ObserveSupervisor(
+		ctx,
+		"root",
+		cap.WithNodes(myWorker),
+		[]cap.Opt{},
+		func(em captest.EventManager) {
+			evIt := em.Iterator()
+			// trigger a failure on myWorker
+			captest.WaitForEvent(t, &evIt, captest.WorkerFailed("root/my-worker"), time.Second)
+		},
+	)
+
+Assertions
+
+Once the supervision tree is terminated, AssertExactMatch and
+AssertPartialMatch check the collected events match a list of EventP
+predicates (e.g. WorkerStarted, SupervisorTerminated). AssertRunningNodes
+checks the nodes that are running on a supervision tree at a given moment.
+
+Golden Event Logs
+
+AssertGoldenMatch compares the collected events against a golden file that was
+recorded on a previous run, ignoring timestamps and durations. Run the tests
+with the -capataz.update-golden flag to (re)generate the golden files.
+
+	captest.AssertGoldenMatch(t, events, "testdata/my_scenario.golden")
+
+Time-based Behaviors
+
+A FakeClock given to a supervision tree with cap.WithClock only moves forward
+when its Advance method is called; it allows to test error tolerance windows
+and shutdown timeouts without real delays. Use WaitForTimers to make sure the
+supervision tree is waiting on the clock before advancing it.
+*/
+package captest
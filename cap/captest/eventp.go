@@ -1,11 +1,10 @@
-package stest
+package captest
 
 import (
 	"fmt"
 	"strings"
 
 	"github.com/capatazlib/go-capataz/cap"
-	"github.com/capatazlib/go-capataz/internal/c"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -96,6 +95,31 @@ func (p AndP) String() string {
 	return strings.Join(acc, " && ")
 }
 
+// OrP is a predicate that builds the disjunction of a group EventP predicates
+// (e.g. join EventP predicates with ||)
+type OrP struct {
+	preds []EventP
+}
+
+// Call will try and verify that any of it's grouped predicates return true, if
+// all of them return false, this predicate function will return false
+func (p OrP) Call(ev cap.Event) bool {
+	for _, pred := range p.preds {
+		if pred.Call(ev) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p OrP) String() string {
+	acc := make([]string, 0, len(p.preds))
+	for _, pred := range p.preds {
+		acc = append(acc, pred.String())
+	}
+	return "(" + strings.Join(acc, " || ") + ")"
+}
+
 // ErrorMsgP is a predicate that asserts the message of an error is the one
 // specified
 type ErrorMsgP struct {
@@ -115,6 +139,22 @@ func (p ErrorMsgP) String() string {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// processFinished is a predicate to assert an event reports the termination
+// (or the failure) of the given runtime process name
+func processFinished(name string) EventP {
+	return AndP{
+		preds: []EventP{
+			ProcessNameP{name: name},
+			OrP{
+				preds: []EventP{
+					EventTagP{tag: cap.ProcessTerminated},
+					EventTagP{tag: cap.ProcessFailed},
+				},
+			},
+		},
+	}
+}
+
 // ProcessName is a predicate to assert an event was triggered by the given
 // runtime process name
 func ProcessName(name string) EventP {
@@ -128,7 +168,7 @@ func SupervisorStarted(name string) EventP {
 		preds: []EventP{
 			EventTagP{tag: cap.ProcessStarted},
 			ProcessNameP{name: name},
-			ProcessNodeTagP{nodeTag: c.Supervisor},
+			ProcessNodeTagP{nodeTag: cap.SupervisorT},
 		},
 	}
 }
@@ -140,7 +180,7 @@ func WorkerStarted(name string) EventP {
 		preds: []EventP{
 			EventTagP{tag: cap.ProcessStarted},
 			ProcessNameP{name: name},
-			ProcessNodeTagP{nodeTag: c.Worker},
+			ProcessNodeTagP{nodeTag: cap.WorkerT},
 		},
 	}
 }
@@ -152,7 +192,7 @@ func WorkerCompleted(name string) EventP {
 		preds: []EventP{
 			EventTagP{tag: cap.ProcessCompleted},
 			ProcessNameP{name: name},
-			ProcessNodeTagP{nodeTag: c.Worker},
+			ProcessNodeTagP{nodeTag: cap.WorkerT},
 		},
 	}
 }
@@ -164,7 +204,7 @@ func SupervisorTerminated(name string) EventP {
 		preds: []EventP{
 			EventTagP{tag: cap.ProcessTerminated},
 			ProcessNameP{name: name},
-			ProcessNodeTagP{nodeTag: c.Supervisor},
+			ProcessNodeTagP{nodeTag: cap.SupervisorT},
 		},
 	}
 }
@@ -176,7 +216,7 @@ func WorkerTerminated(name string) EventP {
 		preds: []EventP{
 			EventTagP{tag: cap.ProcessTerminated},
 			ProcessNameP{name: name},
-			ProcessNodeTagP{nodeTag: c.Worker},
+			ProcessNodeTagP{nodeTag: cap.WorkerT},
 		},
 	}
 }
@@ -188,7 +228,7 @@ func SupervisorFailed(name string) EventP {
 		preds: []EventP{
 			EventTagP{tag: cap.ProcessFailed},
 			ProcessNameP{name: name},
-			ProcessNodeTagP{nodeTag: c.Supervisor},
+			ProcessNodeTagP{nodeTag: cap.SupervisorT},
 		},
 	}
 }
@@ -200,7 +240,7 @@ func WorkerFailed(name string) EventP {
 		preds: []EventP{
 			EventTagP{tag: cap.ProcessFailed},
 			ProcessNameP{name: name},
-			ProcessNodeTagP{nodeTag: c.Worker},
+			ProcessNodeTagP{nodeTag: cap.WorkerT},
 		},
 	}
 }
@@ -212,7 +252,7 @@ func WorkerFailedWith(name, errMsg string) EventP {
 		preds: []EventP{
 			EventTagP{tag: cap.ProcessFailed},
 			ProcessNameP{name: name},
-			ProcessNodeTagP{nodeTag: c.Worker},
+			ProcessNodeTagP{nodeTag: cap.WorkerT},
 			ErrorMsgP{errMsg: errMsg},
 		},
 	}
@@ -225,7 +265,7 @@ func SupervisorStartFailed(name string) EventP {
 		preds: []EventP{
 			EventTagP{tag: cap.ProcessStartFailed},
 			ProcessNameP{name: name},
-			ProcessNodeTagP{nodeTag: c.Supervisor},
+			ProcessNodeTagP{nodeTag: cap.SupervisorT},
 		},
 	}
 }
@@ -237,7 +277,56 @@ func WorkerStartFailed(name string) EventP {
 		preds: []EventP{
 			EventTagP{tag: cap.ProcessStartFailed},
 			ProcessNameP{name: name},
-			ProcessNodeTagP{nodeTag: c.Worker},
+			ProcessNodeTagP{nodeTag: cap.WorkerT},
+		},
+	}
+}
+
+// WorkerLeakFinished is a predicate to assert an event represents a process
+// that finished after it did not stop within its shutdown timeout
+func WorkerLeakFinished(name string) EventP {
+	return AndP{
+		preds: []EventP{
+			EventTagP{tag: cap.ProcessLeakFinished},
+			ProcessNameP{name: name},
+			ProcessNodeTagP{nodeTag: cap.WorkerT},
+		},
+	}
+}
+
+// SupervisorLeakFinished is a predicate to assert an event represents a
+// supervisor that finished after it did not stop within its shutdown timeout
+func SupervisorLeakFinished(name string) EventP {
+	return AndP{
+		preds: []EventP{
+			EventTagP{tag: cap.ProcessLeakFinished},
+			ProcessNameP{name: name},
+			ProcessNodeTagP{nodeTag: cap.SupervisorT},
+		},
+	}
+}
+
+// SupervisorShutdownDeadlineReached is a predicate to assert an event
+// represents a supervisor that did not terminate its children before its
+// shutdown deadline
+func SupervisorShutdownDeadlineReached(name string) EventP {
+	return AndP{
+		preds: []EventP{
+			EventTagP{tag: cap.ProcessShutdownDeadlineReached},
+			ProcessNameP{name: name},
+			ProcessNodeTagP{nodeTag: cap.SupervisorT},
+		},
+	}
+}
+
+// WorkerFaultInjected is a predicate to assert an event represents a fault
+// that got injected on a worker on purpose
+func WorkerFaultInjected(name string) EventP {
+	return AndP{
+		preds: []EventP{
+			EventTagP{tag: cap.ProcessFaultInjected},
+			ProcessNameP{name: name},
+			ProcessNodeTagP{nodeTag: cap.WorkerT},
 		},
 	}
 }
@@ -1,8 +1,10 @@
-package stest
+package captest
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/capatazlib/go-capataz/cap"
 )
@@ -106,7 +108,6 @@ func (ei *EventIterator) foldl(
 
 // SkipTill blocks until an event from the supervision system returns true for
 // the given predicate
-// TODO: Change name to WaitTill
 func (ei *EventIterator) SkipTill(pred EventP) {
 	_ = ei.foldl(nil, func(_ interface{}, ev cap.Event) (bool, interface{}) {
 		if pred.Call(ev) {
@@ -116,6 +117,30 @@ func (ei *EventIterator) SkipTill(pred EventP) {
 	})
 }
 
+// WaitTill blocks until an event from the supervision system returns true for
+// the given predicate, or until the given timeout is reached. It returns the
+// event that matched the predicate, or an error if the timeout was reached
+// first.
+func (ei *EventIterator) WaitTill(pred EventP, timeout time.Duration) (cap.Event, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		ev, ok := ei.evManager.getEventIxUntil(ei.evIx, deadline)
+		if !ok {
+			return cap.Event{}, fmt.Errorf(
+				"Event did not happen after %v\ncriteria: %s",
+				timeout,
+				pred.String(),
+			)
+		}
+
+		ei.evIx++
+
+		if pred.Call(ev) {
+			return ev, nil
+		}
+	}
+}
+
 // TakeTill takes all the events that have been collected since the current
 // index until the given predicate returns true
 func (ei *EventIterator) TakeTill(pred EventP) []cap.Event {
@@ -171,6 +196,36 @@ func (em EventManager) GetEventIx(evIx int) (cap.Event, bool) {
 	return (*em.evBuffer)[evIx], true
 }
 
+// getEventIxUntil works like GetEventIx, but it stops waiting for the nth
+// event once the given deadline is reached. If the index is not reached, the
+// second return value will be false.
+func (em EventManager) getEventIxUntil(evIx int, deadline time.Time) (cap.Event, bool) {
+	// the timer wakes up the waiting goroutine when the deadline is reached; it
+	// needs the lock to broadcast, so it cannot run between our deadline check
+	// and the call to Wait
+	timer := time.AfterFunc(time.Until(deadline), func() {
+		em.evBufferCond.L.Lock()
+		defer em.evBufferCond.L.Unlock()
+		em.evBufferCond.Broadcast()
+	})
+	defer timer.Stop()
+
+	em.evBufferCond.L.Lock()
+	defer em.evBufferCond.L.Unlock()
+
+	for evIx >= len(*em.evBuffer) && !em.evDone {
+		if !time.Now().Before(deadline) {
+			return cap.Event{}, false
+		}
+		em.evBufferCond.Wait()
+	}
+
+	if evIx >= len(*em.evBuffer) {
+		return cap.Event{}, false
+	}
+	return (*em.evBuffer)[evIx], true
+}
+
 // NewEventManager returns an EventManager instance that can be used to wait for
 // events to happen on the observed supervision system
 func NewEventManager() EventManager {
@@ -0,0 +1,89 @@
+package captest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+)
+
+// signalWorker creates a worker that fails when the returned function is
+// called
+func signalWorker(name string) (cap.Node, func()) {
+	failCh := make(chan struct{})
+	node := cap.NewWorker(name, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-failCh:
+			return errors.New("signal worker failed")
+		}
+	}, cap.WithRestart(cap.Temporary))
+	return node, func() { close(failCh) }
+}
+
+func TestWaitTill(t *testing.T) {
+	worker, failWorker := signalWorker("child0")
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(worker),
+		[]cap.Opt{},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			ev := WaitForEvent(t, &evIt, SupervisorStarted("root"), time.Second)
+			assert.Equal(t, "root", ev.GetProcessRuntimeName())
+
+			// the event did not happen yet
+			_, waitErr := evIt.WaitTill(WorkerFailed("root/child0"), 10*time.Millisecond)
+			assert.Error(t, waitErr)
+
+			failWorker()
+			ev = WaitForEvent(t, &evIt, WorkerFailed("root/child0"), time.Second)
+			assert.Equal(t, cap.ProcessFailed, ev.GetTag())
+		},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			SupervisorStarted("root"),
+			WorkerFailed("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestAssertRunningNodes(t *testing.T) {
+	worker0, failWorker0 := signalWorker("child0")
+	worker1, _ := signalWorker("child1")
+
+	evManager := NewEventManager()
+	evManager.StartCollector(context.TODO())
+
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(worker0, worker1),
+		cap.WithNotifier(evManager.EventCollector(context.TODO())),
+	).Start(context.TODO())
+	assert.NoError(t, err)
+
+	AssertRunningNodes(t, sup, []string{"root/child1", "root", "root/child0"})
+
+	evIt := evManager.Iterator()
+	failWorker0()
+	WaitForEvent(t, &evIt, WorkerFailed("root/child0"), time.Second)
+
+	AssertRunningNodes(t, sup, []string{"root", "root/child1"})
+
+	assert.NoError(t, sup.Terminate())
+	AssertRunningNodes(t, sup, []string{})
+}
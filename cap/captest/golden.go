@@ -0,0 +1,317 @@
+package captest
+
+// This file contains the golden mode of the event assertions; it records the
+// events of a supervision scenario to a file, and compares later runs against
+// it.
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/capatazlib/go-capataz/cap"
+)
+
+// UpdateGoldenFlag is the name of the command line flag that makes
+// AssertGoldenMatch (and VerifyGoldenMatch) write the golden files instead of
+// comparing against them.
+//
+// Example:
+//
+//   go test ./mypackage -capataz.update-golden
+//
+const UpdateGoldenFlag = "capataz.update-golden"
+
+var updateGolden = flag.Bool(
+	UpdateGoldenFlag,
+	false,
+	"write the golden event logs of captest instead of comparing against them",
+)
+
+// goldenHeader is the first line of every golden file
+const goldenHeader = "# capataz golden event log; regenerate with -" + UpdateGoldenFlag
+
+// renderGoldenEvent returns the golden representation of the given event; it
+// does not contain timestamps nor durations, so that it is stable across runs
+func renderGoldenEvent(ev cap.Event) string {
+	line := fmt.Sprintf("%s %s %s", ev.GetTag(), ev.GetNodeTag(), ev.GetProcessRuntimeName())
+	if ev.Err() != nil {
+		line = fmt.Sprintf("%s err=%q", line, ev.Err().Error())
+	}
+	return line
+}
+
+// RenderGolden returns the golden event log of the given events, one line per
+// event. Check AssertGoldenMatch for details.
+func RenderGolden(evs []cap.Event) string {
+	var builder strings.Builder
+	builder.WriteString(goldenHeader)
+	builder.WriteString("\n")
+	for _, ev := range evs {
+		builder.WriteString(renderGoldenEvent(ev))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// parseGolden returns the event lines of the given golden event log, comments
+// and empty lines are skipped
+func parseGolden(input string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// goldenRuntimeName returns the runtime name of the process of the given
+// golden line
+func goldenRuntimeName(line string) string {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) < 3 {
+		return ""
+	}
+	return fields[2]
+}
+
+// goldenGroup returns the runtime name of the supervisor that reported the
+// event of the given golden line; the root supervisor belongs to the group
+// with an empty name
+func goldenGroup(line string) string {
+	runtimeName := goldenRuntimeName(line)
+	if runtimeName == "" {
+		return ""
+	}
+	group := path.Dir(runtimeName)
+	if group == "." {
+		return ""
+	}
+	return group
+}
+
+// groupGoldenLines splits the given golden lines by the supervisor that
+// reported them, keeping their order; it also returns the groups in the order
+// they first appear
+func groupGoldenLines(lines []string) (map[string][]string, []string) {
+	groups := make(map[string][]string)
+	order := []string{}
+	for _, line := range lines {
+		group := goldenGroup(line)
+		if _, ok := groups[group]; !ok {
+			order = append(order, group)
+		}
+		groups[group] = append(groups[group], line)
+	}
+	return groups, order
+}
+
+// causalGoldenLines returns, for every supervisor of the given golden lines,
+// its own lines merged with the lines of its descendants; the descendant lines
+// found between two lines of the supervisor are sorted, given they may happen
+// in any order (their order is checked on their own groups). It also returns
+// the supervisors in the order they first appear.
+//
+// This way, the position of the events of a supervisor relative to the events
+// of its descendants gets checked (e.g. a sub-tree reports it started after
+// all its children started).
+func causalGoldenLines(lines []string) (map[string][]string, []string) {
+	// supervisors are the nodes that have descendants
+	supervisors := make(map[string]bool)
+	for _, line := range lines {
+		if group := goldenGroup(line); group != "" {
+			supervisors[group] = true
+		}
+	}
+
+	order := []string{}
+	for _, line := range lines {
+		runtimeName := goldenRuntimeName(line)
+		if supervisors[runtimeName] {
+			order = append(order, runtimeName)
+			// every supervisor is added once
+			supervisors[runtimeName] = false
+		}
+	}
+
+	causal := make(map[string][]string)
+
+	for _, supName := range order {
+		result := []string{}
+		segment := []string{}
+		for _, line := range lines {
+			runtimeName := goldenRuntimeName(line)
+			if runtimeName == supName {
+				sort.Strings(segment)
+				result = append(append(result, segment...), line)
+				segment = []string{}
+			} else if strings.HasPrefix(runtimeName, supName+"/") {
+				segment = append(segment, line)
+			}
+		}
+		sort.Strings(segment)
+		causal[supName] = append(result, segment...)
+	}
+	return causal, order
+}
+
+// diffLines returns a readable diff between the wanted and given lines, where
+// removed lines have a `-` prefix and added lines a `+` prefix
+func diffLines(want, given []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of want[i:]
+	// and given[j:]
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(given)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(given) - 1; j >= 0; j-- {
+			if want[i] == given[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var builder strings.Builder
+	i, j := 0, 0
+	for i < len(want) || j < len(given) {
+		switch {
+		case i < len(want) && j < len(given) && want[i] == given[j]:
+			builder.WriteString(fmt.Sprintf("    %s\n", want[i]))
+			i++
+			j++
+		case j < len(given) && (i == len(want) || lcs[i][j+1] > lcs[i+1][j]):
+			builder.WriteString(fmt.Sprintf("  + %s\n", given[j]))
+			j++
+		default:
+			builder.WriteString(fmt.Sprintf("  - %s\n", want[i]))
+			i++
+		}
+	}
+	return builder.String()
+}
+
+// verifyGoldenLines compares the given golden lines against the wanted ones.
+// Lines reported by different supervisors may interleave in any order, but the
+// lines reported by the same supervisor must be in the same order, and the
+// lines of a supervisor must be in the same position relative to the lines of
+// its descendants.
+func verifyGoldenLines(want, given []string) error {
+	wantGroups, wantOrder := groupGoldenLines(want)
+	givenGroups, givenOrder := groupGoldenLines(given)
+
+	// we report the groups that are only on the given lines at the end
+	for _, group := range givenOrder {
+		if _, ok := wantGroups[group]; !ok {
+			wantOrder = append(wantOrder, group)
+		}
+	}
+
+	var builder strings.Builder
+	for _, group := range wantOrder {
+		wantLines, givenLines := wantGroups[group], givenGroups[group]
+		if strings.Join(wantLines, "\n") == strings.Join(givenLines, "\n") {
+			continue
+		}
+		name := group
+		if name == "" {
+			name = "<root>"
+		}
+		builder.WriteString(fmt.Sprintf("events reported by %s:\n", name))
+		builder.WriteString(diffLines(wantLines, givenLines))
+	}
+
+	// the group diffs do not show the order of a supervisor's events relative
+	// to the ones of its descendants, so it is reported on its own
+	wantCausal, wantSups := causalGoldenLines(want)
+	givenCausal, givenSups := causalGoldenLines(given)
+	for _, supName := range givenSups {
+		if _, ok := wantCausal[supName]; !ok {
+			wantSups = append(wantSups, supName)
+		}
+	}
+	for _, supName := range wantSups {
+		wantLines, givenLines := wantCausal[supName], givenCausal[supName]
+		if strings.Join(wantLines, "\n") == strings.Join(givenLines, "\n") {
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("events of %s and its descendants:\n", supName))
+		builder.WriteString(diffLines(wantLines, givenLines))
+	}
+
+	if builder.Len() > 0 {
+		return fmt.Errorf(
+			"Expecting golden match, but events differ (- golden, + given):\n%s",
+			builder.String(),
+		)
+	}
+	return nil
+}
+
+// VerifyGoldenMatch compares the given events against the golden event log
+// stored in the given file (check AssertGoldenMatch for details); it returns
+// an error with a diff of the mismatches.
+//
+// When the test binary runs with the UpdateGoldenFlag, the golden file gets
+// written with the given events instead.
+func VerifyGoldenMatch(evs []cap.Event, goldenPath string) error {
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(goldenPath, []byte(RenderGolden(evs)), 0644)
+	}
+
+	input, err := ioutil.ReadFile(goldenPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf(
+			"golden file %s does not exist, run the test with -%s to create it",
+			goldenPath,
+			UpdateGoldenFlag,
+		)
+	} else if err != nil {
+		return err
+	}
+
+	return verifyGoldenLines(parseGolden(string(input)), parseGolden(RenderGolden(evs)))
+}
+
+// AssertGoldenMatch is an assertion that compares the given events against the
+// golden event log stored in the given file (e.g. testdata/my_test.golden).
+//
+// A golden event log contains one line per event, with its EventTag, its
+// NodeTag, the runtime name of the process and its error message (if any);
+// timestamps and durations are ignored, so that runs can be compared.
+//
+// The events reported by the nodes of different supervisors (e.g. the nodes
+// of sibling sub-trees) may happen in a different order on every run, this
+// assertion only checks the order of the events reported by the nodes of the
+// same supervisor, and the order of the events of every supervisor relative to
+// the events of its descendants (e.g. a sub-tree reports it terminated after
+// all its children terminated). Note that supervisors with a
+// WithParallelStart or a WithParallelTermination setting report the events of
+// their children in a different order on every run.
+//
+// Run the test with the UpdateGoldenFlag to write the golden file with the
+// events of the current run; review the result before committing it.
+func AssertGoldenMatch(t *testing.T, evs []cap.Event, goldenPath string) {
+	t.Helper()
+	err := VerifyGoldenMatch(evs, goldenPath)
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,182 @@
+package captest_test
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// observeGoldenScenario runs a supervision tree with two sub-trees, where a
+// worker of the first sub-tree fails once
+func observeGoldenScenario(t *testing.T) []cap.Event {
+	failingNode, failWorker := FailOnSignalWorker(1, "w0")
+
+	b0 := cap.NewSupervisorSpec("b0", cap.WithNodes(failingNode, WaitDoneWorker("w1")))
+	b1 := cap.NewSupervisorSpec("b1", cap.WithNodes(WaitDoneWorker("w0")))
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(cap.Subtree(b0), cap.Subtree(b1)),
+		[]cap.Opt{},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			WaitForEvent(t, &evIt, SupervisorStarted("root"), time.Second)
+			failWorker(false /* done */)
+			WaitForEvent(t, &evIt, WorkerStarted("root/b0/w0"), time.Second)
+			failWorker(true /* done */)
+		},
+	)
+	assert.NoError(t, err)
+	return events
+}
+
+// tempGoldenDir creates a temporary directory for golden files, the returned
+// function removes it
+func tempGoldenDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "captest-golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { _ = os.RemoveAll(dir) }
+}
+
+func TestGoldenMatch(t *testing.T) {
+	events := observeGoldenScenario(t)
+	AssertGoldenMatch(t, events, filepath.Join("testdata", "golden_scenario.golden"))
+}
+
+func TestGoldenMatchAllowsInterleavedSubtrees(t *testing.T) {
+	events := observeGoldenScenario(t)
+
+	// the children of the sub-tree b1 start before the ones of the sub-tree b0
+	// on the golden file
+	isMoved := func(line string) bool {
+		return strings.HasPrefix(line, "ProcessStarted") && strings.Contains(line, "root/b1/")
+	}
+	lines := strings.Split(RenderGolden(events), "\n")
+	reordered := []string{}
+	for _, line := range lines {
+		if isMoved(line) {
+			reordered = append(reordered, line)
+		}
+	}
+	for _, line := range lines {
+		if !isMoved(line) {
+			reordered = append(reordered, line)
+		}
+	}
+
+	dir, cleanup := tempGoldenDir(t)
+	defer cleanup()
+
+	goldenPath := filepath.Join(dir, "reordered.golden")
+	err := ioutil.WriteFile(goldenPath, []byte(strings.Join(reordered, "\n")), 0644)
+	assert.NoError(t, err)
+
+	assert.NoError(t, VerifyGoldenMatch(events, goldenPath))
+}
+
+func TestGoldenMismatch(t *testing.T) {
+	events := observeGoldenScenario(t)
+
+	// the golden file expects the failure on a different worker
+	golden := strings.Replace(
+		RenderGolden(events),
+		"ProcessFailed Worker root/b0/w0",
+		"ProcessFailed Worker root/b0/w1",
+		1,
+	)
+	dir, cleanup := tempGoldenDir(t)
+	defer cleanup()
+
+	goldenPath := filepath.Join(dir, "mismatch.golden")
+	err := ioutil.WriteFile(goldenPath, []byte(golden), 0644)
+	assert.NoError(t, err)
+
+	err = VerifyGoldenMatch(events, goldenPath)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "events reported by root/b0:")
+		assert.Contains(t, err.Error(), `  - ProcessFailed Worker root/b0/w1 err="Failing child (1 out of 1)"`)
+		assert.Contains(t, err.Error(), `  + ProcessFailed Worker root/b0/w0 err="Failing child (1 out of 1)"`)
+		// the events of other supervisors are not reported
+		assert.NotContains(t, err.Error(), "events reported by root/b1:")
+	}
+}
+
+func TestGoldenMismatchSubtreeOrder(t *testing.T) {
+	events := observeGoldenScenario(t)
+
+	// the golden file expects the sub-tree b0 to report it started before its
+	// children; the order of the events reported by each supervisor is the same
+	lines := strings.Split(RenderGolden(events), "\n")
+	reordered := []string{}
+	for _, line := range lines {
+		if line == "ProcessStarted Supervisor root/b0" {
+			reordered = append(reordered, line)
+		}
+	}
+	for _, line := range lines {
+		if line != "ProcessStarted Supervisor root/b0" {
+			reordered = append(reordered, line)
+		}
+	}
+
+	dir, cleanup := tempGoldenDir(t)
+	defer cleanup()
+
+	goldenPath := filepath.Join(dir, "subtree_order.golden")
+	err := ioutil.WriteFile(goldenPath, []byte(strings.Join(reordered, "\n")), 0644)
+	assert.NoError(t, err)
+
+	err = VerifyGoldenMatch(events, goldenPath)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "events of root/b0 and its descendants:")
+		// the children of the sub-tree start before it reports it started
+		assert.Contains(t, err.Error(), "  + ProcessStarted Worker root/b0/w1\n    ProcessStarted Supervisor root/b0")
+		assert.Contains(t, err.Error(), "  - ProcessStarted Worker root/b0/w1\n    ProcessTerminated Worker root/b0/w0")
+		assert.NotContains(t, err.Error(), "events reported by root:")
+		assert.NotContains(t, err.Error(), "events of root/b1 and its descendants:")
+	}
+}
+
+func TestGoldenMissingFile(t *testing.T) {
+	events := observeGoldenScenario(t)
+
+	dir, cleanup := tempGoldenDir(t)
+	defer cleanup()
+
+	err := VerifyGoldenMatch(events, filepath.Join(dir, "missing.golden"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), UpdateGoldenFlag)
+	}
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	events := observeGoldenScenario(t)
+
+	assert.NoError(t, flag.Set(UpdateGoldenFlag, "true"))
+	defer func() { _ = flag.Set(UpdateGoldenFlag, "false") }()
+
+	dir, cleanup := tempGoldenDir(t)
+	defer cleanup()
+
+	goldenPath := filepath.Join(dir, "nested", "updated.golden")
+	assert.NoError(t, VerifyGoldenMatch(events, goldenPath))
+
+	input, err := ioutil.ReadFile(goldenPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, RenderGolden(events), string(input))
+	}
+}
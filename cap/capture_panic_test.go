@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
 	. "github.com/capatazlib/go-capataz/internal/stest"
 	"github.com/stretchr/testify/assert"
 )
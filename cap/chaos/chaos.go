@@ -0,0 +1,412 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/capatazlib/go-capataz/cap"
+	"github.com/capatazlib/go-capataz/internal/c"
+)
+
+// Fault specifies the kind of fault that gets injected on a node
+type Fault uint32
+
+const (
+	// Failure is a Fault that makes the node return an InjectedError
+	Failure Fault = iota
+	// Panic is a Fault that makes the node panic with an InjectedError
+	Panic
+	// StartDelay is a Fault that makes the node take longer to start
+	StartDelay
+	// IgnoreCancel is a Fault that makes the node keep running for a while
+	// after its context is done
+	IgnoreCancel
+)
+
+// String returns a string representation of the current Fault
+func (f Fault) String() string {
+	switch f {
+	case Failure:
+		return "Failure"
+	case Panic:
+		return "Panic"
+	case StartDelay:
+		return "StartDelay"
+	case IgnoreCancel:
+		return "IgnoreCancel"
+	default:
+		return "<Unknown>"
+	}
+}
+
+// InjectedError is the error that describes a fault injected on a node. It is
+// reported on ProcessFaultInjected events, and it is the error returned (or the
+// value given to panic) by the nodes that get a Failure or a Panic fault.
+type InjectedError struct {
+	fault    Fault
+	nodeName string
+	duration time.Duration
+}
+
+// GetFault returns the kind of Fault that got injected
+func (err *InjectedError) GetFault() Fault {
+	return err.fault
+}
+
+// GetNodeName returns the name of the node that got the fault injected
+func (err *InjectedError) GetNodeName() string {
+	return err.nodeName
+}
+
+// GetDuration returns the duration of the fault; the time the node ran before
+// it failed or panicked, the start delay, or the time the node ignores its
+// cancellation
+func (err *InjectedError) GetDuration() time.Duration {
+	return err.duration
+}
+
+// KVs returns a data bag map that may be used in structured logging
+func (err *InjectedError) KVs() map[string]interface{} {
+	kvs := make(map[string]interface{})
+	kvs["node.name"] = err.nodeName
+	kvs["chaos.fault"] = err.fault.String()
+	kvs["chaos.duration"] = err.duration
+	return kvs
+}
+
+func (err *InjectedError) Error() string {
+	return fmt.Sprintf(
+		"chaos: injected %s fault on node %s (%v)",
+		err.fault,
+		err.nodeName,
+		err.duration,
+	)
+}
+
+// faultSetting specifies how often a fault gets injected, and its duration
+type faultSetting struct {
+	probability float64
+	duration    time.Duration
+}
+
+// PolicyOpt is a type used to configure a Policy
+type PolicyOpt func(*Policy)
+
+// Policy specifies the faults that get injected on the nodes it is applied to.
+// Check the documentation of NewPolicy for more details.
+type Policy struct {
+	seed     int64
+	settings map[Fault]faultSetting
+
+	mux   *sync.Mutex
+	rands map[string]*rand.Rand
+}
+
+// NewPolicy creates a Policy with the given options. A Policy without options
+// does not inject any fault.
+//
+// Every time a node the policy is applied to starts, the policy decides which
+// faults are injected on that run, using the probability given on each fault
+// option. The Failure and Panic faults are mutually exclusive, when both are
+// chosen, only the Failure gets injected.
+//
+func NewPolicy(opts ...PolicyOpt) *Policy {
+	var mux sync.Mutex
+
+	policy := &Policy{
+		seed:     time.Now().UnixNano(),
+		settings: make(map[Fault]faultSetting),
+		mux:      &mux,
+		rands:    make(map[string]*rand.Rand),
+	}
+	for _, optFn := range opts {
+		optFn(policy)
+	}
+	return policy
+}
+
+// checkProbability panics if the given probability is not between 0 and 1
+func checkProbability(fault Fault, probability float64) {
+	if probability < 0 || probability > 1 {
+		panic(fmt.Sprintf("invalid %s probability %v", fault, probability))
+	}
+}
+
+// withFault returns a PolicyOpt that registers the given fault setting
+func withFault(fault Fault, probability float64, d time.Duration) PolicyOpt {
+	checkProbability(fault, probability)
+	return func(policy *Policy) {
+		policy.settings[fault] = faultSetting{probability: probability, duration: d}
+	}
+}
+
+// WithSeed is a PolicyOpt that specifies the seed of the random number
+// generator used to decide which faults get injected. Policies with the same
+// seed inject the same sequence of faults on nodes with the same runtime name
+// (e.g. root/api/db); each node of the tree gets its own sequence, which
+// continues when the node (or its supervisor) gets restarted.
+//
+// By default, the seed is the creation time of the policy.
+//
+func WithSeed(seed int64) PolicyOpt {
+	return func(policy *Policy) {
+		policy.seed = seed
+	}
+}
+
+// WithFailure is a PolicyOpt that makes nodes fail with an InjectedError with
+// the given probability. The failure happens once the node has been running
+// for the given duration (counted from the moment it notifies its start); the
+// node context gets cancelled and the node is waited before the error is
+// returned.
+//
+// The given probability must be between 0 and 1, otherwise, the system will
+// panic.
+//
+func WithFailure(probability float64, after time.Duration) PolicyOpt {
+	return withFault(Failure, probability, after)
+}
+
+// WithPanic is a PolicyOpt that works like WithFailure, but nodes panic with
+// the InjectedError instead of returning it.
+//
+// The given probability must be between 0 and 1, otherwise, the system will
+// panic.
+//
+func WithPanic(probability float64, after time.Duration) PolicyOpt {
+	return withFault(Panic, probability, after)
+}
+
+// WithStartDelay is a PolicyOpt that makes nodes wait the given duration before
+// they get started with the given probability.
+//
+// The given probability must be between 0 and 1, otherwise, the system will
+// panic.
+//
+func WithStartDelay(probability float64, delay time.Duration) PolicyOpt {
+	return withFault(StartDelay, probability, delay)
+}
+
+// WithIgnoredCancel is a PolicyOpt that makes nodes ignore the cancellation
+// of their context for the given duration with the given probability. Use a
+// duration longer than the shutdown timeout of a node to make it leak.
+//
+// The given probability must be between 0 and 1, otherwise, the system will
+// panic.
+//
+func WithIgnoredCancel(probability float64, d time.Duration) PolicyOpt {
+	return withFault(IgnoreCancel, probability, d)
+}
+
+// roll returns the faults that get injected on a single run of the node with
+// the given runtime name, with their duration
+func (policy *Policy) roll(runtimeName string) map[Fault]time.Duration {
+	policy.mux.Lock()
+	defer policy.mux.Unlock()
+
+	rng, ok := policy.rands[runtimeName]
+	if !ok {
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(runtimeName))
+		rng = rand.New(rand.NewSource(policy.seed ^ int64(hash.Sum64())))
+		policy.rands[runtimeName] = rng
+	}
+
+	faults := make(map[Fault]time.Duration)
+	// faults are always rolled in the same order to keep the runs reproducible
+	for _, fault := range []Fault{StartDelay, IgnoreCancel, Failure, Panic} {
+		// every fault consumes a number, this way, the settings of a fault do
+		// not change the decisions on the other faults
+		n := rng.Float64()
+		setting, ok := policy.settings[fault]
+		if !ok || n >= setting.probability {
+			continue
+		}
+		faults[fault] = setting.duration
+	}
+	if _, ok := faults[Failure]; ok {
+		delete(faults, Panic)
+	}
+	return faults
+}
+
+// inject wraps the start function of the given child spec, so that it runs
+// with the faults of this policy; the faults are reported on the event system
+// of the supervisor
+func (policy *Policy) inject(chSpec *c.ChildSpec) {
+	cap.WithFaultReporting(true)(chSpec)
+	nodeName := chSpec.GetName()
+	startFn := chSpec.Start
+
+	chSpec.Start = func(ctx context.Context, notifyStart c.NotifyStartFn) error {
+		// every node of the tree gets its own sequence of faults, even when
+		// nodes of different sub-trees have the same name
+		runtimeName := cap.GetFaultRuntimeName(ctx)
+		if runtimeName == "" {
+			runtimeName = nodeName
+		}
+		return runWithFaults(ctx, nodeName, policy.roll(runtimeName), startFn, notifyStart)
+	}
+}
+
+// Wrap returns a Node that runs the given node with the faults of the given
+// policy.
+//
+// The given node may be a worker or a sub-tree; on sub-trees, the faults are
+// injected on the supervisor of the sub-tree, not on its children (check
+// WithPolicy).
+//
+func Wrap(node cap.Node, policy *Policy) cap.Node {
+	return func(spec cap.SupervisorSpec) c.ChildSpec {
+		chSpec := node(spec)
+		policy.inject(&chSpec)
+		return chSpec
+	}
+}
+
+// WithPolicy is an Opt that applies the given policy to every descendant node
+// with a runtime name that matches the given pattern. The pattern follows the
+// same syntax of cap.WithNodeOverride (on top of which this option is built),
+// nodes the policy is applied to are reported by the GetNodeOverrideMatches
+// method of cap.Supervisor.
+//
+// The given pattern must be valid, otherwise, the system will panic.
+//
+func WithPolicy(pattern string, policy *Policy) cap.Opt {
+	return cap.WithNodeOverride(pattern, policy.inject)
+}
+
+// notify reports the given fault on the event system
+func notify(ctx context.Context, nodeName string, fault Fault, d time.Duration) *InjectedError {
+	err := &InjectedError{fault: fault, nodeName: nodeName, duration: d}
+	cap.NotifyInjectedFault(ctx, err)
+	return err
+}
+
+// runWithFaults executes the given start function with the given faults
+func runWithFaults(
+	ctx context.Context,
+	nodeName string,
+	faults map[Fault]time.Duration,
+	startFn func(context.Context, c.NotifyStartFn) error,
+	notifyStart c.NotifyStartFn,
+) error {
+	// delays follow the clock of the supervision tree (check cap.WithClock)
+	clock := cap.GetFaultClock(ctx)
+
+	if delay, ok := faults[StartDelay]; ok {
+		notify(ctx, nodeName, StartDelay, delay)
+		select {
+		case <-clock.After(delay):
+		case <-ctx.Done():
+		}
+	}
+
+	runCtx := ctx
+	if d, ok := faults[IgnoreCancel]; ok {
+		notify(ctx, nodeName, IgnoreCancel, d)
+		var cancelFn func()
+		runCtx, cancelFn = ignoreCancel(ctx, clock, d)
+		defer cancelFn()
+	}
+
+	fault, after, ok := pickFailFault(faults)
+	if !ok {
+		return startFn(runCtx, notifyStart)
+	}
+
+	runCtx, cancelFn := context.WithCancel(runCtx)
+	defer cancelFn()
+
+	// the node runs on its own goroutine, so that it can be cancelled once the
+	// fault gets injected
+	startedCh := make(chan struct{})
+	resultCh := make(chan func() error, 1)
+	go func() {
+		var err error
+		defer func() {
+			if panicVal := recover(); panicVal != nil {
+				// panics must happen on the goroutine of the node
+				resultCh <- func() error { panic(panicVal) }
+				return
+			}
+			resultCh <- func() error { return err }
+		}()
+		err = startFn(runCtx, func(startErr error) {
+			notifyStart(startErr)
+			if startErr == nil {
+				close(startedCh)
+			}
+		})
+	}()
+
+	select {
+	case <-startedCh:
+	case result := <-resultCh:
+		return result()
+	}
+
+	select {
+	case <-clock.After(after):
+	case result := <-resultCh:
+		return result()
+	}
+
+	injectedErr := notify(ctx, nodeName, fault, after)
+	cancelFn()
+	// the result of the node is discarded, but its panics are not
+	_ = (<-resultCh)()
+
+	if fault == Panic {
+		panic(injectedErr)
+	}
+	return injectedErr
+}
+
+// pickFailFault returns the Failure or Panic fault that must be injected (if
+// any)
+func pickFailFault(faults map[Fault]time.Duration) (Fault, time.Duration, bool) {
+	for _, fault := range []Fault{Failure, Panic} {
+		if d, ok := faults[fault]; ok {
+			return fault, d, true
+		}
+	}
+	return Failure, 0, false
+}
+
+// detachedContext is a context that keeps the values of its parent context,
+// but it never gets done
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// ignoreCancel returns a context that gets done the given duration (measured
+// with the given clock) after the given context is done
+func ignoreCancel(
+	ctx context.Context,
+	clock cap.Clock,
+	d time.Duration,
+) (context.Context, func()) {
+	runCtx, cancelFn := context.WithCancel(detachedContext{ctx})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-runCtx.Done():
+			return
+		}
+		select {
+		case <-clock.After(d):
+			cancelFn()
+		case <-runCtx.Done():
+		}
+	}()
+	return runCtx, cancelFn
+}
@@ -0,0 +1,43 @@
+package chaos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rollMany returns the faults the given policy injects on the given number of
+// runs of the node with the given name
+func rollMany(policy *Policy, nodeName string, runs int) []map[Fault]time.Duration {
+	results := make([]map[Fault]time.Duration, 0, runs)
+	for i := 0; i < runs; i++ {
+		results = append(results, policy.roll(nodeName))
+	}
+	return results
+}
+
+func TestRollIsReproducibleWithSeed(t *testing.T) {
+	newPolicy := func() *Policy {
+		return NewPolicy(
+			WithSeed(42),
+			WithFailure(0.5, time.Second),
+			WithStartDelay(0.5, time.Millisecond),
+		)
+	}
+
+	policy0, policy1 := newPolicy(), newPolicy()
+
+	// the sequence of a node does not depend on the runs of other nodes
+	_ = rollMany(policy1, "other", 10)
+
+	assert.Equal(t, rollMany(policy0, "worker", 50), rollMany(policy1, "worker", 50))
+}
+
+func TestRollFailureExcludesPanic(t *testing.T) {
+	policy := NewPolicy(WithFailure(1, time.Second), WithPanic(1, time.Second))
+
+	faults := policy.roll("worker")
+	assert.Contains(t, faults, Failure)
+	assert.NotContains(t, faults, Panic)
+}
@@ -0,0 +1,272 @@
+package chaos_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	"github.com/capatazlib/go-capataz/cap/chaos"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// assertInjectedFault asserts the given error is an InjectedError of the given
+// fault
+func assertInjectedFault(t *testing.T, err error, fault chaos.Fault) {
+	var injectedErr *chaos.InjectedError
+	if assert.True(t, errors.As(err, &injectedErr), "expected injected error, got %v", err) {
+		assert.Equal(t, fault, injectedErr.GetFault())
+	}
+}
+
+func TestWrapInjectsFailure(t *testing.T) {
+	policy := chaos.NewPolicy(chaos.WithFailure(1, 10*time.Millisecond))
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			chaos.Wrap(WaitDoneWorker("child0", cap.WithRestart(cap.Temporary)), policy),
+		),
+		[]cap.Opt{},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			WaitForEvent(t, &evIt, WorkerFailed("root/child0"), time.Second)
+		},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			SupervisorStarted("root"),
+			WorkerFaultInjected("root/child0"),
+			WorkerFailed("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+
+	assertInjectedFault(t, events[2].Err(), chaos.Failure)
+	assertInjectedFault(t, events[3].Err(), chaos.Failure)
+}
+
+func TestWithPolicyInjectsPanic(t *testing.T) {
+	policy := chaos.NewPolicy(chaos.WithPanic(1, 10*time.Millisecond))
+
+	b0 := cap.NewSupervisorSpec(
+		"branch0",
+		cap.WithNodes(
+			WaitDoneWorker("child0", cap.WithRestart(cap.Temporary)),
+			WaitDoneWorker("child1"),
+		),
+	)
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(cap.Subtree(b0)),
+		[]cap.Opt{chaos.WithPolicy("root/branch0/child0", policy)},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			WaitForEvent(t, &evIt, WorkerFailed("root/branch0/child0"), time.Second)
+		},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/branch0/child0"),
+			WorkerStarted("root/branch0/child1"),
+			SupervisorStarted("root/branch0"),
+			SupervisorStarted("root"),
+			WorkerFaultInjected("root/branch0/child0"),
+			WorkerFailed("root/branch0/child0"),
+			WorkerTerminated("root/branch0/child1"),
+			SupervisorTerminated("root/branch0"),
+			SupervisorTerminated("root"),
+		},
+	)
+
+	// the panic is captured by the supervisor
+	assertInjectedFault(t, events[5].Err(), chaos.Panic)
+}
+
+func TestWrapInjectsStartDelay(t *testing.T) {
+	policy := chaos.NewPolicy(chaos.WithStartDelay(1, 20*time.Millisecond))
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(chaos.Wrap(WaitDoneWorker("child0"), policy)),
+		[]cap.Opt{},
+		func(EventManager) {},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerFaultInjected("root/child0"),
+			WorkerStarted("root/child0"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+
+	assertInjectedFault(t, events[0].Err(), chaos.StartDelay)
+	assert.True(t, events[1].GetDuration() >= 20*time.Millisecond)
+}
+
+func TestWrapSeedPerRuntimeName(t *testing.T) {
+	// faultedNodes returns the runtime names of the nodes that got a fault
+	// injected on a single run of a tree with workers of the same name
+	faultedNodes := func() []string {
+		policy := chaos.NewPolicy(
+			chaos.WithSeed(42),
+			chaos.WithStartDelay(0.5, time.Millisecond),
+		)
+
+		branches := make([]cap.Node, 0, 8)
+		for i := 0; i < 8; i++ {
+			branches = append(branches, cap.Subtree(cap.NewSupervisorSpec(
+				fmt.Sprintf("branch%d", i),
+				cap.WithNodes(chaos.Wrap(WaitDoneWorker("worker"), policy)),
+			)))
+		}
+
+		events, err := ObserveSupervisor(
+			context.TODO(),
+			"root",
+			cap.WithNodes(branches...),
+			[]cap.Opt{cap.WithParallelStart(8)},
+			func(EventManager) {},
+		)
+		assert.NoError(t, err)
+
+		names := make([]string, 0)
+		for _, ev := range events {
+			if ev.GetTag() == cap.ProcessFaultInjected {
+				names = append(names, ev.GetProcessRuntimeName())
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	names := faultedNodes()
+	// nodes with the same name get their own sequence of faults
+	assert.NotEmpty(t, names)
+	assert.True(t, len(names) < 8, "expected some nodes without faults, got %v", names)
+
+	// the faults do not depend on the order in which the nodes started
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, names, faultedNodes())
+	}
+}
+
+func TestWrapInjectsStartDelayWithClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	policy := chaos.NewPolicy(chaos.WithStartDelay(1, time.Hour))
+
+	// the start delay only finishes once the supervisor clock moves forward
+	go func() {
+		clock.WaitForTimers(1)
+		clock.Advance(time.Hour)
+	}()
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(chaos.Wrap(WaitDoneWorker("child0"), policy)),
+		[]cap.Opt{cap.WithClock(clock)},
+		func(EventManager) {},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerFaultInjected("root/child0"),
+			WorkerStarted("root/child0"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+
+	assert.Equal(t, time.Hour, events[1].GetDuration())
+}
+
+func TestWrapInjectsIgnoredCancel(t *testing.T) {
+	policy := chaos.NewPolicy(chaos.WithIgnoredCancel(1, 100*time.Millisecond))
+
+	worker := chaos.Wrap(
+		WaitDoneWorker("child0", cap.WithShutdown(cap.Timeout(10*time.Millisecond))),
+		policy,
+	)
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(worker),
+		[]cap.Opt{},
+		func(EventManager) {},
+	)
+
+	// the worker did not stop within its shutdown timeout
+	var supErr *cap.SupervisorError
+	if assert.True(t, errors.As(err, &supErr)) {
+		var timeoutErr *cap.ShutdownTimeoutError
+		assert.True(t, errors.As(supErr.GetNodeErrors()["child0"], &timeoutErr))
+	}
+
+	AssertPartialMatch(t, events,
+		[]EventP{
+			WorkerFaultInjected("root/child0"),
+			WorkerStarted("root/child0"),
+			SupervisorStarted("root"),
+			WorkerFailed("root/child0"),
+			SupervisorFailed("root"),
+		},
+	)
+
+	assertInjectedFault(t, events[0].Err(), chaos.IgnoreCancel)
+}
+
+func TestWrapWithoutFaults(t *testing.T) {
+	policy := chaos.NewPolicy(chaos.WithFailure(0, time.Millisecond))
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(chaos.Wrap(WaitDoneWorker("child0"), policy)),
+		[]cap.Opt{},
+		func(EventManager) {},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestPolicyInvalidProbability(t *testing.T) {
+	assert.Panics(t, func() {
+		chaos.WithFailure(1.5, time.Millisecond)
+	})
+}
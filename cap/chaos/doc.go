@@ -0,0 +1,60 @@
+/*
+Package chaos offers a fault injection wrapper for the nodes of a supervision
+tree built with the cap package.
+
+It allows to exercise the resilience of a system by making some of its nodes
+fail, panic, delay their start or ignore their cancellation on purpose.
+
+Policies
+
+A Policy specifies which faults get injected and how often. Every time a
+wrapped node starts (or restarts), the policy decides which of its faults are
+injected on that run, using the probability given to each fault:
+
+	policy := chaos.NewPolicy(
+		chaos.WithSeed(42),
+		chaos.WithFailure(0.1, 30*time.Second),
+		chaos.WithStartDelay(0.5, time.Second),
+	)
+
+The decisions are taken with a random number generator; when the policy has
+a seed (check WithSeed), every node gets the same sequence of faults across
+executions, no matter the order in which the nodes of the tree get started.
+Sequences are kept per runtime name, nodes with the same name in different
+sub-trees get different sequences.
+
+Wrapping Nodes
+
+A policy may be applied to a single node with Wrap:
+
+	cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(
+			chaos.Wrap(cap.NewWorker("db", dbWorker), policy),
+		),
+	)
+
+Or to all the descendant nodes of a supervisor with a runtime name that
+matches a pattern with WithPolicy:
+
+	cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(apiSubtree, dbSubtree),
+		chaos.WithPolicy("root/api/*", policy),
+	)
+
+Injected Faults Events
+
+Every injected fault is reported on the event system with an Event that has an
+EventTag of ProcessFaultInjected; the error of the event is an InjectedError
+that describes the fault. The injected failures and panics also use an
+InjectedError, this way, injected faults can be distinguished from real
+failures.
+
+Fault Delays
+
+The delays of the injected faults (e.g. the time a node runs before it fails)
+are measured with the Clock of the supervision tree, this way, a tree that runs
+with a fake Clock (check cap.WithClock) gets deterministic faults.
+*/
+package chaos
@@ -0,0 +1,190 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+var fakeClockStart = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func TestClockErrorWindowElapsed(t *testing.T) {
+	clock := NewFakeClock(fakeClockStart)
+	child0, failChild0 := FailOnSignalWorker(
+		2,
+		"child0",
+		cap.WithTolerance(1, 5*time.Second),
+	)
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(child0),
+		[]cap.Opt{cap.WithClock(clock)},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			evIt.SkipTill(SupervisorStarted("root"))
+
+			failChild0(false /* done */)
+			evIt.SkipTill(WorkerFailed("root/child0"))
+			evIt.SkipTill(WorkerStarted("root/child0"))
+
+			// the second error happens after the error window, the error count
+			// gets reset
+			clock.Advance(6 * time.Second)
+
+			failChild0(true /* done */)
+			evIt.SkipTill(WorkerFailed("root/child0"))
+			evIt.SkipTill(WorkerStarted("root/child0"))
+		},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			SupervisorStarted("root"),
+			WorkerFailed("root/child0"),
+			WorkerStarted("root/child0"),
+			WorkerFailed("root/child0"),
+			WorkerStarted("root/child0"),
+			WorkerTerminated("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+
+	// the events are created with the time of the given clock
+	assert.Equal(t, fakeClockStart, events[0].GetCreated())
+	assert.Equal(t, fakeClockStart.Add(6*time.Second), events[4].GetCreated())
+}
+
+func TestClockErrorWindowNotElapsed(t *testing.T) {
+	clock := NewFakeClock(fakeClockStart)
+	child0, failChild0 := FailOnSignalWorker(
+		2,
+		"child0",
+		cap.WithTolerance(1, 5*time.Second),
+	)
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(child0),
+		[]cap.Opt{cap.WithClock(clock)},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			evIt.SkipTill(SupervisorStarted("root"))
+
+			failChild0(false /* done */)
+			evIt.SkipTill(WorkerFailed("root/child0"))
+			evIt.SkipTill(WorkerStarted("root/child0"))
+
+			// the second error happens within the error window
+			clock.Advance(4 * time.Second)
+
+			failChild0(false /* done */)
+			evIt.SkipTill(SupervisorFailed("root"))
+		},
+	)
+
+	assert.Error(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			SupervisorStarted("root"),
+			WorkerFailed("root/child0"),
+			WorkerStarted("root/child0"),
+			WorkerFailed("root/child0"),
+			SupervisorFailed("root"),
+		},
+	)
+}
+
+func TestClockShutdownTimeout(t *testing.T) {
+	clock := NewFakeClock(fakeClockStart)
+	stuck, unblock := StuckWorker("child0", cap.WithShutdown(cap.Timeout(time.Minute)))
+	defer unblock()
+
+	evManager := NewEventManager()
+	evManager.StartCollector(context.TODO())
+
+	b0 := cap.NewSupervisorSpec("branch0", cap.WithNodes(stuck))
+
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(cap.Subtree(b0)),
+		cap.WithNotifier(evManager.EventCollector(context.TODO())),
+		cap.WithClock(clock),
+	).Start(context.TODO())
+	assert.NoError(t, err)
+
+	terminateCh := make(chan error)
+	go func() {
+		terminateCh <- sup.Terminate()
+	}()
+
+	// the sub-tree inherits the clock of the root supervisor, we wait for the
+	// shutdown timeout of the stuck worker
+	clock.WaitForTimers(1)
+	clock.Advance(time.Minute)
+
+	terminateErr := <-terminateCh
+	assert.Error(t, terminateErr)
+
+	var timeoutErr *cap.ShutdownTimeoutError
+	evIt := evManager.Iterator()
+	ev := WaitForEvent(t, &evIt, WorkerFailed("root/branch0/child0"), time.Second)
+	if assert.True(t, errors.As(ev.Err(), &timeoutErr)) {
+		assert.Equal(t, time.Minute, timeoutErr.GetTimeout())
+	}
+	assert.Equal(t, fakeClockStart.Add(time.Minute), ev.GetCreated())
+
+	// leaked nodes are measured with the clock as well
+	clock.Advance(time.Minute)
+	leaked := sup.GetLeakedNodes()
+	if assert.Equal(t, 1, len(leaked)) {
+		assert.Equal(t, time.Minute, leaked[0].GetLeakedDuration())
+	}
+}
+
+func TestClockHealthcheckMonitor(t *testing.T) {
+	clock := NewFakeClock(fakeClockStart)
+	healthcheckMonitor := cap.NewHealthcheckMonitorWithClock(1, 10*time.Second, clock)
+	slow, failSlow, releaseSlow := slowRestartWorker("slow")
+	defer releaseSlow()
+
+	_, err := ObserveSupervisorWithNotifiers(
+		context.TODO(),
+		"root",
+		cap.WithNodes(slow),
+		[]cap.Opt{cap.WithClock(clock)},
+		[]cap.EventNotifier{healthcheckMonitor.HandleEvent},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			evIt.SkipTill(SupervisorStarted("root"))
+
+			failSlow()
+			evIt.SkipTill(WorkerFailed("root/slow"))
+
+			// the restart is considered delayed only once the clock moves
+			assert.True(t, healthcheckMonitor.IsHealthy())
+			clock.Advance(11 * time.Second)
+			assert.False(t, healthcheckMonitor.IsHealthy())
+
+			releaseSlow()
+			evIt.SkipTill(WorkerStarted("root/slow"))
+			assert.True(t, healthcheckMonitor.IsHealthy())
+		},
+	)
+
+	assert.NoError(t, err)
+}
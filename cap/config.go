@@ -0,0 +1,464 @@
+package cap
+
+// This file contains the implementation of declarative supervision trees;
+// these trees are described with a JSON document, and the workers in it are
+// created with constructors that are registered in a Registry.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/capatazlib/go-capataz/internal/c"
+)
+
+// WorkerConstructor is a function that creates a worker Node. It receives the
+// name of the node, the arguments specified for the node in the config
+// document (nil if none was given), and the WorkerOpt values built from the
+// config document, which must be given to the created worker.
+//
+// Example:
+//
+//   func newKafkaConsumer(
+//     name string,
+//     args json.RawMessage,
+//     opts ...cap.WorkerOpt,
+//   ) (cap.Node, error) {
+//     var consumerArgs struct {
+//       Topic string `json:"topic"`
+//     }
+//     if err := json.Unmarshal(args, &consumerArgs); err != nil {
+//       return nil, err
+//     }
+//     return cap.NewWorker(name, func(ctx context.Context) error {
+//       return consume(ctx, consumerArgs.Topic)
+//     }, opts...), nil
+//   }
+//
+type WorkerConstructor = func(
+	name string,
+	args json.RawMessage,
+	opts ...WorkerOpt,
+) (Node, error)
+
+// Registry binds the worker types used in a config document to the Go
+// functions that create them. A Registry is safe for concurrent use.
+type Registry struct {
+	mux          *sync.Mutex
+	constructors map[string]WorkerConstructor
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	var mux sync.Mutex
+
+	return &Registry{
+		mux:          &mux,
+		constructors: make(map[string]WorkerConstructor),
+	}
+}
+
+// Register binds the given worker type to the given constructor.
+//
+// The worker type must not be empty nor be registered already, otherwise, the
+// system will panic. This method is preferred as opposed to return an error
+// given it is considered a bad implementation (ideally a compilation error).
+func (r *Registry) Register(workerType string, constructor WorkerConstructor) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if workerType == "" {
+		panic("Registry cannot have an empty worker type")
+	}
+	if constructor == nil {
+		panic(fmt.Sprintf("Registry worker type %s cannot have an empty constructor", workerType))
+	}
+	if _, ok := r.constructors[workerType]; ok {
+		panic(fmt.Sprintf("Registry worker type %s is already registered", workerType))
+	}
+	r.constructors[workerType] = constructor
+}
+
+// GetWorkerTypes returns the registered worker types in lexicographical order
+func (r *Registry) GetWorkerTypes() []string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	workerTypes := make([]string, 0, len(r.constructors))
+	for workerType := range r.constructors {
+		workerTypes = append(workerTypes, workerType)
+	}
+	sort.Strings(workerTypes)
+	return workerTypes
+}
+
+// getConstructor returns the constructor of the given worker type
+func (r *Registry) getConstructor(workerType string) (WorkerConstructor, bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	constructor, ok := r.constructors[workerType]
+	return constructor, ok
+}
+
+// SupervisorConfig is the declarative representation of a SupervisorSpec.
+//
+// Example:
+//
+//   {
+//     "name": "root",
+//     "order": "left-to-right",
+//     "strategy": "one-for-one",
+//     "shutdown_timeout": "30s",
+//     "nodes": [
+//       {
+//         "name": "consumer",
+//         "type": "kafka-consumer",
+//         "args": {"topic": "orders"},
+//         "restart": "transient",
+//         "shutdown": "10s",
+//         "depends_on": ["api"],
+//         "tolerance": {"max_errors": 3, "window": "1m"}
+//       },
+//       {
+//         "name": "api",
+//         "subtree": {
+//           "order": "right-to-left",
+//           "nodes": [{"name": "server", "type": "http-server"}]
+//         }
+//       }
+//     ]
+//   }
+//
+// Durations use the format of time.ParseDuration. The supported values for
+// each setting are:
+//
+// * order -- left-to-right (default), right-to-left
+//
+// * strategy -- one-for-one (default)
+//
+// * restart -- permanent (default), transient, temporary
+//
+// * shutdown -- indefinitely or a duration (default: 5s)
+//
+// * tolerance -- max_errors and a window duration; the window is optional
+// (default: 5s)
+//
+type SupervisorConfig struct {
+	Name            string       `json:"name,omitempty"`
+	Order           string       `json:"order,omitempty"`
+	Strategy        string       `json:"strategy,omitempty"`
+	ShutdownTimeout string       `json:"shutdown_timeout,omitempty"`
+	DrainTimeout    string       `json:"drain_timeout,omitempty"`
+	Nodes           []NodeConfig `json:"nodes"`
+}
+
+// NodeConfig is the declarative representation of a Node. A node is either a
+// worker, which uses a constructor registered for its type, or a sub-tree.
+//
+// The name of a sub-tree node is used as the name of its supervisor, the name
+// in the sub-tree SupervisorConfig is ignored.
+type NodeConfig struct {
+	Name         string            `json:"name"`
+	Type         string            `json:"type,omitempty"`
+	Args         json.RawMessage   `json:"args,omitempty"`
+	Restart      string            `json:"restart,omitempty"`
+	Shutdown     string            `json:"shutdown,omitempty"`
+	Tolerance    *ToleranceConfig  `json:"tolerance,omitempty"`
+	CapturePanic *bool             `json:"capture_panic,omitempty"`
+	DependsOn    []string          `json:"depends_on,omitempty"`
+	Subtree      *SupervisorConfig `json:"subtree,omitempty"`
+}
+
+// ToleranceConfig is the declarative representation of the WithTolerance
+// setting of a node; when the window is not given, the node keeps its default
+// error window
+type ToleranceConfig struct {
+	MaxErrors uint32 `json:"max_errors"`
+	Window    string `json:"window,omitempty"`
+}
+
+// ConfigError is the error reported when a config document is not valid; it
+// contains the path of the node with the invalid setting (e.g. root/api/server)
+type ConfigError struct {
+	path string
+	err  error
+}
+
+// GetPath returns the path of the supervisor or node with the invalid setting
+func (err *ConfigError) GetPath() string {
+	return err.path
+}
+
+// Unwrap returns the error that made the setting invalid
+func (err *ConfigError) Unwrap() error {
+	return err.err
+}
+
+// KVs returns a data bag map that may be used in structured logging
+func (err *ConfigError) KVs() map[string]interface{} {
+	kvs := make(map[string]interface{})
+	kvs["config.path"] = err.path
+	kvs["config.error"] = err.err.Error()
+	return kvs
+}
+
+func (err *ConfigError) Error() string {
+	if err.path == "" {
+		return fmt.Sprintf("invalid supervisor config: %v", err.err)
+	}
+	return fmt.Sprintf("invalid supervisor config at %s: %v", err.path, err.err)
+}
+
+// ReadSupervisorConfig decodes a SupervisorConfig from the given JSON document.
+// Unknown fields in the document are reported as errors.
+func ReadSupervisorConfig(r io.Reader) (SupervisorConfig, error) {
+	var cfg SupervisorConfig
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&cfg); err != nil {
+		return SupervisorConfig{}, &ConfigError{err: err}
+	}
+	return cfg, nil
+}
+
+// NewSupervisorSpecFromJSON creates a SupervisorSpec from the given JSON
+// document; check the documentation of SupervisorConfig for details of the
+// document format.
+//
+// The given Opt values are applied to the root supervisor after the settings
+// of the document (e.g. WithNotifier).
+func (r *Registry) NewSupervisorSpecFromJSON(
+	input []byte,
+	opts ...Opt,
+) (SupervisorSpec, error) {
+	cfg, err := ReadSupervisorConfig(bytes.NewReader(input))
+	if err != nil {
+		return SupervisorSpec{}, err
+	}
+	return r.NewSupervisorSpecFromConfig(cfg, opts...)
+}
+
+// NewSupervisorSpecFromConfig creates a SupervisorSpec from the given
+// SupervisorConfig. The worker nodes get created with the constructors of
+// this Registry.
+//
+// The given Opt values are applied to the root supervisor after the settings
+// of the config (e.g. WithNotifier).
+func (r *Registry) NewSupervisorSpecFromConfig(
+	cfg SupervisorConfig,
+	opts ...Opt,
+) (SupervisorSpec, error) {
+	if cfg.Name == "" {
+		return SupervisorSpec{}, &ConfigError{err: fmt.Errorf("supervisor name is required")}
+	}
+	return r.buildSupervisorSpec(cfg.Name, cfg.Name, cfg, opts)
+}
+
+// buildSupervisorSpec creates the SupervisorSpec of the given config, the given
+// path is used to report errors
+func (r *Registry) buildSupervisorSpec(
+	name string,
+	path string,
+	cfg SupervisorConfig,
+	extraOpts []Opt,
+) (SupervisorSpec, error) {
+	supOpts, err := buildSupervisorOpts(cfg)
+	if err != nil {
+		return SupervisorSpec{}, &ConfigError{path: path, err: err}
+	}
+
+	nodes := make([]Node, 0, len(cfg.Nodes))
+	names := make(map[string]struct{}, len(cfg.Nodes))
+
+	for _, nodeCfg := range cfg.Nodes {
+		if nodeCfg.Name == "" || strings.Contains(nodeCfg.Name, nodeSepToken) {
+			return SupervisorSpec{}, &ConfigError{
+				path: path,
+				err:  fmt.Errorf("invalid node name %q", nodeCfg.Name),
+			}
+		}
+		if _, ok := names[nodeCfg.Name]; ok {
+			return SupervisorSpec{}, &ConfigError{
+				path: path,
+				err:  fmt.Errorf("duplicated node name %q", nodeCfg.Name),
+			}
+		}
+		names[nodeCfg.Name] = struct{}{}
+
+		node, err := r.buildNode(strings.Join([]string{path, nodeCfg.Name}, nodeSepToken), nodeCfg)
+		if err != nil {
+			return SupervisorSpec{}, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	supOpts = append(supOpts, extraOpts...)
+	return NewSupervisorSpec(name, WithNodes(nodes...), supOpts...), nil
+}
+
+// buildNode creates the Node of the given config, the given path is used to
+// report errors
+func (r *Registry) buildNode(path string, cfg NodeConfig) (Node, error) {
+	workerOpts, err := buildWorkerOpts(cfg)
+	if err != nil {
+		return nil, &ConfigError{path: path, err: err}
+	}
+
+	switch {
+	case cfg.Subtree != nil && cfg.Type != "":
+		return nil, &ConfigError{
+			path: path,
+			err:  fmt.Errorf("a node cannot have both a type and a subtree"),
+		}
+
+	case cfg.Subtree != nil:
+		if cfg.Shutdown != "" || len(cfg.Args) > 0 {
+			return nil, &ConfigError{
+				path: path,
+				err:  fmt.Errorf("a subtree node only supports restart, tolerance and depends_on settings"),
+			}
+		}
+		subtreeSpec, err := r.buildSupervisorSpec(cfg.Name, path, *cfg.Subtree, []Opt{})
+		if err != nil {
+			return nil, err
+		}
+		return Subtree(subtreeSpec, workerOpts...), nil
+
+	case cfg.Type != "":
+		constructor, ok := r.getConstructor(cfg.Type)
+		if !ok {
+			return nil, &ConfigError{
+				path: path,
+				err:  fmt.Errorf("unknown worker type %q", cfg.Type),
+			}
+		}
+		node, err := constructor(cfg.Name, cfg.Args, workerOpts...)
+		if err != nil {
+			return nil, &ConfigError{path: path, err: err}
+		}
+		return node, nil
+
+	default:
+		return nil, &ConfigError{
+			path: path,
+			err:  fmt.Errorf("a node must have either a type or a subtree"),
+		}
+	}
+}
+
+// buildSupervisorOpts transforms the settings of the given config into Opt
+// values
+func buildSupervisorOpts(cfg SupervisorConfig) ([]Opt, error) {
+	opts := make([]Opt, 0, 4)
+
+	switch cfg.Order {
+	case "", "left-to-right":
+		opts = append(opts, WithStartOrder(LeftToRight))
+	case "right-to-left":
+		opts = append(opts, WithStartOrder(RightToLeft))
+	default:
+		return nil, fmt.Errorf("invalid order %q", cfg.Order)
+	}
+
+	switch cfg.Strategy {
+	case "", "one-for-one":
+		opts = append(opts, WithStrategy(OneForOne))
+	default:
+		return nil, fmt.Errorf("invalid strategy %q", cfg.Strategy)
+	}
+
+	if cfg.ShutdownTimeout != "" {
+		d, err := parseConfigDuration("shutdown_timeout", cfg.ShutdownTimeout)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithShutdownTimeout(d))
+	}
+
+	if cfg.DrainTimeout != "" {
+		d, err := parseConfigDuration("drain_timeout", cfg.DrainTimeout)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithDrainTimeout(d))
+	}
+
+	return opts, nil
+}
+
+// buildWorkerOpts transforms the settings of the given config into WorkerOpt
+// values
+func buildWorkerOpts(cfg NodeConfig) ([]WorkerOpt, error) {
+	opts := make([]WorkerOpt, 0, 4)
+
+	switch cfg.Restart {
+	case "":
+	case "permanent":
+		opts = append(opts, WithRestart(Permanent))
+	case "transient":
+		opts = append(opts, WithRestart(Transient))
+	case "temporary":
+		opts = append(opts, WithRestart(Temporary))
+	default:
+		return nil, fmt.Errorf("invalid restart %q", cfg.Restart)
+	}
+
+	switch cfg.Shutdown {
+	case "":
+	case "indefinitely":
+		opts = append(opts, WithShutdown(Indefinitely))
+	default:
+		d, err := parseConfigDuration("shutdown", cfg.Shutdown)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithShutdown(Timeout(d)))
+	}
+
+	if cfg.Tolerance != nil && cfg.Tolerance.Window == "" {
+		opts = append(opts, withMaxErrCount(cfg.Tolerance.MaxErrors))
+	} else if cfg.Tolerance != nil {
+		d, err := parseConfigDuration("tolerance window", cfg.Tolerance.Window)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithTolerance(cfg.Tolerance.MaxErrors, d))
+	}
+
+	if cfg.CapturePanic != nil {
+		opts = append(opts, WithCapturePanic(*cfg.CapturePanic))
+	}
+
+	if len(cfg.DependsOn) > 0 {
+		opts = append(opts, WithDependsOn(cfg.DependsOn...))
+	}
+
+	return opts, nil
+}
+
+// withMaxErrCount is a WorkerOpt that only changes the max error count of the
+// tolerance of a node, the error window of the node is kept
+func withMaxErrCount(maxErrCount uint32) WorkerOpt {
+	return func(chSpec *c.ChildSpec) {
+		chSpec.ErrTolerance.MaxErrCount = maxErrCount
+	}
+}
+
+// parseConfigDuration parses a duration of the setting with the given name
+func parseConfigDuration(setting string, input string) (time.Duration, error) {
+	d, err := time.ParseDuration(input)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", setting, input, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: duration must not be negative", setting, input)
+	}
+	return d, nil
+}
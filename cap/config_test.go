@@ -0,0 +1,296 @@
+package cap_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// waitDoneConstructor is a WorkerConstructor that creates a WaitDoneWorker
+func waitDoneConstructor(
+	name string,
+	_ json.RawMessage,
+	opts ...cap.WorkerOpt,
+) (cap.Node, error) {
+	return cap.NewWorker(name, func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}, opts...), nil
+}
+
+func TestSupervisorSpecFromJSON(t *testing.T) {
+	ctx := context.TODO()
+	evManager := NewEventManager()
+	evManager.StartCollector(ctx)
+
+	argsCh := make(chan string, 1)
+
+	registry := cap.NewRegistry()
+	registry.Register("wait-done", waitDoneConstructor)
+	registry.Register(
+		"with-args",
+		func(name string, args json.RawMessage, opts ...cap.WorkerOpt) (cap.Node, error) {
+			var input struct {
+				Topic string `json:"topic"`
+			}
+			if err := json.Unmarshal(args, &input); err != nil {
+				return nil, err
+			}
+			argsCh <- input.Topic
+			return waitDoneConstructor(name, args, opts...)
+		},
+	)
+
+	assert.Equal(t, []string{"wait-done", "with-args"}, registry.GetWorkerTypes())
+
+	supSpec, err := registry.NewSupervisorSpecFromJSON(
+		[]byte(`{
+			"name": "root",
+			"shutdown_timeout": "1s",
+			"nodes": [
+				{"name": "child0", "type": "with-args", "args": {"topic": "orders"}},
+				{
+					"name": "branch0",
+					"restart": "transient",
+					"subtree": {
+						"order": "right-to-left",
+						"nodes": [
+							{"name": "child1", "type": "wait-done", "shutdown": "indefinitely"},
+							{
+								"name": "child2",
+								"type": "wait-done",
+								"restart": "temporary",
+								"tolerance": {"max_errors": 3, "window": "1m"}
+							}
+						]
+					}
+				}
+			]
+		}`),
+		cap.WithNotifier(evManager.EventCollector(ctx)),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "orders", <-argsCh)
+	assert.Equal(t, "root", supSpec.GetName())
+
+	sup, err := supSpec.Start(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, sup.Terminate())
+
+	// events are collected asynchronously, we wait for the last one
+	evIt := evManager.Iterator()
+	evIt.SkipTill(SupervisorTerminated("root"))
+
+	AssertExactMatch(t, evManager.Snapshot(),
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerStarted("root/branch0/child2"),
+			WorkerStarted("root/branch0/child1"),
+			SupervisorStarted("root/branch0"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/branch0/child1"),
+			WorkerTerminated("root/branch0/child2"),
+			SupervisorTerminated("root/branch0"),
+			WorkerTerminated("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestSupervisorSpecFromJSONWorkerOpts(t *testing.T) {
+	var unblock func()
+
+	registry := cap.NewRegistry()
+	registry.Register(
+		"stuck",
+		func(name string, _ json.RawMessage, opts ...cap.WorkerOpt) (cap.Node, error) {
+			var node cap.Node
+			node, unblock = StuckWorker(name, opts...)
+			return node, nil
+		},
+	)
+
+	supSpec, err := registry.NewSupervisorSpecFromJSON([]byte(`{
+		"name": "root",
+		"nodes": [{"name": "child0", "type": "stuck", "shutdown": "10ms"}]
+	}`))
+	assert.NoError(t, err)
+
+	sup, err := supSpec.Start(context.TODO())
+	assert.NoError(t, err)
+	defer unblock()
+
+	// the shutdown setting of the document is given to the worker
+	var supErr *cap.SupervisorError
+	if assert.True(t, errors.As(sup.Terminate(), &supErr)) {
+		var timeoutErr *cap.ShutdownTimeoutError
+		if assert.True(t, errors.As(supErr.GetNodeErrors()["child0"], &timeoutErr)) {
+			assert.Equal(t, 10*time.Millisecond, timeoutErr.GetTimeout())
+		}
+	}
+}
+
+func TestSupervisorSpecFromJSONToleranceWindow(t *testing.T) {
+	registry := cap.NewRegistry()
+	registry.Register("wait-done", waitDoneConstructor)
+
+	supSpec, err := registry.NewSupervisorSpecFromJSON([]byte(`{
+		"name": "root",
+		"nodes": [
+			{"name": "child0", "type": "wait-done", "tolerance": {"max_errors": 3}},
+			{
+				"name": "branch0",
+				"tolerance": {"max_errors": 2},
+				"subtree": {"nodes": [{"name": "child1", "type": "wait-done"}]}
+			}
+		]
+	}`))
+	assert.NoError(t, err)
+
+	info, err := supSpec.Describe()
+	assert.NoError(t, err)
+
+	// nodes without a tolerance window keep the default one
+	children := info.GetChildren()
+	if assert.Len(t, children, 2) {
+		maxErrCount, errWindow := children[0].GetTolerance()
+		assert.Equal(t, uint32(3), maxErrCount)
+		assert.Equal(t, 5*time.Second, errWindow)
+
+		maxErrCount, errWindow = children[1].GetTolerance()
+		assert.Equal(t, uint32(2), maxErrCount)
+		assert.Equal(t, 5*time.Second, errWindow)
+	}
+}
+
+func TestSupervisorSpecFromJSONErrors(t *testing.T) {
+	registry := cap.NewRegistry()
+	registry.Register("wait-done", waitDoneConstructor)
+	registry.Register(
+		"failing",
+		func(string, json.RawMessage, ...cap.WorkerOpt) (cap.Node, error) {
+			return nil, errors.New("missing topic")
+		},
+	)
+
+	tests := []struct {
+		name    string
+		input   string
+		path    string
+		message string
+	}{
+		{
+			"malformed document",
+			`{"name": "root", `,
+			"",
+			"unexpected EOF",
+		},
+		{
+			"unknown field",
+			`{"name": "root", "nodez": []}`,
+			"",
+			`unknown field "nodez"`,
+		},
+		{
+			"missing supervisor name",
+			`{"nodes": []}`,
+			"",
+			"supervisor name is required",
+		},
+		{
+			"invalid order",
+			`{"name": "root", "order": "up-down", "nodes": []}`,
+			"root",
+			`invalid order "up-down"`,
+		},
+		{
+			"invalid shutdown timeout",
+			`{"name": "root", "shutdown_timeout": "soon", "nodes": []}`,
+			"root",
+			`invalid shutdown_timeout "soon"`,
+		},
+		{
+			"unknown worker type",
+			`{"name": "root", "nodes": [{"name": "child0", "type": "kafka-consumer"}]}`,
+			"root/child0",
+			`unknown worker type "kafka-consumer"`,
+		},
+		{
+			"duplicated node name",
+			`{"name": "root", "nodes": [
+				{"name": "child0", "type": "wait-done"},
+				{"name": "child0", "type": "wait-done"}
+			]}`,
+			"root",
+			`duplicated node name "child0"`,
+		},
+		{
+			"invalid restart on a sub-tree",
+			`{"name": "root", "nodes": [{"name": "branch0", "subtree": {"nodes": [
+				{"name": "child0", "type": "wait-done", "restart": "sometimes"}
+			]}}]}`,
+			"root/branch0/child0",
+			`invalid restart "sometimes"`,
+		},
+		{
+			"invalid tolerance window",
+			`{"name": "root", "nodes": [
+				{"name": "child0", "type": "wait-done", "tolerance": {"max_errors": 1, "window": "-1s"}}
+			]}`,
+			"root/child0",
+			"duration must not be negative",
+		},
+		{
+			"node without type",
+			`{"name": "root", "nodes": [{"name": "child0"}]}`,
+			"root/child0",
+			"a node must have either a type or a subtree",
+		},
+		{
+			"shutdown on a sub-tree",
+			`{"name": "root", "nodes": [{"name": "branch0", "shutdown": "1s", "subtree": {"nodes": []}}]}`,
+			"root/branch0",
+			"a subtree node only supports restart, tolerance and depends_on settings",
+		},
+		{
+			"constructor error",
+			`{"name": "root", "nodes": [{"name": "child0", "type": "failing"}]}`,
+			"root/child0",
+			"missing topic",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := registry.NewSupervisorSpecFromJSON([]byte(tt.input))
+
+			var configErr *cap.ConfigError
+			if assert.True(t, errors.As(err, &configErr)) {
+				assert.Equal(t, tt.path, configErr.GetPath())
+				assert.True(
+					t,
+					strings.Contains(err.Error(), tt.message),
+					"expected %q in error %q", tt.message, err.Error(),
+				)
+			}
+		})
+	}
+}
+
+func TestRegistryRegisterPanics(t *testing.T) {
+	registry := cap.NewRegistry()
+	registry.Register("wait-done", waitDoneConstructor)
+
+	assert.Panics(t, func() { registry.Register("wait-done", waitDoneConstructor) })
+	assert.Panics(t, func() { registry.Register("", waitDoneConstructor) })
+	assert.Panics(t, func() { registry.Register("nil", nil) })
+}
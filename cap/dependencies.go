@@ -0,0 +1,291 @@
+package cap
+
+// This file contains the logic of the dependencies between the children nodes
+// of a supervisor (check WithDependsOn)
+
+import (
+	"context"
+
+	"github.com/capatazlib/go-capataz/internal/c"
+)
+
+// validateNodeDependencies verifies the dependencies of the given children
+// specs reference sibling nodes and do not have cycles
+func validateNodeDependencies(
+	supRuntimeName string,
+	supChildrenSpecs []c.ChildSpec,
+) error {
+	specsByName := make(map[string]c.ChildSpec, len(supChildrenSpecs))
+	for _, chSpec := range supChildrenSpecs {
+		specsByName[chSpec.GetName()] = chSpec
+	}
+
+	for _, chSpec := range supChildrenSpecs {
+		for _, depName := range chSpec.GetDependencies() {
+			if _, ok := specsByName[depName]; !ok {
+				return &UnknownDependencyError{
+					supRuntimeName: supRuntimeName,
+					nodeName:       chSpec.GetName(),
+					dependencyName: depName,
+				}
+			}
+		}
+	}
+
+	// we do a depth-first search on the dependency graph; a node that is found
+	// while it is still on the search path indicates a cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(supChildrenSpecs))
+	path := make([]string, 0, len(supChildrenSpecs))
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			// the cycle is the section of the path that starts on this node
+			for i, pathName := range path {
+				if pathName == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, depName := range specsByName[name].GetDependencies() {
+			if cycle := visit(depName); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+
+	for _, chSpec := range supChildrenSpecs {
+		if cycle := visit(chSpec.GetName()); cycle != nil {
+			return &DependencyCycleError{
+				supRuntimeName: supRuntimeName,
+				cycle:          cycle,
+			}
+		}
+	}
+	return nil
+}
+
+// sortStart returns children sorted for the supervisor start; a child always
+// comes after its dependencies, and children without dependencies between them
+// follow the supervisor's Order.
+func (spec SupervisorSpec) sortStart(supChildrenSpecs []c.ChildSpec) []c.ChildSpec {
+	input := spec.order.sortStart(supChildrenSpecs)
+
+	inInput := make(map[string]bool, len(input))
+	for _, chSpec := range input {
+		inInput[chSpec.GetName()] = true
+	}
+
+	output := make([]c.ChildSpec, 0, len(input))
+	placed := make(map[string]bool, len(input))
+
+	for len(output) < len(input) {
+		progress := false
+		for _, chSpec := range input {
+			if placed[chSpec.GetName()] || !dependenciesPlaced(chSpec, inInput, placed) {
+				continue
+			}
+			output = append(output, chSpec)
+			placed[chSpec.GetName()] = true
+			progress = true
+			// we start again from the beginning to keep the supervisor's Order
+			break
+		}
+		if !progress {
+			// NOTE: this should never happen given cycles are detected before the
+			// supervisor starts; we keep the remaining children in Order
+			for _, chSpec := range input {
+				if !placed[chSpec.GetName()] {
+					output = append(output, chSpec)
+					placed[chSpec.GetName()] = true
+				}
+			}
+		}
+	}
+
+	return output
+}
+
+// dependenciesPlaced returns true if all the dependencies of the given child
+// spec that are part of the sorted children have been placed already
+func dependenciesPlaced(
+	chSpec c.ChildSpec,
+	inInput map[string]bool,
+	placed map[string]bool,
+) bool {
+	for _, depName := range chSpec.GetDependencies() {
+		// dependencies that are not present (e.g. a cancelled node of a
+		// DynSupervisor) are ignored
+		if inInput[depName] && !placed[depName] {
+			return false
+		}
+	}
+	return true
+}
+
+// sortTermination returns children sorted for the supervisor stop, which is
+// the reverse of the start order
+func (spec SupervisorSpec) sortTermination(supChildrenSpecs []c.ChildSpec) []c.ChildSpec {
+	output := spec.sortStart(supChildrenSpecs)
+	for i, j := 0, len(output)-1; i < j; i, j = i+1, j-1 {
+		output[i], output[j] = output[j], output[i]
+	}
+	return output
+}
+
+// getDependentNodes returns the specs of the children that depend (directly or
+// transitively) on the child with the given name, sorted for the supervisor
+// start.
+func (spec SupervisorSpec) getDependentNodes(
+	supChildrenSpecs []c.ChildSpec,
+	chName string,
+) []c.ChildSpec {
+	dependents := map[string]bool{chName: true}
+
+	// we iterate in start order, this way the dependencies of a node are always
+	// checked before the node itself
+	sorted := spec.sortStart(supChildrenSpecs)
+	output := make([]c.ChildSpec, 0)
+
+	for _, chSpec := range sorted {
+		for _, depName := range chSpec.GetDependencies() {
+			if dependents[depName] {
+				dependents[chSpec.GetName()] = true
+				output = append(output, chSpec)
+				break
+			}
+		}
+	}
+	return output
+}
+
+// restartsOnNotification returns true if the supervisor is going to restart the
+// given child after receiving the given notification
+func restartsOnNotification(ch c.Child, chNotification c.ChildNotification) bool {
+	switch ch.GetSpec().GetRestart() {
+	case c.Permanent:
+		return true
+	case c.Transient:
+		return chNotification.Unwrap() != nil
+	default: /* Temporary */
+		return false
+	}
+}
+
+// stopDependentNodes terminates the children that depend on the child with the
+// given name, in the supervisor stop order. The stopped children are removed
+// from the given children map and returned in start order.
+func stopDependentNodes(
+	spec SupervisorSpec,
+	supChildrenSpecs []c.ChildSpec,
+	supChildren map[string]c.Child,
+	chName string,
+) []c.Child {
+	eventNotifier := spec.getEventNotifier()
+	dependentSpecs := spec.getDependentNodes(supChildrenSpecs, chName)
+
+	stopped := make([]c.Child, 0, len(dependentSpecs))
+	for i := len(dependentSpecs) - 1; i >= 0; i-- {
+		ch, ok := supChildren[dependentSpecs[i].GetName()]
+		if !ok {
+			continue
+		}
+		// errors are reported on the event system by terminateChildNode
+		_ = terminateChildNode(eventNotifier, ch, c.RestartReason)
+		delete(supChildren, ch.GetName())
+		stopped = append([]c.Child{ch}, stopped...)
+	}
+	return stopped
+}
+
+// startDependentNodes starts again the given children (previously stopped with
+// stopDependentNodes) in the given order. The restart of a dependent child
+// does not count against its error tolerance, unless the child fails to start.
+func startDependentNodes(
+	startCtx context.Context,
+	spec SupervisorSpec,
+	supRuntimeName string,
+	supChildren map[string]c.Child,
+	supNotifyCh chan c.ChildNotification,
+	stopped []c.Child,
+) *c.ErrorToleranceReached {
+	eventNotifier := spec.getEventNotifier()
+
+	for _, prevCh := range stopped {
+		_, restartErr := oneForOneRestart(
+			startCtx,
+			eventNotifier,
+			supRuntimeName,
+			supChildren,
+			supNotifyCh,
+			true, /* was complete */
+			prevCh,
+		)
+		if restartErr == nil {
+			continue
+		}
+		// the supervisor is terminating, the remaining children stay stopped
+		if startCtx.Err() != nil {
+			return nil
+		}
+		// if the dependent child fails to start, we handle it as a child
+		// failure, which restarts it until its error tolerance is reached
+		toleranceErr := handleChildNodeError(
+			startCtx,
+			eventNotifier,
+			supRuntimeName,
+			supChildren,
+			supNotifyCh,
+			prevCh,
+			restartErr,
+		)
+		if toleranceErr != nil {
+			return toleranceErr
+		}
+	}
+	return nil
+}
+
+// startStoppedNodes starts again the given children (stopped by a manual
+// restart) in the given order, without asserting their error tolerance; the
+// children keep their restart count. When a child fails to start, it returns
+// that child and the ones after it.
+func startStoppedNodes(
+	startCtx context.Context,
+	eventNotifier EventNotifier,
+	supRuntimeName string,
+	supChildren map[string]c.Child,
+	supNotifyCh chan c.ChildNotification,
+	stopped []c.Child,
+) ([]c.Child, error) {
+	for i, prevCh := range stopped {
+		chSpec := prevCh.GetSpec()
+		startTime := chSpec.GetClock().Now()
+		newCh, startErr := prevCh.StartAgain(startCtx, supRuntimeName, supNotifyCh)
+		if startErr != nil {
+			notifyChildNodeStartError(
+				eventNotifier, chSpec, prevCh.GetRuntimeName(), newCh, startErr,
+			)
+			return stopped[i:], startErr
+		}
+		supChildren[chSpec.GetName()] = newCh
+		if chSpec.IsWorker() {
+			eventNotifier.workerStarted(chSpec.GetClock(), newCh.GetRuntimeName(), startTime)
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,266 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+func TestDependenciesStartAndStopOrder(t *testing.T) {
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			WaitDoneWorker("http-server", cap.WithDependsOn("cache", "db-pool")),
+			WaitDoneWorker("cache"),
+			WaitDoneWorker("db-pool"),
+		),
+		[]cap.Opt{},
+		func(EventManager) {},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/cache"),
+			WorkerStarted("root/db-pool"),
+			WorkerStarted("root/http-server"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/http-server"),
+			WorkerTerminated("root/db-pool"),
+			WorkerTerminated("root/cache"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestDependenciesKeepStartOrder(t *testing.T) {
+	b0 := cap.NewSupervisorSpec(
+		"branch0",
+		cap.WithNodes(WaitDoneWorker("child2"), WaitDoneWorker("child3")),
+	)
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			WaitDoneWorker("child0"),
+			cap.Subtree(b0, cap.WithDependsOn("child1")),
+			WaitDoneWorker("child1"),
+		),
+		[]cap.Opt{cap.WithStartOrder(cap.RightToLeft)},
+		func(EventManager) {},
+	)
+
+	assert.NoError(t, err)
+
+	// independent nodes follow the RightToLeft order
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child1"),
+			WorkerStarted("root/branch0/child2"),
+			WorkerStarted("root/branch0/child3"),
+			SupervisorStarted("root/branch0"),
+			WorkerStarted("root/child0"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child0"),
+			WorkerTerminated("root/branch0/child3"),
+			WorkerTerminated("root/branch0/child2"),
+			SupervisorTerminated("root/branch0"),
+			WorkerTerminated("root/child1"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestDependenciesRestartDependents(t *testing.T) {
+	reasonCh := make(chan cap.TerminationReason, 2)
+
+	dbPool, failDBPool := FailOnSignalWorker(1, "db-pool")
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			dbPool,
+			WaitDoneWorker("cache"),
+			reasonWorker("repository", reasonCh, cap.WithDependsOn("db-pool")),
+			WaitDoneWorker("http-server", cap.WithDependsOn("repository", "cache")),
+		),
+		[]cap.Opt{},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			evIt.SkipTill(SupervisorStarted("root"))
+			failDBPool(true /* done */)
+			evIt.SkipTill(WorkerStarted("root/http-server"))
+		},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/db-pool"),
+			WorkerStarted("root/cache"),
+			WorkerStarted("root/repository"),
+			WorkerStarted("root/http-server"),
+			SupervisorStarted("root"),
+			// dependents are stopped (transitively) before the failure is
+			// handled, the cache does not depend on the db pool
+			WorkerTerminated("root/http-server"),
+			WorkerTerminated("root/repository"),
+			WorkerFailed("root/db-pool"),
+			WorkerStarted("root/db-pool"),
+			WorkerStarted("root/repository"),
+			WorkerStarted("root/http-server"),
+			// regular termination
+			WorkerTerminated("root/http-server"),
+			WorkerTerminated("root/repository"),
+			WorkerTerminated("root/cache"),
+			WorkerTerminated("root/db-pool"),
+			SupervisorTerminated("root"),
+		},
+	)
+
+	assert.Equal(t, cap.RestartReason, <-reasonCh)
+	assert.Equal(t, cap.ShutdownReason, <-reasonCh)
+}
+
+func TestDependenciesRestartDependentStartFailure(t *testing.T) {
+	var starts int32
+
+	// the second and third starts of the repository fail, these happen when
+	// the repository gets restarted with the db pool
+	repository := cap.NewWorkerWithNotifyStart(
+		"repository",
+		func(ctx context.Context, notifyStart cap.NotifyStartFn) error {
+			if n := atomic.AddInt32(&starts, 1); n == 2 || n == 3 {
+				err := errors.New("start failure")
+				notifyStart(err)
+				return err
+			}
+			notifyStart(nil)
+			<-ctx.Done()
+			return nil
+		},
+		cap.WithDependsOn("db-pool"),
+		cap.WithTolerance(10, time.Minute),
+	)
+
+	dbPool, failDBPool := FailOnSignalWorker(1, "db-pool")
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(dbPool, repository),
+		[]cap.Opt{},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			evIt.SkipTill(SupervisorStarted("root"))
+			failDBPool(true /* done */)
+			evIt.SkipTill(WorkerStarted("root/repository"))
+		},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&starts))
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/db-pool"),
+			WorkerStarted("root/repository"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/repository"),
+			WorkerFailed("root/db-pool"),
+			WorkerStarted("root/db-pool"),
+			// the failed start of the dependent is handled as a failure, the
+			// dependent is restarted until it starts
+			WorkerFailed("root/repository"),
+			WorkerStarted("root/repository"),
+			// regular termination
+			WorkerTerminated("root/repository"),
+			WorkerTerminated("root/db-pool"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestDependenciesErrors(t *testing.T) {
+	t.Run("unknown dependency", func(t *testing.T) {
+		_, err := ObserveSupervisor(
+			context.TODO(),
+			"root",
+			cap.WithNodes(WaitDoneWorker("child0", cap.WithDependsOn("child1"))),
+			[]cap.Opt{},
+			func(EventManager) {},
+		)
+
+		var depErr *cap.UnknownDependencyError
+		if assert.True(t, errors.As(err, &depErr)) {
+			assert.Equal(t, "root", depErr.GetRuntimeName())
+			assert.Equal(t, "child0", depErr.GetNodeName())
+			assert.Equal(t, "child1", depErr.GetDependencyName())
+		}
+	})
+
+	t.Run("dependency cycle", func(t *testing.T) {
+		cleaned := false
+
+		_, err := ObserveSupervisor(
+			context.TODO(),
+			"root",
+			func() ([]cap.Node, cap.CleanupResourcesFn, error) {
+				nodes := []cap.Node{
+					WaitDoneWorker("child0"),
+					WaitDoneWorker("child1", cap.WithDependsOn("child3")),
+					WaitDoneWorker("child2", cap.WithDependsOn("child1")),
+					WaitDoneWorker("child3", cap.WithDependsOn("child2", "child0")),
+				}
+				cleanup := func() error {
+					cleaned = true
+					return nil
+				}
+				return nodes, cleanup, nil
+			},
+			[]cap.Opt{},
+			func(EventManager) {},
+		)
+
+		var cycleErr *cap.DependencyCycleError
+		if assert.True(t, errors.As(err, &cycleErr)) {
+			assert.Equal(
+				t,
+				[]string{"child1", "child3", "child2", "child1"},
+				cycleErr.GetCycle(),
+			)
+		}
+		// resources are released when the dependencies are not valid
+		assert.True(t, cleaned)
+	})
+
+	t.Run("unknown dependency on a dynamic supervisor", func(t *testing.T) {
+		dyn, err := cap.NewDynSupervisor(context.TODO(), "root")
+		assert.NoError(t, err)
+
+		_, err = dyn.Spawn(WaitDoneWorker("child0"))
+		assert.NoError(t, err)
+
+		_, err = dyn.Spawn(WaitDoneWorker("child1", cap.WithDependsOn("child0")))
+		assert.NoError(t, err)
+
+		_, err = dyn.Spawn(WaitDoneWorker("child2", cap.WithDependsOn("child3")))
+		var depErr *cap.UnknownDependencyError
+		assert.True(t, errors.As(err, &depErr))
+
+		assert.NoError(t, dyn.Terminate())
+	})
+}
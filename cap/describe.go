@@ -0,0 +1,392 @@
+package cap
+
+// This file contains the introspection of supervision trees; it allows to get
+// the structure and settings of the nodes of a SupervisorSpec (before it
+// starts) and of a running Supervisor.
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/capatazlib/go-capataz/internal/c"
+)
+
+// NodeStatus specifies the state of a node of a running supervision tree
+type NodeStatus uint32
+
+const (
+	// UnknownStatus is the NodeStatus of the nodes described from a
+	// SupervisorSpec (e.g. they are not running)
+	UnknownStatus NodeStatus = iota
+	// RunningStatus is the NodeStatus of a node that has started and has not
+	// finished yet
+	RunningStatus
+	// StoppedStatus is the NodeStatus of a node that is not running (e.g. it
+	// completed, it failed, or it is restarting)
+	StoppedStatus
+	// LeakedStatus is the NodeStatus of a node that did not stop within its
+	// shutdown timeout, and whose goroutine is still running
+	LeakedStatus
+)
+
+// String returns a string representation of the current NodeStatus
+func (s NodeStatus) String() string {
+	switch s {
+	case UnknownStatus:
+		return "Unknown"
+	case RunningStatus:
+		return "Running"
+	case StoppedStatus:
+		return "Stopped"
+	case LeakedStatus:
+		return "Leaked"
+	default:
+		return "<Unknown>"
+	}
+}
+
+// String returns a string representation of the current Order
+func (o Order) String() string {
+	switch o {
+	case LeftToRight:
+		return "LeftToRight"
+	case RightToLeft:
+		return "RightToLeft"
+	default:
+		return "<Unknown>"
+	}
+}
+
+// NodeInfo describes a node of a supervision tree, with the settings given to
+// it. Check the Describe methods of SupervisorSpec and Supervisor.
+type NodeInfo struct {
+	name        string
+	runtimeName string
+	tag         NodeTag
+	root        bool
+
+	restart   Restart
+	shutdown  Shutdown
+	tolerance c.ErrTolerance
+	dependsOn []string
+
+	order    Order
+	children []NodeInfo
+
+	status NodeStatus
+}
+
+// GetName returns the name of the node
+func (ni NodeInfo) GetName() string {
+	return ni.name
+}
+
+// GetRuntimeName returns the runtime name of the node (e.g. root/api/db)
+func (ni NodeInfo) GetRuntimeName() string {
+	return ni.runtimeName
+}
+
+// GetTag returns the NodeTag of the node
+func (ni NodeInfo) GetTag() NodeTag {
+	return ni.tag
+}
+
+// IsRoot indicates if the node is the root supervisor of the tree; the root
+// supervisor does not have restart, tolerance nor dependencies settings
+func (ni NodeInfo) IsRoot() bool {
+	return ni.root
+}
+
+// GetRestart returns the Restart setting of the node
+func (ni NodeInfo) GetRestart() Restart {
+	return ni.restart
+}
+
+// GetShutdown returns the Shutdown setting of the node. The shutdown of the
+// root supervisor is given by its WithShutdownTimeout setting.
+func (ni NodeInfo) GetShutdown() Shutdown {
+	return ni.shutdown
+}
+
+// GetTolerance returns the error tolerance of the node, as specified with
+// WithTolerance
+func (ni NodeInfo) GetTolerance() (uint32, time.Duration) {
+	return ni.tolerance.MaxErrCount, ni.tolerance.ErrWindow
+}
+
+// GetDependencies returns the names of the siblings the node depends on
+func (ni NodeInfo) GetDependencies() []string {
+	return append([]string{}, ni.dependsOn...)
+}
+
+// GetStartOrder returns the order in which a supervisor node starts its
+// children
+func (ni NodeInfo) GetStartOrder() Order {
+	return ni.order
+}
+
+// GetChildren returns the children of a supervisor node, in the order they
+// were given to the supervisor
+func (ni NodeInfo) GetChildren() []NodeInfo {
+	return append([]NodeInfo{}, ni.children...)
+}
+
+// GetStatus returns the state of the node at the moment the supervision tree
+// got described
+func (ni NodeInfo) GetStatus() NodeStatus {
+	return ni.status
+}
+
+// nodeRegistry keeps track of the children nodes that every supervisor of a
+// supervision tree built, and of the mailboxes of the running supervisors,
+// indexed by supervisor runtime name. It is shared with all the sub-trees of a
+// root supervisor.
+type nodeRegistry struct {
+	mux       *sync.Mutex
+	children  map[string][]c.ChildSpec
+	mailboxes map[string]*supervisorMailbox
+}
+
+// supervisorMailbox allows client APIs to send control messages to a running
+// supervisor of the tree (e.g. RestartNode)
+type supervisorMailbox struct {
+	ctrlCh chan ctrlMsg
+	// doneCh gets closed once the supervisor stops handling control messages
+	doneCh chan struct{}
+
+	// mux guarantees no client is sending a message once the mailbox is
+	// closed, the root supervisor closes its ctrlCh right after
+	mux    *sync.RWMutex
+	closed bool
+}
+
+// newSupervisorMailbox creates a new supervisorMailbox
+func newSupervisorMailbox(ctrlCh chan ctrlMsg) *supervisorMailbox {
+	var mux sync.RWMutex
+
+	return &supervisorMailbox{
+		ctrlCh: ctrlCh,
+		doneCh: make(chan struct{}),
+		mux:    &mux,
+	}
+}
+
+// send blocks until the supervisor receives the given message, it returns
+// false if the mailbox gets closed first
+func (mb *supervisorMailbox) send(msg ctrlMsg) bool {
+	mb.mux.RLock()
+	defer mb.mux.RUnlock()
+
+	if mb.closed {
+		return false
+	}
+	select {
+	case mb.ctrlCh <- msg:
+		return true
+	case <-mb.doneCh:
+		return false
+	}
+}
+
+// close unblocks the clients waiting on the mailbox, once it returns, no
+// client sends messages on the ctrlCh of the supervisor. It must be called
+// only once.
+func (mb *supervisorMailbox) close() {
+	close(mb.doneCh)
+
+	mb.mux.Lock()
+	defer mb.mux.Unlock()
+	mb.closed = true
+}
+
+// newNodeRegistry creates a new nodeRegistry
+func newNodeRegistry() *nodeRegistry {
+	var mux sync.Mutex
+
+	return &nodeRegistry{
+		mux:       &mux,
+		children:  make(map[string][]c.ChildSpec),
+		mailboxes: make(map[string]*supervisorMailbox),
+	}
+}
+
+// setChildren registers the children that the given supervisor built
+func (nr *nodeRegistry) setChildren(supRuntimeName string, children []c.ChildSpec) {
+	nr.mux.Lock()
+	defer nr.mux.Unlock()
+	nr.children[supRuntimeName] = children
+}
+
+// getChildren returns the children that the given supervisor built
+func (nr *nodeRegistry) getChildren(supRuntimeName string) []c.ChildSpec {
+	nr.mux.Lock()
+	defer nr.mux.Unlock()
+	return nr.children[supRuntimeName]
+}
+
+// setMailbox registers the mailbox of the given running supervisor
+func (nr *nodeRegistry) setMailbox(supRuntimeName string, mailbox *supervisorMailbox) {
+	nr.mux.Lock()
+	defer nr.mux.Unlock()
+	nr.mailboxes[supRuntimeName] = mailbox
+}
+
+// removeMailbox removes the mailbox of the given supervisor, unless a restart
+// of the supervisor registered a new one already
+func (nr *nodeRegistry) removeMailbox(supRuntimeName string, mailbox *supervisorMailbox) {
+	nr.mux.Lock()
+	defer nr.mux.Unlock()
+	if nr.mailboxes[supRuntimeName] == mailbox {
+		delete(nr.mailboxes, supRuntimeName)
+	}
+}
+
+// getMailbox returns the mailbox of the given supervisor, if it is running
+func (nr *nodeRegistry) getMailbox(supRuntimeName string) (*supervisorMailbox, bool) {
+	nr.mux.Lock()
+	defer nr.mux.Unlock()
+	mailbox, ok := nr.mailboxes[supRuntimeName]
+	return mailbox, ok
+}
+
+// childrenSpecsFn returns the children specs of the supervisor with the given
+// runtime name
+type childrenSpecsFn = func(SupervisorSpec, string) ([]c.ChildSpec, error)
+
+// describeSupervisor returns the NodeInfo of the supervisor with the given spec
+// and runtime name, the children are described recursively
+func describeSupervisor(
+	spec SupervisorSpec,
+	supRuntimeName string,
+	getChildren childrenSpecsFn,
+) (NodeInfo, error) {
+	chSpecs, err := getChildren(spec, supRuntimeName)
+	if err != nil {
+		return NodeInfo{}, err
+	}
+
+	children := make([]NodeInfo, 0, len(chSpecs))
+	for _, chSpec := range chSpecs {
+		chRuntimeName := fmt.Sprintf("%s%s%s", supRuntimeName, nodeSepToken, chSpec.GetName())
+		chInfo := NodeInfo{
+			name:        chSpec.GetName(),
+			runtimeName: chRuntimeName,
+			tag:         chSpec.GetTag(),
+			restart:     chSpec.GetRestart(),
+			shutdown:    chSpec.Shutdown,
+			tolerance:   chSpec.ErrTolerance,
+			dependsOn:   chSpec.GetDependencies(),
+		}
+		if subtreeSpec, ok := chSpec.Subtree.(SupervisorSpec); ok {
+			subtreeInfo, err := describeSupervisor(subtreeSpec, chRuntimeName, getChildren)
+			if err != nil {
+				return NodeInfo{}, err
+			}
+			chInfo.order = subtreeInfo.order
+			chInfo.children = subtreeInfo.children
+		}
+		children = append(children, chInfo)
+	}
+
+	return NodeInfo{
+		name:        spec.GetName(),
+		runtimeName: supRuntimeName,
+		tag:         c.Supervisor,
+		order:       spec.order,
+		children:    children,
+	}, nil
+}
+
+// describeRoot returns the NodeInfo of a root supervisor
+func (spec SupervisorSpec) describeRoot(getChildren childrenSpecsFn) (NodeInfo, error) {
+	supRuntimeName := buildRuntimeName(spec, rootSupervisorName)
+	info, err := describeSupervisor(spec, supRuntimeName, getChildren)
+	if err != nil {
+		return NodeInfo{}, err
+	}
+	info.root = true
+	info.shutdown = Indefinitely
+	if spec.shutdownTimeout > 0 {
+		info.shutdown = Timeout(spec.shutdownTimeout)
+	}
+	return info, nil
+}
+
+// Describe returns the NodeInfo of the root supervisor of this spec, with the
+// settings of all the nodes of the supervision tree (e.g. its restart,
+// shutdown, tolerance and start order settings). The node overrides (check
+// WithNodeOverride) are applied to the described nodes.
+//
+// * Warning
+//
+// To get the nodes of the tree, this method calls the BuildNodesFn function of
+// every supervisor, and the returned CleanupResourcesFn right after; the nodes
+// are never started. If a BuildNodesFn function fails, its error is returned.
+//
+func (spec SupervisorSpec) Describe() (NodeInfo, error) {
+	return spec.describeRoot(
+		func(supSpec SupervisorSpec, supRuntimeName string) ([]c.ChildSpec, error) {
+			chSpecs, cleanup, err := supSpec.buildChildrenSpecs(supRuntimeName)
+			if err != nil {
+				return nil, err
+			}
+			if cleanup != nil {
+				if err := cleanup(); err != nil {
+					return nil, &SupervisorError{
+						supRuntimeName: supRuntimeName,
+						rscCleanupErr:  err,
+					}
+				}
+			}
+			return chSpecs, nil
+		},
+	)
+}
+
+// Describe returns the NodeInfo of this supervisor, with the settings of all
+// the nodes of the supervision tree and their NodeStatus at the moment of the
+// call.
+//
+// The described nodes are the ones the supervisors of the tree built on their
+// last (re)start; no BuildNodesFn function gets called.
+//
+func (sup Supervisor) Describe() NodeInfo {
+	running := make(map[string]bool)
+	for _, name := range sup.runningNodes.list() {
+		running[name] = true
+	}
+	leaked := make(map[string]bool)
+	for _, node := range sup.leakedNodes.list() {
+		leaked[node.GetRuntimeName()] = true
+	}
+
+	info, _ := sup.spec.describeRoot(
+		func(_ SupervisorSpec, supRuntimeName string) ([]c.ChildSpec, error) {
+			if sup.spec.nodeRegistry == nil {
+				return []c.ChildSpec{}, nil
+			}
+			return sup.spec.nodeRegistry.getChildren(supRuntimeName), nil
+		},
+	)
+	return info.withStatus(running, leaked)
+}
+
+// withStatus returns a copy of this NodeInfo (and its children) with the
+// NodeStatus given by the running and leaked nodes
+func (ni NodeInfo) withStatus(running, leaked map[string]bool) NodeInfo {
+	switch {
+	case leaked[ni.runtimeName]:
+		ni.status = LeakedStatus
+	case running[ni.runtimeName]:
+		ni.status = RunningStatus
+	default:
+		ni.status = StoppedStatus
+	}
+	children := make([]NodeInfo, 0, len(ni.children))
+	for _, child := range ni.children {
+		children = append(children, child.withStatus(running, leaked))
+	}
+	ni.children = children
+	return ni
+}
@@ -0,0 +1,160 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// describedSpec returns a supervision tree with a sub-tree, dependencies and a
+// node override
+func describedSpec(nodes ...cap.Node) cap.SupervisorSpec {
+	b0 := cap.NewSupervisorSpec(
+		"api",
+		cap.WithNodes(
+			WaitDoneWorker("listen-and-serve"),
+			WaitDoneWorker("wait-server", cap.WithDependsOn("listen-and-serve")),
+		),
+		cap.WithStartOrder(cap.RightToLeft),
+	)
+	return cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(
+			append(
+				[]cap.Node{
+					cap.Subtree(b0, cap.WithTolerance(3, time.Minute)),
+					WaitDoneWorker("cache", cap.WithRestart(cap.Transient)),
+				},
+				nodes...,
+			)...,
+		),
+		cap.WithShutdownTimeout(10*time.Second),
+		cap.WithNodeOverride("root/api/wait-server", cap.WithShutdown(cap.Timeout(time.Second))),
+	)
+}
+
+func TestDescribeSpec(t *testing.T) {
+	info, err := describedSpec().Describe()
+	assert.NoError(t, err)
+
+	assert.True(t, info.IsRoot())
+	assert.Equal(t, "root", info.GetRuntimeName())
+	assert.Equal(t, cap.Timeout(10*time.Second), info.GetShutdown())
+
+	children := info.GetChildren()
+	if assert.Len(t, children, 2) {
+		api := children[0]
+		assert.Equal(t, cap.SupervisorT, api.GetTag())
+		assert.Equal(t, cap.RightToLeft, api.GetStartOrder())
+		maxErrCount, errWindow := api.GetTolerance()
+		assert.Equal(t, uint32(3), maxErrCount)
+		assert.Equal(t, time.Minute, errWindow)
+
+		apiChildren := api.GetChildren()
+		if assert.Len(t, apiChildren, 2) {
+			assert.Equal(t, "root/api/wait-server", apiChildren[1].GetRuntimeName())
+			assert.Equal(t, []string{"listen-and-serve"}, apiChildren[1].GetDependencies())
+			// the node override got applied
+			assert.Equal(t, cap.Timeout(time.Second), apiChildren[1].GetShutdown())
+		}
+
+		assert.Equal(t, cap.Transient, children[1].GetRestart())
+		assert.Equal(t, cap.UnknownStatus, children[1].GetStatus())
+	}
+}
+
+func TestDescribeSpecBuildError(t *testing.T) {
+	buildErr := errors.New("resource allocation failed")
+	spec := cap.NewSupervisorSpec(
+		"root",
+		func() ([]cap.Node, cap.CleanupResourcesFn, error) {
+			return nil, nil, buildErr
+		},
+	)
+	_, err := spec.Describe()
+	assert.Equal(t, buildErr, err)
+}
+
+func TestRenderSpecASCII(t *testing.T) {
+	info, err := describedSpec().Describe()
+	assert.NoError(t, err)
+
+	expected := strings.Join([]string{
+		"+ root (shutdown: Timeout(10s), start order: LeftToRight)",
+		"|",
+		"+ api (Permanent, shutdown: Indefinitely, tolerance: 3 in 1m0s, start order: RightToLeft)",
+		"| |",
+		"| ` listen-and-serve (Permanent, shutdown: Timeout(5s), tolerance: 1 in 5s)",
+		"| |",
+		"| ` wait-server (Permanent, shutdown: Timeout(1s), tolerance: 1 in 5s, depends on: listen-and-serve)",
+		"|",
+		"` cache (Transient, shutdown: Timeout(5s), tolerance: 1 in 5s)",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, info.RenderASCII())
+}
+
+func TestRenderSpecDOT(t *testing.T) {
+	info, err := describedSpec().Describe()
+	assert.NoError(t, err)
+
+	dot := info.RenderDOT()
+	assert.True(t, strings.HasPrefix(dot, "digraph \"root\" {\n"))
+	assert.Contains(t, dot, `"root/api" [shape=folder, color=black, label="api\nPermanent\nshutdown: Indefinitely\ntolerance: 3 in 1m0s\nstart order: RightToLeft"];`)
+	assert.Contains(t, dot, `"root" -> "root/api";`)
+	assert.Contains(t, dot, `"root/api/wait-server" -> "root/api/listen-and-serve" [style=dashed, label="depends on"];`)
+}
+
+func TestDescribeSupervisor(t *testing.T) {
+	completing, completeWorker := CompleteOnSignalWorker(1, "job", cap.WithRestart(cap.Transient))
+
+	evManager := NewEventManager()
+	evManager.StartCollector(context.TODO())
+
+	spec := describedSpec(completing)
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(cap.Subtree(spec)),
+		cap.WithNotifier(evManager.EventCollector(context.TODO())),
+	).Start(context.TODO())
+	assert.NoError(t, err)
+
+	evIt := evManager.Iterator()
+	evIt.SkipTill(SupervisorStarted("root"))
+	completeWorker()
+	evIt.SkipTill(WorkerCompleted("root/root/job"))
+
+	info := sup.Describe()
+	assert.Equal(t, cap.RunningStatus, info.GetStatus())
+
+	subtree := info.GetChildren()[0]
+	assert.Equal(t, "root/root", subtree.GetRuntimeName())
+	assert.Equal(t, cap.RunningStatus, subtree.GetStatus())
+
+	children := subtree.GetChildren()
+	if assert.Len(t, children, 3) {
+		assert.Equal(t, cap.RunningStatus, children[0].GetStatus())
+		assert.Equal(t, cap.RunningStatus, children[0].GetChildren()[1].GetStatus())
+		// the completed worker is not restarted
+		assert.Equal(t, "root/root/job", children[2].GetRuntimeName())
+		assert.Equal(t, cap.StoppedStatus, children[2].GetStatus())
+	}
+
+	assert.Contains(
+		t,
+		info.RenderASCII(),
+		"  ` job (Transient, shutdown: Timeout(5s), tolerance: 1 in 5s) [Stopped]\n",
+	)
+	assert.Contains(t, info.RenderDOT(), `"root/root/job" [shape=box, color=gray,`)
+
+	assert.NoError(t, sup.Terminate())
+	assert.Equal(t, cap.StoppedStatus, sup.Describe().GetStatus())
+}
@@ -0,0 +1,346 @@
+package cap
+
+// This file contains the static validation and the dry-run of a
+// SupervisorSpec; these walk the spec tree without spawning goroutines, so
+// that mistakes can be found before the supervision tree starts.
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/capatazlib/go-capataz/internal/c"
+)
+
+// Severity specifies how serious a Finding is
+type Severity uint32
+
+const (
+	// InfoSeverity is used for findings that do not require any action
+	InfoSeverity Severity = iota
+	// WarningSeverity is used for findings that may cause problems at runtime
+	WarningSeverity
+	// ErrorSeverity is used for findings that make the supervision tree fail
+	// (or misbehave) at runtime
+	ErrorSeverity
+)
+
+// String returns a string representation of the current Severity
+func (s Severity) String() string {
+	switch s {
+	case InfoSeverity:
+		return "Info"
+	case WarningSeverity:
+		return "Warning"
+	case ErrorSeverity:
+		return "Error"
+	default:
+		return "<Unknown>"
+	}
+}
+
+// Finding is an issue found on a node of a SupervisorSpec by Validate
+type Finding struct {
+	severity    Severity
+	runtimeName string
+	message     string
+}
+
+// GetSeverity returns how serious the finding is
+func (f Finding) GetSeverity() Severity {
+	return f.severity
+}
+
+// GetRuntimeName returns the runtime name of the node the finding is about
+func (f Finding) GetRuntimeName() string {
+	return f.runtimeName
+}
+
+// GetMessage returns the description of the finding
+func (f Finding) GetMessage() string {
+	return f.message
+}
+
+// String returns an string representation for the Finding
+func (f Finding) String() string {
+	return fmt.Sprintf("[%s] %s: %s", f.severity, f.runtimeName, f.message)
+}
+
+// dryRunSettings contains the settings of a dry-run
+type dryRunSettings struct {
+	skipPatterns []string
+}
+
+// skipsBuildNodes indicates if the BuildNodesFn of the supervisor with the
+// given runtime name must not be called
+func (s dryRunSettings) skipsBuildNodes(supRuntimeName string) bool {
+	for _, pattern := range s.skipPatterns {
+		// the pattern is validated when the option is created
+		if matched, _ := path.Match(pattern, supRuntimeName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// DryRunOpt is a type used to configure the Validate and DryRun methods of
+// SupervisorSpec
+type DryRunOpt func(*dryRunSettings)
+
+// WithSkipBuildNodes is a DryRunOpt that skips the call to the BuildNodesFn
+// function of the supervisors with a runtime name that matches the given
+// pattern (e.g. the ones that allocate resources); these supervisors are
+// reported without children. The pattern follows the syntax of path.Match,
+// like the one of WithNodeOverride.
+//
+// The given pattern must be valid, otherwise, the system will panic.
+//
+func WithSkipBuildNodes(pattern string) DryRunOpt {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(fmt.Sprintf("invalid skip build nodes pattern %q: %v", pattern, err))
+	}
+	return func(s *dryRunSettings) {
+		s.skipPatterns = append(s.skipPatterns, pattern)
+	}
+}
+
+// DryRunPlan is the result of the dry-run of a SupervisorSpec. It contains the
+// runtime names of the nodes in the order they would report they started and
+// terminated, and the findings of the static validation of the spec.
+type DryRunPlan struct {
+	startSequence       []string
+	terminationSequence []string
+	findings            []Finding
+}
+
+// GetStartSequence returns the runtime names of the nodes in the order they
+// would report they started (e.g. a supervisor starts after its children).
+func (p DryRunPlan) GetStartSequence() []string {
+	return append([]string{}, p.startSequence...)
+}
+
+// GetTerminationSequence returns the runtime names of the nodes in the order
+// they would report they terminated (e.g. a supervisor terminates after its
+// children).
+func (p DryRunPlan) GetTerminationSequence() []string {
+	return append([]string{}, p.terminationSequence...)
+}
+
+// GetFindings returns the issues found on the nodes of the spec
+func (p DryRunPlan) GetFindings() []Finding {
+	return append([]Finding{}, p.findings...)
+}
+
+// HasErrors indicates if any of the findings has an ErrorSeverity
+func (p DryRunPlan) HasErrors() bool {
+	for _, f := range p.findings {
+		if f.severity == ErrorSeverity {
+			return true
+		}
+	}
+	return false
+}
+
+// dryRun contains the state of a dry-run that walks the spec tree
+type dryRun struct {
+	settings dryRunSettings
+	plan     DryRunPlan
+}
+
+// addFinding registers a finding on the plan of the dry-run
+func (dr *dryRun) addFinding(severity Severity, runtimeName, format string, args ...interface{}) {
+	dr.plan.findings = append(dr.plan.findings, Finding{
+		severity:    severity,
+		runtimeName: runtimeName,
+		message:     fmt.Sprintf(format, args...),
+	})
+}
+
+// buildChildrenSpecs returns the children specs of the given supervisor, the
+// errors and panics of the BuildNodesFn function (and of the nodes) are
+// reported as findings
+func (dr *dryRun) buildChildrenSpecs(
+	spec SupervisorSpec,
+	supRuntimeName string,
+) (children []c.ChildSpec, ok bool) {
+	defer func() {
+		if panicVal := recover(); panicVal != nil {
+			dr.addFinding(ErrorSeverity, supRuntimeName, "building nodes panicked: %v", panicVal)
+			children, ok = nil, false
+		}
+	}()
+
+	nodes, cleanup, err := spec.buildNodes()
+	if err != nil {
+		dr.addFinding(ErrorSeverity, supRuntimeName, "building nodes failed: %v", err)
+		return nil, false
+	}
+
+	children = make([]c.ChildSpec, 0, len(nodes))
+	for _, node := range nodes {
+		children = append(children, spec.buildChildSpec(supRuntimeName, node))
+	}
+
+	if cleanup != nil {
+		if err := cleanup(); err != nil {
+			dr.addFinding(ErrorSeverity, supRuntimeName, "resource cleanup failed: %v", err)
+		}
+	}
+	return children, true
+}
+
+// validateChildren reports the issues of the given children of a supervisor,
+// it returns the children without the ones with a duplicate name
+func (dr *dryRun) validateChildren(supRuntimeName string, children []c.ChildSpec) []c.ChildSpec {
+	if len(children) == 0 {
+		dr.addFinding(WarningSeverity, supRuntimeName, "supervisor has no children nodes")
+		return children
+	}
+
+	unique := make([]c.ChildSpec, 0, len(children))
+	seen := make(map[string]bool, len(children))
+	for _, chSpec := range children {
+		chRuntimeName := fmt.Sprintf("%s%s%s", supRuntimeName, nodeSepToken, chSpec.GetName())
+
+		if strings.Contains(chSpec.GetName(), nodeSepToken) {
+			dr.addFinding(ErrorSeverity, chRuntimeName, "node name must not contain %q", nodeSepToken)
+		}
+		if seen[chSpec.GetName()] {
+			dr.addFinding(ErrorSeverity, chRuntimeName, "duplicate node name %q", chSpec.GetName())
+			continue
+		}
+		seen[chSpec.GetName()] = true
+		unique = append(unique, chSpec)
+
+		if chSpec.IsWorker() && chSpec.Shutdown == Indefinitely {
+			dr.addFinding(
+				WarningSeverity,
+				chRuntimeName,
+				"worker has an Indefinitely shutdown, its supervisor blocks forever if it does not stop",
+			)
+		}
+		if chSpec.GetRestart() != Temporary && chSpec.ErrTolerance.MaxErrCount == 0 {
+			dr.addFinding(
+				WarningSeverity,
+				chRuntimeName,
+				"node has a zero error tolerance, its first error makes its supervisor fail",
+			)
+		}
+	}
+
+	if depErr := validateNodeDependencies(supRuntimeName, unique); depErr != nil {
+		dr.addFinding(ErrorSeverity, supRuntimeName, "%v", depErr)
+	}
+	return unique
+}
+
+// walk validates the given supervisor, it returns the children specs of the
+// supervisor (if they were built); only the first of the children with the
+// same name is returned
+func (dr *dryRun) walk(spec SupervisorSpec, supRuntimeName string) []c.ChildSpec {
+	if strings.Contains(spec.GetName(), nodeSepToken) {
+		dr.addFinding(ErrorSeverity, supRuntimeName, "supervisor name must not contain %q", nodeSepToken)
+	}
+
+	if dr.settings.skipsBuildNodes(supRuntimeName) {
+		dr.addFinding(InfoSeverity, supRuntimeName, "building nodes skipped, children are unknown")
+		return nil
+	}
+
+	children, ok := dr.buildChildrenSpecs(spec, supRuntimeName)
+	if !ok {
+		return nil
+	}
+	return dr.validateChildren(supRuntimeName, children)
+}
+
+// planSupervisor walks the given supervisor and its descendants, and registers them on
+// the start and termination sequences
+func (dr *dryRun) planSupervisor(spec SupervisorSpec, supRuntimeName string) {
+	children := dr.walk(spec, supRuntimeName)
+
+	// the termination sequence is built after the start one, we keep the
+	// sequences of the sub-trees to avoid building their nodes twice
+	subtreeSeqs := make(map[string][]string)
+
+	for _, chSpec := range spec.sortStart(children) {
+		chRuntimeName := fmt.Sprintf("%s%s%s", supRuntimeName, nodeSepToken, chSpec.GetName())
+		subtreeSpec, ok := chSpec.Subtree.(SupervisorSpec)
+		if !ok {
+			dr.plan.startSequence = append(dr.plan.startSequence, chRuntimeName)
+			continue
+		}
+		// the sub-tree registers its own termination sequence, we move it to
+		// the right place afterwards
+		termStart := len(dr.plan.terminationSequence)
+		dr.planSupervisor(subtreeSpec, chRuntimeName)
+		subtreeSeqs[chRuntimeName] = append(
+			[]string{},
+			dr.plan.terminationSequence[termStart:]...,
+		)
+		dr.plan.terminationSequence = dr.plan.terminationSequence[:termStart]
+	}
+	dr.plan.startSequence = append(dr.plan.startSequence, supRuntimeName)
+
+	for _, chSpec := range spec.sortTermination(children) {
+		chRuntimeName := fmt.Sprintf("%s%s%s", supRuntimeName, nodeSepToken, chSpec.GetName())
+		if subtreeSeq, ok := subtreeSeqs[chRuntimeName]; ok {
+			dr.plan.terminationSequence = append(dr.plan.terminationSequence, subtreeSeq...)
+			continue
+		}
+		dr.plan.terminationSequence = append(dr.plan.terminationSequence, chRuntimeName)
+	}
+	dr.plan.terminationSequence = append(dr.plan.terminationSequence, supRuntimeName)
+}
+
+// DryRun walks the spec tree without spawning any goroutine, and returns the
+// planned start and termination sequences of its nodes, together with the
+// findings of a static validation (check Validate).
+//
+// The sequences follow the start order of every supervisor (check
+// WithStartOrder and WithDependsOn); the sequences of supervisors with a
+// WithParallelStart or WithParallelTermination setting may differ at runtime.
+//
+// * Warning
+//
+// To get the nodes of the tree, this method calls the BuildNodesFn function of
+// every supervisor, and the returned CleanupResourcesFn right after. Use
+// WithSkipBuildNodes to avoid calling the BuildNodesFn function of supervisors
+// that allocate resources.
+//
+func (spec SupervisorSpec) DryRun(opts ...DryRunOpt) DryRunPlan {
+	dr := &dryRun{
+		plan: DryRunPlan{
+			startSequence:       []string{},
+			terminationSequence: []string{},
+			findings:            []Finding{},
+		},
+	}
+	for _, optFn := range opts {
+		optFn(&dr.settings)
+	}
+	dr.planSupervisor(spec, buildRuntimeName(spec, rootSupervisorName))
+	return dr.plan
+}
+
+// Validate walks the spec tree without spawning any goroutine, and returns the
+// issues found on its nodes, with their Severity. It checks:
+//
+// * Nodes with duplicate names, or with names that contain a `/` character
+// (ErrorSeverity)
+//
+// * Dependencies between nodes that are unknown or have cycles (ErrorSeverity)
+//
+// * BuildNodesFn functions that fail or panic (ErrorSeverity)
+//
+// * Workers with an Indefinitely shutdown (WarningSeverity)
+//
+// * Nodes with a zero error tolerance that get restarted (WarningSeverity)
+//
+// * Supervisors without children nodes (WarningSeverity)
+//
+// The same warning of DryRun applies to this method.
+//
+func (spec SupervisorSpec) Validate(opts ...DryRunOpt) []Finding {
+	return spec.DryRun(opts...).GetFindings()
+}
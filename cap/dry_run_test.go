@@ -0,0 +1,158 @@
+package cap_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// findingsBySeverity returns the "runtimeName: message" of the given findings
+// with the given severity
+func findingsBySeverity(findings []cap.Finding, severity cap.Severity) []string {
+	out := []string{}
+	for _, f := range findings {
+		if f.GetSeverity() == severity {
+			out = append(out, f.GetRuntimeName()+": "+f.GetMessage())
+		}
+	}
+	return out
+}
+
+func TestDryRunSequences(t *testing.T) {
+	plan := describedSpec(WaitDoneWorker("metrics", cap.WithDependsOn("cache"))).DryRun()
+
+	assert.False(t, plan.HasErrors())
+	assert.Empty(t, plan.GetFindings())
+
+	assert.Equal(
+		t,
+		[]string{
+			"root/api/listen-and-serve",
+			"root/api/wait-server",
+			"root/api",
+			"root/cache",
+			"root/metrics",
+			"root",
+		},
+		plan.GetStartSequence(),
+	)
+	assert.Equal(
+		t,
+		[]string{
+			"root/metrics",
+			"root/cache",
+			"root/api/wait-server",
+			"root/api/listen-and-serve",
+			"root/api",
+			"root",
+		},
+		plan.GetTerminationSequence(),
+	)
+}
+
+func TestDryRunSkipBuildNodes(t *testing.T) {
+	called := false
+	b0 := cap.NewSupervisorSpec(
+		"db",
+		func() ([]cap.Node, cap.CleanupResourcesFn, error) {
+			called = true
+			return []cap.Node{WaitDoneWorker("conn")}, nil, nil
+		},
+	)
+	spec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(cap.Subtree(b0), WaitDoneWorker("cache")),
+	)
+
+	plan := spec.DryRun(cap.WithSkipBuildNodes("root/db"))
+
+	assert.False(t, called)
+	assert.Equal(t, []string{"root/db", "root/cache", "root"}, plan.GetStartSequence())
+	assert.Equal(t, []string{"root/cache", "root/db", "root"}, plan.GetTerminationSequence())
+	assert.Equal(
+		t,
+		[]string{"root/db: building nodes skipped, children are unknown"},
+		findingsBySeverity(plan.GetFindings(), cap.InfoSeverity),
+	)
+
+	plan = spec.DryRun()
+	assert.True(t, called)
+	assert.Equal(
+		t,
+		[]string{"root/db/conn", "root/db", "root/cache", "root"},
+		plan.GetStartSequence(),
+	)
+}
+
+func TestDryRunInvalidSkipPattern(t *testing.T) {
+	assert.Panics(t, func() {
+		cap.WithSkipBuildNodes("root/[")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	empty := cap.NewSupervisorSpec("empty", cap.WithNodes())
+	spec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(
+			WaitDoneWorker("child0"),
+			WaitDoneWorker("child0"),
+			WaitDoneWorker("child1", cap.WithShutdown(cap.Indefinitely)),
+			WaitDoneWorker("child2", cap.WithTolerance(0, time.Second)),
+			WaitDoneWorker("child3", cap.WithTolerance(0, time.Second), cap.WithRestart(cap.Temporary)),
+			WaitDoneWorker("child4", cap.WithDependsOn("unknown")),
+			cap.Subtree(empty),
+		),
+	)
+
+	findings := spec.Validate()
+
+	assert.Equal(
+		t,
+		[]string{
+			`root/child0: duplicate node name "child0"`,
+			`root: node child4 depends on unknown node unknown`,
+		},
+		findingsBySeverity(findings, cap.ErrorSeverity),
+	)
+	assert.Equal(
+		t,
+		[]string{
+			"root/child1: worker has an Indefinitely shutdown, its supervisor blocks forever if it does not stop",
+			"root/child2: node has a zero error tolerance, its first error makes its supervisor fail",
+			"root/empty: supervisor has no children nodes",
+		},
+		findingsBySeverity(findings, cap.WarningSeverity),
+	)
+}
+
+func TestValidateBuildNodesFailure(t *testing.T) {
+	b0 := cap.NewSupervisorSpec(
+		"db",
+		func() ([]cap.Node, cap.CleanupResourcesFn, error) {
+			return nil, nil, errors.New("connection refused")
+		},
+	)
+	b1 := cap.NewSupervisorSpec(
+		"broken",
+		func() ([]cap.Node, cap.CleanupResourcesFn, error) {
+			panic("boom")
+		},
+	)
+	spec := cap.NewSupervisorSpec("root", cap.WithNodes(cap.Subtree(b0), cap.Subtree(b1)))
+
+	plan := spec.DryRun()
+
+	assert.True(t, plan.HasErrors())
+	if assert.Len(t, plan.GetFindings(), 2) {
+		assert.Equal(t, "root/db", plan.GetFindings()[0].GetRuntimeName())
+		assert.Contains(t, plan.GetFindings()[0].GetMessage(), "connection refused")
+		assert.Equal(t, "[Error] root/broken: building nodes panicked: boom", plan.GetFindings()[1].String())
+	}
+	assert.Equal(t, []string{"root/db", "root/broken", "root"}, plan.GetStartSequence())
+}
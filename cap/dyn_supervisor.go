@@ -23,6 +23,20 @@ type ctrlMsg interface {
 	) ([]c.ChildSpec, map[string]c.Child)
 }
 
+// replaceChildSpec returns the given children specs with the given child spec
+// in place of the one with the same name, the child spec is appended when
+// there is none
+func replaceChildSpec(specChildren []c.ChildSpec, childSpec c.ChildSpec) []c.ChildSpec {
+	for i, prevSpec := range specChildren {
+		if prevSpec.GetName() == childSpec.GetName() {
+			output := append(specChildren[:0:0], specChildren...)
+			output[i] = childSpec
+			return output
+		}
+	}
+	return append(specChildren, childSpec)
+}
+
 type startChildResult struct {
 	childName string
 	startErr  startError
@@ -46,16 +60,36 @@ func (scm startChildMsg) processMsg(
 ) ([]c.ChildSpec, map[string]c.Child) {
 	// REMEMBER: WE ARE RUNNING THIS CODE IN THE SUPERVISOR THREAD
 
-	childSpec := scm.node(spec)
+	childSpec := spec.buildChildSpec(supRuntimeName, scm.node)
+
+	// the dependencies of the new child must be children of this supervisor
+	depErr := validateNodeDependencies(
+		supRuntimeName,
+		append(specChildren[:len(specChildren):len(specChildren)], childSpec),
+	)
+	if depErr != nil {
+		// do not block waiting for a read
+		select {
+		case scm.resultChan <- startChildResult{
+			childName: "",
+			startErr:  depErr,
+		}:
+		default:
+		}
+		return specChildren, supChildren
+	}
 
-	ch, startErr := startChildNode(spec, supRuntimeName, supNotifyCh, childSpec)
+	ch, startErr := startChildNode(
+		context.Background(),
+		spec,
+		supRuntimeName,
+		supNotifyCh,
+		childSpec,
+	)
 	if startErr != nil {
-		// When we fail, we send an error to the supNotifyCh and return the error,
-		// this doesn't have any detrimental consequence in static supervisors,
-		// but on dynamic supervisors, it means the monitor loop will get bothered
-		// with an error that it should not really handle. We are going to read it
-		// out and return after that.
-		_ = <-supNotifyCh
+		// NOTE: a child that fails to start does not send a notification to the
+		// supNotifyCh, there is nothing else to cleanup
+		//
 		// do not block waiting for a read
 		select {
 		case scm.resultChan <- startChildResult{
@@ -73,7 +107,11 @@ func (scm startChildMsg) processMsg(
 	// when the supervisor is terminated in the correct order. This won't have
 	// unintended side-effects because a DynSupervisor once terminated, cannot
 	// be started again.
-	specChildren = append(specChildren, childSpec)
+	//
+	// A node may be spawned again with the name of a node that finished, in
+	// which case the spec of the previous node is replaced; children specs must
+	// have unique names (e.g. to sort them by their dependencies).
+	specChildren = replaceChildSpec(specChildren, childSpec)
 	supChildren[ch.GetName()] = ch
 
 	select {
@@ -122,7 +160,7 @@ func (tcm terminateChildMsg) processMsg(
 
 	// we call our basic terminateChildNode function that is found in the
 	// monitor.go file
-	terminateErr := terminateChildNode(evNotifier, ch)
+	terminateErr := terminateChildNode(evNotifier, ch, c.CancelReason)
 
 	// do not block waiting for a read
 	select {
@@ -142,7 +180,12 @@ func (tcm terminateChildMsg) processMsg(
 	return specChildren, supChildren
 }
 
-var _ ctrlMsg = terminateChildMsg{}
+// getNodeName returns the name of the child node that must be terminated
+func (tcm terminateChildMsg) getNodeName() string {
+	return tcm.nodeName
+}
+
+var _ nodeCtrlMsg = terminateChildMsg{}
 
 // DynSupervisor is a supervisor that can spawn workers in a procedural way.
 type DynSupervisor struct {
@@ -280,12 +323,67 @@ func (dyn *DynSupervisor) Terminate() error {
 	return dyn.terminationErr
 }
 
+// TerminateContext is a synchronous procedure that halts the execution of the
+// whole supervision tree. It returns a TerminationPendingError when the given
+// context is done before the supervision tree finishes its termination.
+func (dyn *DynSupervisor) TerminateContext(ctx context.Context) error {
+	terminationErr := dyn.sup.TerminateContext(ctx)
+
+	// the termination is still in progress, we cannot register the final
+	// state of the supervisor yet
+	var pendingErr *TerminationPendingError
+	if errors.As(terminationErr, &pendingErr) {
+		return terminationErr
+	}
+
+	dyn.terminationErr = terminationErr
+	dyn.terminated = true
+	return dyn.terminationErr
+}
+
 // Wait blocks the execution of the current goroutine until the Supervisor
 // finishes it execution.
 func (dyn DynSupervisor) Wait() error {
 	return dyn.sup.Wait()
 }
 
+// WaitContext blocks the execution of the current goroutine until the
+// Supervisor finishes it execution, or until the given context is done.
+func (dyn DynSupervisor) WaitContext(ctx context.Context) error {
+	return dyn.sup.WaitContext(ctx)
+}
+
+// Done returns a channel that gets closed when the supervision tree finishes
+// its execution, either because it was terminated or because it crashed.
+func (dyn DynSupervisor) Done() <-chan struct{} {
+	return dyn.sup.Done()
+}
+
+// Err returns the error that made the supervision tree finish its execution.
+// It returns nil if the channel returned by Done is not closed yet, or when
+// the supervision tree was terminated without errors.
+func (dyn DynSupervisor) Err() error {
+	return dyn.sup.Err()
+}
+
+// GetNodeOverrideMatches returns the overrides given with WithNodeOverride
+// that matched nodes of the dynamic supervisor, sorted by runtime name
+func (dyn DynSupervisor) GetNodeOverrideMatches() []NodeOverrideMatch {
+	return dyn.sup.GetNodeOverrideMatches()
+}
+
+// GetLeakedNodes returns the nodes of the dynamic supervisor that did not stop
+// within their shutdown timeout and are still running
+func (dyn DynSupervisor) GetLeakedNodes() []LeakedNode {
+	return dyn.sup.GetLeakedNodes()
+}
+
+// GetRunningNodes returns the runtime names of the nodes of the dynamic
+// supervisor that are running, in lexicographical order
+func (dyn DynSupervisor) GetRunningNodes() []string {
+	return dyn.sup.GetRunningNodes()
+}
+
 // GetName returns the name of the Spec used to start this Supervisor
 func (dyn DynSupervisor) GetName() string {
 	return dyn.sup.GetName()
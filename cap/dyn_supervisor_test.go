@@ -2,7 +2,7 @@ package cap_test
 
 //
 // NOTE: If you feel it is counter-intuitive to have workers start before
-// supervisors in the assertions bellow, check stest/README.md
+// supervisors in the assertions bellow, check internal/stest/README.md
 //
 
 import (
@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
 	. "github.com/capatazlib/go-capataz/internal/stest"
 )
 
@@ -375,6 +376,45 @@ func TestDynCancelAlreadyTerminatedWorker(t *testing.T) {
 	)
 }
 
+func TestDynSpawnAgainWithSameName(t *testing.T) {
+	worker1, completeWorker1 := CompleteOnSignalWorker(1, "one", cap.WithRestart(cap.Temporary))
+
+	events, errs := ObserveDynSupervisor(
+		context.TODO(),
+		"root",
+		[]cap.Node{},
+		[]cap.Opt{},
+		func(sup cap.DynSupervisor, em EventManager) {
+			evIt := em.Iterator()
+
+			_, err := sup.Spawn(worker1)
+			assert.NoError(t, err)
+
+			evIt.SkipTill(WorkerStarted("root/one"))
+			completeWorker1()
+			evIt.SkipTill(WorkerCompleted("root/one"))
+
+			// the name of a worker that completed may be used again
+			_, err = sup.Spawn(WaitDoneWorker("one"))
+			assert.NoError(t, err)
+		},
+	)
+
+	assert.Empty(t, errs)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			SupervisorStarted("root"),
+			WorkerStarted("root/one"),
+			WorkerCompleted("root/one"),
+			WorkerStarted("root/one"),
+			WorkerTerminated("root/one"),
+			// ^^^ triggered by supervisor termination
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
 func TestDynCancelAlreadyTerminatedSupervisor(t *testing.T) {
 	sup, err := cap.NewDynSupervisor(context.TODO(), "root")
 	assert.NoError(t, err)
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/capatazlib/go-capataz/internal/c"
 )
@@ -16,6 +17,16 @@ type startError = error
 // termination of a worker fails
 type terminateError = error
 
+// ShutdownTimeoutError is the error reported when a node does not stop within
+// the time specified in its WithShutdown setting. Given there is no way to kill
+// a goroutine, the goroutine of the node may still be running in memory (e.g.
+// it leaked); check the GetLeakedNodes method of Supervisor for details.
+//
+// This error contains a stack dump of the node's goroutines at the moment the
+// timeout was reached (check GetStackDump), it is reported on the ProcessFailed
+// event of the node and on the termination error of its supervisor.
+type ShutdownTimeoutError = c.ShutdownTimeoutError
+
 // SupervisorError wraps an error from a supervised
 // worker, enhancing it with supervisor information and possible shutdown errors
 // on other siblings
@@ -24,6 +35,7 @@ type SupervisorError struct {
 	rscCleanupErr  error
 	nodeErr        error
 	nodeErrMap     map[string]error
+	pendingNodes   []string
 }
 
 // Unwrap returns anj error from a supervised goroutine (if any)
@@ -41,6 +53,28 @@ func (se *SupervisorError) GetRuntimeName() string {
 	return se.supRuntimeName
 }
 
+// GetNodeErrors returns the errors of the nodes that failed to terminate
+// correctly, indexed by node name. When a node did not stop within its
+// shutdown timeout, its error is a ShutdownTimeoutError that contains the
+// stack dump of the node's goroutines. Supervisors that start their children
+// in parallel (check WithParallelStart) also report the start errors of the
+// nodes that failed to start.
+func (se *SupervisorError) GetNodeErrors() map[string]error {
+	nodeErrMap := make(map[string]error, len(se.nodeErrMap))
+	for chKey, chErr := range se.nodeErrMap {
+		nodeErrMap[chKey] = chErr
+	}
+	return nodeErrMap
+}
+
+// GetPendingNodes returns the runtime names of the nodes that were never
+// started because the start of a sibling failed, in start order; it is only
+// reported by supervisors that start their children in parallel (check
+// WithParallelStart)
+func (se *SupervisorError) GetPendingNodes() []string {
+	return se.pendingNodes
+}
+
 // NodeFailCount returns the number of nodes that failed to terminate correctly.
 // Note if a goroutine fails to terminate because of a shutdown timeout, the
 // failed goroutines may leak. This happens because go doesn't offer any true
@@ -55,6 +89,10 @@ func (se *SupervisorError) KVs() map[string]interface{} {
 	kvs["supervisor.name"] = se.supRuntimeName
 	for chKey, chErr := range se.nodeErrMap {
 		kvs[fmt.Sprintf("supervisor.node.%v.stop.error", chKey)] = chErr.Error()
+		var timeoutErr *ShutdownTimeoutError
+		if errors.As(chErr, &timeoutErr) && timeoutErr.GetStackDump() != "" {
+			kvs[fmt.Sprintf("supervisor.node.%v.stop.stack_dump", chKey)] = timeoutErr.GetStackDump()
+		}
 	}
 	if se.nodeErr != nil {
 		kvs["supervisor.termination.error"] = se.nodeErr.Error()
@@ -62,6 +100,9 @@ func (se *SupervisorError) KVs() map[string]interface{} {
 	if se.rscCleanupErr != nil {
 		kvs["supervisor.cleanup.error"] = se.rscCleanupErr.Error()
 	}
+	if len(se.pendingNodes) > 0 {
+		kvs["supervisor.start.pending"] = strings.Join(se.pendingNodes, ", ")
+	}
 	return kvs
 }
 
@@ -98,6 +139,239 @@ func (se *SupervisorError) Error() string {
 	return strings.Join(sections, "\n\n")
 }
 
+// UnknownDependencyError is the error reported when a node depends on a name
+// that is not a sibling node (check WithDependsOn)
+type UnknownDependencyError struct {
+	supRuntimeName string
+	nodeName       string
+	dependencyName string
+}
+
+// GetRuntimeName returns the name of the supervisor with the invalid node
+func (se *UnknownDependencyError) GetRuntimeName() string {
+	return se.supRuntimeName
+}
+
+// GetNodeName returns the name of the node with the unknown dependency
+func (se *UnknownDependencyError) GetNodeName() string {
+	return se.nodeName
+}
+
+// GetDependencyName returns the unknown dependency
+func (se *UnknownDependencyError) GetDependencyName() string {
+	return se.dependencyName
+}
+
+// KVs returns a data bag map that may be used in structured logging
+func (se *UnknownDependencyError) KVs() map[string]interface{} {
+	kvs := make(map[string]interface{})
+	kvs["supervisor.name"] = se.supRuntimeName
+	kvs["supervisor.node.name"] = se.nodeName
+	kvs["supervisor.node.dependency"] = se.dependencyName
+	return kvs
+}
+
+// Error returns an error message
+func (se *UnknownDependencyError) Error() string {
+	return fmt.Sprintf(
+		"node %s depends on unknown node %s",
+		se.nodeName,
+		se.dependencyName,
+	)
+}
+
+// UnknownNodeError is the error reported when a request targets a node that is
+// not running on the supervision tree (check RestartNode)
+type UnknownNodeError struct {
+	supRuntimeName string
+	nodeName       string
+}
+
+// GetRuntimeName returns the name of the supervisor of the unknown node
+func (se *UnknownNodeError) GetRuntimeName() string {
+	return se.supRuntimeName
+}
+
+// GetNodeName returns the name of the unknown node
+func (se *UnknownNodeError) GetNodeName() string {
+	return se.nodeName
+}
+
+// KVs returns a data bag map that may be used in structured logging
+func (se *UnknownNodeError) KVs() map[string]interface{} {
+	kvs := make(map[string]interface{})
+	kvs["supervisor.name"] = se.supRuntimeName
+	kvs["supervisor.node.name"] = se.nodeName
+	return kvs
+}
+
+// Error returns an error message
+func (se *UnknownNodeError) Error() string {
+	if se.supRuntimeName == "" {
+		return fmt.Sprintf("unknown node %s", se.nodeName)
+	}
+	return fmt.Sprintf("unknown node %s/%s", se.supRuntimeName, se.nodeName)
+}
+
+// DependencyCycleError is the error reported when the dependencies of the
+// nodes of a supervisor have a cycle (check WithDependsOn)
+type DependencyCycleError struct {
+	supRuntimeName string
+	cycle          []string
+}
+
+// GetRuntimeName returns the name of the supervisor with the invalid nodes
+func (se *DependencyCycleError) GetRuntimeName() string {
+	return se.supRuntimeName
+}
+
+// GetCycle returns the names of the nodes in the cycle, the first and last
+// names are the same node (e.g. [a b a])
+func (se *DependencyCycleError) GetCycle() []string {
+	return se.cycle
+}
+
+// KVs returns a data bag map that may be used in structured logging
+func (se *DependencyCycleError) KVs() map[string]interface{} {
+	kvs := make(map[string]interface{})
+	kvs["supervisor.name"] = se.supRuntimeName
+	kvs["supervisor.dependency.cycle"] = strings.Join(se.cycle, " -> ")
+	return kvs
+}
+
+// Error returns an error message
+func (se *DependencyCycleError) Error() string {
+	return fmt.Sprintf("node dependencies have a cycle: %s", strings.Join(se.cycle, " -> "))
+}
+
+// ShutdownDeadlineError is the error reported when a supervisor could not
+// terminate all its children nodes within the duration specified with the
+// WithShutdownTimeout option.
+type ShutdownDeadlineError struct {
+	supRuntimeName string
+	timeout        time.Duration
+	pendingNodes   []string
+}
+
+// GetRuntimeName returns the name of the supervisor that reached its shutdown
+// deadline
+func (se *ShutdownDeadlineError) GetRuntimeName() string {
+	return se.supRuntimeName
+}
+
+// GetPendingNodes returns the runtime names of the nodes that did not stop
+// before the shutdown deadline was reached, in termination order
+func (se *ShutdownDeadlineError) GetPendingNodes() []string {
+	return se.pendingNodes
+}
+
+// KVs returns a data bag map that may be used in structured logging
+func (se *ShutdownDeadlineError) KVs() map[string]interface{} {
+	kvs := make(map[string]interface{})
+	kvs["supervisor.name"] = se.supRuntimeName
+	kvs["supervisor.shutdown.timeout"] = se.timeout
+	kvs["supervisor.shutdown.pending"] = strings.Join(se.pendingNodes, ", ")
+	return kvs
+}
+
+// Error returns an error message
+func (se *ShutdownDeadlineError) Error() string {
+	return fmt.Sprintf(
+		"supervisor did not terminate within %v (pending nodes: %s)",
+		se.timeout,
+		strings.Join(se.pendingNodes, ", "),
+	)
+}
+
+// TerminationPendingError is the error reported when the context given to the
+// TerminateContext or WaitContext methods of a Supervisor is done before the
+// supervision tree finishes its execution.
+type TerminationPendingError struct {
+	supRuntimeName string
+	pendingNodes   []string
+	err            error
+}
+
+// GetRuntimeName returns the name of the supervisor that did not finish in
+// time
+func (se *TerminationPendingError) GetRuntimeName() string {
+	return se.supRuntimeName
+}
+
+// GetPendingNodes returns the runtime names of the nodes that were still
+// running when the context was done
+func (se *TerminationPendingError) GetPendingNodes() []string {
+	return se.pendingNodes
+}
+
+// Unwrap returns the error of the context that was done
+func (se *TerminationPendingError) Unwrap() error {
+	return se.err
+}
+
+// KVs returns a data bag map that may be used in structured logging
+func (se *TerminationPendingError) KVs() map[string]interface{} {
+	kvs := make(map[string]interface{})
+	kvs["supervisor.name"] = se.supRuntimeName
+	kvs["supervisor.termination.pending"] = strings.Join(se.pendingNodes, ", ")
+	kvs["supervisor.termination.error"] = se.err.Error()
+	return kvs
+}
+
+// Error returns an error message
+func (se *TerminationPendingError) Error() string {
+	return fmt.Sprintf(
+		"supervisor did not finish before context was done: %v (pending nodes: %s)",
+		se.err,
+		strings.Join(se.pendingNodes, ", "),
+	)
+}
+
+// StartCancelledError is the error reported when the context of a supervisor is
+// done before all its children nodes started. The children that got started
+// are terminated before this error is reported.
+type StartCancelledError struct {
+	supRuntimeName string
+	pendingNodes   []string
+	err            error
+}
+
+// GetRuntimeName returns the name of the supervisor that got its start
+// cancelled
+func (se *StartCancelledError) GetRuntimeName() string {
+	return se.supRuntimeName
+}
+
+// GetPendingNodes returns the runtime names of the nodes that did not start,
+// in start order; the first one is the node that was starting when the
+// context was done (if any)
+func (se *StartCancelledError) GetPendingNodes() []string {
+	return se.pendingNodes
+}
+
+// Unwrap returns the error of the context that was done
+func (se *StartCancelledError) Unwrap() error {
+	return se.err
+}
+
+// KVs returns a data bag map that may be used in structured logging
+func (se *StartCancelledError) KVs() map[string]interface{} {
+	kvs := make(map[string]interface{})
+	kvs["supervisor.name"] = se.supRuntimeName
+	kvs["supervisor.start.pending"] = strings.Join(se.pendingNodes, ", ")
+	kvs["supervisor.start.error"] = se.err.Error()
+	return kvs
+}
+
+// Error returns an error message
+func (se *StartCancelledError) Error() string {
+	return fmt.Sprintf(
+		"supervisor start cancelled: %v (pending nodes: %s)",
+		se.err,
+		strings.Join(se.pendingNodes, ", "),
+	)
+}
+
 // SupervisorRestartError wraps an error tolerance surpassed error from a child
 // node, enhancing it with supervisor information and possible shutdown errors
 // on other siblings
@@ -26,6 +26,18 @@ const (
 	ProcessFailed
 	// ProcessCompleted is an Event that indicates a process finished without errors
 	ProcessCompleted
+	// ProcessLeakFinished is an Event that indicates a process that did not stop
+	// within its shutdown timeout (e.g. it leaked) finally finished; this Event
+	// may be reported after the root supervisor finished
+	ProcessLeakFinished
+	// ProcessFaultInjected is an Event that indicates a fault got injected on a
+	// process on purpose (check NotifyInjectedFault)
+	ProcessFaultInjected
+	// ProcessShutdownDeadlineReached is an Event that indicates a supervisor did
+	// not terminate all its children before its shutdown deadline (check
+	// WithShutdownTimeout); the error of the event is a ShutdownDeadlineError
+	// that reports the nodes that did not stop in time
+	ProcessShutdownDeadlineReached
 )
 
 // String returns a string representation of the current EventTag
@@ -41,6 +53,12 @@ func (tag EventTag) String() string {
 		return "ProcessFailed"
 	case ProcessCompleted:
 		return "ProcessCompleted"
+	case ProcessLeakFinished:
+		return "ProcessLeakFinished"
+	case ProcessFaultInjected:
+		return "ProcessFaultInjected"
+	case ProcessShutdownDeadlineReached:
+		return "ProcessShutdownDeadlineReached"
 	default:
 		return "<Unknown>"
 	}
@@ -56,6 +74,9 @@ type Event struct {
 	err                error
 	created            time.Time
 	duration           time.Duration
+	// leak is the error that reported the leak that finished on a
+	// ProcessLeakFinished event, it tells apart the leaks of the same process
+	leak *ShutdownTimeoutError
 }
 
 // GetTag returns the EventTag from an Event
@@ -83,6 +104,14 @@ func (e Event) GetCreated() time.Time {
 	return e.created
 }
 
+// GetDuration returns the duration of the operation reported by the event (e.g.
+// the start time on ProcessStarted, the stop time on ProcessTerminated, the
+// time a process was leaked on ProcessLeakFinished, or the time given to the
+// termination on ProcessShutdownDeadlineReached)
+func (e Event) GetDuration() time.Duration {
+	return e.duration
+}
+
 // String returns an string representation for the Event
 func (e Event) String() string {
 	var buffer strings.Builder
@@ -104,13 +133,29 @@ func (e Event) String() string {
 // Check the documentation of WithNotifier for more details.
 type EventNotifier func(Event)
 
+// withNotifier returns an EventNotifier that calls this EventNotifier first,
+// and the given one after. Any of them may be nil.
+func (en EventNotifier) withNotifier(other EventNotifier) EventNotifier {
+	if en == nil {
+		return other
+	}
+	if other == nil {
+		return en
+	}
+	return func(ev Event) {
+		en(ev)
+		other(ev)
+	}
+}
+
 // processTerminated reports an event with an EventTag of ProcessTerminated
 func (en EventNotifier) processTerminated(
+	clock Clock,
 	nodeTag NodeTag,
 	name string,
 	stopTime time.Time,
 ) {
-	createdTime := time.Now()
+	createdTime := clock.Now()
 	stopDuration := createdTime.Sub(stopTime)
 
 	en(Event{
@@ -123,22 +168,62 @@ func (en EventNotifier) processTerminated(
 }
 
 // supervisorTerminated reports an event with an EventTag of ProcessTerminated
-func (en EventNotifier) supervisorTerminated(name string, stopTime time.Time) {
-	en.processTerminated(c.Supervisor, name, stopTime)
+func (en EventNotifier) supervisorTerminated(clock Clock, name string, stopTime time.Time) {
+	en.processTerminated(clock, c.Supervisor, name, stopTime)
+}
+
+// processLeakFinished reports an event with an EventTag of ProcessLeakFinished
+func (en EventNotifier) processLeakFinished(
+	clock Clock,
+	nodeTag NodeTag,
+	name string,
+	leak *ShutdownTimeoutError,
+	leakedSince time.Time,
+	err error,
+) {
+	createdTime := clock.Now()
+	leakDuration := createdTime.Sub(leakedSince)
+
+	en(Event{
+		tag:                ProcessLeakFinished,
+		nodeTag:            nodeTag,
+		processRuntimeName: name,
+		err:                err,
+		created:            createdTime,
+		duration:           leakDuration,
+		leak:               leak,
+	})
+}
+
+// faultInjected reports an event with an EventTag of ProcessFaultInjected
+func (en EventNotifier) faultInjected(
+	clock Clock,
+	nodeTag NodeTag,
+	name string,
+	fault error,
+) {
+	en(Event{
+		tag:                ProcessFaultInjected,
+		nodeTag:            nodeTag,
+		processRuntimeName: name,
+		err:                fault,
+		created:            clock.Now(),
+	})
 }
 
 // workerCompleted reports an event with an EventTag of ProcessCompleted
-func (en EventNotifier) workerCompleted(name string) {
+func (en EventNotifier) workerCompleted(clock Clock, name string) {
 	en(Event{
 		tag:                ProcessCompleted,
 		nodeTag:            c.Worker,
 		processRuntimeName: name,
-		created:            time.Now(),
+		created:            clock.Now(),
 	})
 }
 
 // processFailed reports an event with an EventTag of ProcessFailed
 func (en EventNotifier) processFailed(
+	clock Clock,
 	nodeTag NodeTag,
 	name string,
 	err error,
@@ -148,18 +233,18 @@ func (en EventNotifier) processFailed(
 		nodeTag:            nodeTag,
 		processRuntimeName: name,
 		err:                err,
-		created:            time.Now(),
+		created:            clock.Now(),
 	})
 }
 
 // supervisorFailed reports a supervisor event with an EventTag of ProcessFailed
-func (en EventNotifier) supervisorFailed(name string, err error) {
-	en.processFailed(c.Supervisor, name, err)
+func (en EventNotifier) supervisorFailed(clock Clock, name string, err error) {
+	en.processFailed(clock, c.Supervisor, name, err)
 }
 
 // workerFailed reports a worker event with an EventTag of ProcessFailed
-func (en EventNotifier) workerFailed(name string, err error) {
-	en.processFailed(c.Worker, name, err)
+func (en EventNotifier) workerFailed(clock Clock, name string, err error) {
+	en.processFailed(clock, c.Worker, name, err)
 }
 
 // workerFailed reports an event with an EventTag of ProcessFailed
@@ -181,6 +266,23 @@ func (en EventNotifier) processStartFailed(
 	})
 }
 
+// supervisorShutdownDeadlineReached reports an event with an EventTag of
+// ProcessShutdownDeadlineReached
+func (en EventNotifier) supervisorShutdownDeadlineReached(
+	clock Clock,
+	name string,
+	err *ShutdownDeadlineError,
+) {
+	en(Event{
+		tag:                ProcessShutdownDeadlineReached,
+		nodeTag:            c.Supervisor,
+		processRuntimeName: name,
+		err:                err,
+		created:            clock.Now(),
+		duration:           err.timeout,
+	})
+}
+
 // supervisorStartFailed reports an event with an EventTag of ProcessFailed
 func (en EventNotifier) supervisorStartFailed(name string, err error) {
 	en.processStartFailed(c.Supervisor, name, err)
@@ -191,8 +293,14 @@ func (en EventNotifier) supervisorStartFailed(name string, err error) {
 //	en.processStartFailed(c.Worker, name, err)
 // }
 
-func processStarted(en EventNotifier, nodeTag NodeTag, name string, startTime time.Time) {
-	createdTime := time.Now()
+func processStarted(
+	en EventNotifier,
+	clock Clock,
+	nodeTag NodeTag,
+	name string,
+	startTime time.Time,
+) {
+	createdTime := clock.Now()
 	startDuration := createdTime.Sub(startTime)
 	en(Event{
 		tag:                ProcessStarted,
@@ -205,13 +313,13 @@ func processStarted(en EventNotifier, nodeTag NodeTag, name string, startTime ti
 }
 
 // supervisorStarted reports an event with an EventTag of ProcessStarted
-func (en EventNotifier) supervisorStarted(name string, startTime time.Time) {
-	processStarted(en, c.Supervisor, name, startTime)
+func (en EventNotifier) supervisorStarted(clock Clock, name string, startTime time.Time) {
+	processStarted(en, clock, c.Supervisor, name, startTime)
 }
 
 // workerStarted reports an event with an EventTag of ProcessStarted
-func (en EventNotifier) workerStarted(name string, startTime time.Time) {
-	processStarted(en, c.Worker, name, startTime)
+func (en EventNotifier) workerStarted(clock Clock, name string, startTime time.Time) {
+	processStarted(en, clock, c.Worker, name, startTime)
 }
 
 // emptyEventNotifier is an utility function that works as a default value
@@ -0,0 +1,106 @@
+package cap
+
+// This file contains the support for fault injection; it allows wrappers of
+// nodes (e.g. the ones of the chaos package) to report the faults they inject
+// on the event system, so that they can be distinguished from real failures.
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/capatazlib/go-capataz/internal/c"
+)
+
+// faultReporterKey is the key used to store the faultReporter of a node in
+// its context
+type faultReporterKey struct{}
+
+// faultReporter contains the information needed to report the injected
+// faults of a node
+type faultReporter struct {
+	eventNotifier EventNotifier
+	clock         Clock
+	nodeTag       NodeTag
+	runtimeName   string
+}
+
+// withFaultReporter wraps the start function of the given child spec, so that
+// the node receives a context that allows it to report injected faults
+func (spec SupervisorSpec) withFaultReporter(
+	supRuntimeName string,
+	chSpec c.ChildSpec,
+) c.ChildSpec {
+	reporter := faultReporter{
+		eventNotifier: spec.getEventNotifier(),
+		clock:         chSpec.GetClock(),
+		nodeTag:       chSpec.GetTag(),
+		runtimeName:   fmt.Sprintf("%s%s%s", supRuntimeName, nodeSepToken, chSpec.GetName()),
+	}
+	startFn := chSpec.Start
+	chSpec.Start = func(ctx context.Context, notifyStart c.NotifyStartFn) error {
+		return startFn(context.WithValue(ctx, faultReporterKey{}, reporter), notifyStart)
+	}
+	return chSpec
+}
+
+// WithFaultReporting is a WorkerOpt that specifies if the node runs with a
+// context that allows it to report the faults injected on it (check
+// NotifyInjectedFault and GetFaultClock). This option may be used with both
+// workers and sub-trees; it is disabled by default.
+//
+// This option is meant to be used by the code that injects faults on nodes
+// (e.g. the chaos package enables it on the nodes it wraps).
+var WithFaultReporting = c.WithFaultReporting
+
+// NotifyInjectedFault reports an Event with an EventTag of
+// ProcessFaultInjected for the node running with the given context; the given
+// error describes the injected fault.
+//
+// This function is meant to be used by the code that injects faults on nodes
+// (check the chaos package), it allows monitoring and tests to distinguish
+// injected faults from real failures. The fault must be reported before it
+// happens (e.g. before the node returns the injected error).
+//
+// It returns false when the context does not belong to a node of a
+// supervision tree that has fault reporting enabled (check WithFaultReporting).
+func NotifyInjectedFault(ctx context.Context, fault error) bool {
+	reporter, ok := ctx.Value(faultReporterKey{}).(faultReporter)
+	if !ok {
+		return false
+	}
+	reporter.eventNotifier.faultInjected(
+		reporter.clock,
+		reporter.nodeTag,
+		reporter.runtimeName,
+		fault,
+	)
+	return true
+}
+
+// GetFaultClock returns the Clock of the supervision tree of the node running
+// with the given context; the code that injects faults on a node must use it to
+// measure its delays, this way faults follow the clock given with WithClock.
+//
+// SystemClock is returned when the context does not belong to a node of a
+// supervision tree that has fault reporting enabled (check WithFaultReporting).
+func GetFaultClock(ctx context.Context) Clock {
+	reporter, ok := ctx.Value(faultReporterKey{}).(faultReporter)
+	if !ok {
+		return SystemClock
+	}
+	return reporter.clock
+}
+
+// GetFaultRuntimeName returns the runtime name of the node running with the
+// given context (e.g. root/api/db); the code that injects faults on a node may
+// use it to tell apart nodes that have the same name in different sub-trees.
+//
+// An empty string is returned when the context does not belong to a node of a
+// supervision tree that has fault reporting enabled (check WithFaultReporting).
+func GetFaultRuntimeName(ctx context.Context) string {
+	reporter, ok := ctx.Value(faultReporterKey{}).(faultReporter)
+	if !ok {
+		return ""
+	}
+	return reporter.runtimeName
+}
@@ -0,0 +1,60 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+)
+
+// faultWorker is a worker that reports an injected fault before it notifies
+// its start, the result of the report is sent to the given channel
+func faultWorker(name string, reportedCh chan<- bool, opts ...cap.WorkerOpt) cap.Node {
+	return cap.NewWorkerWithNotifyStart(
+		name,
+		func(ctx context.Context, notifyStart cap.NotifyStartFn) error {
+			reportedCh <- cap.NotifyInjectedFault(ctx, errors.New("injected"))
+			notifyStart(nil)
+			<-ctx.Done()
+			return nil
+		},
+		opts...,
+	)
+}
+
+func TestNotifyInjectedFault(t *testing.T) {
+	reportedCh := make(chan bool, 2)
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			faultWorker("child0", reportedCh),
+			faultWorker("child1", reportedCh, cap.WithFaultReporting(true)),
+		),
+		[]cap.Opt{},
+		func(EventManager) {
+			// nodes without fault reporting can't report faults
+			assert.False(t, <-reportedCh)
+			assert.True(t, <-reportedCh)
+		},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerFaultInjected("root/child1"),
+			WorkerStarted("root/child1"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child1"),
+			WorkerTerminated("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
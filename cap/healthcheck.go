@@ -21,6 +21,7 @@ type HealthcheckMonitor struct {
 	maxAllowedRestartDuration time.Duration
 	maxAllowedFailures        uint32
 	failedEvs                 map[string]Event
+	clock                     Clock
 }
 
 // GetFailedProcesses returns a list of the failed processes
@@ -49,11 +50,28 @@ func (hr HealthReport) IsHealthyReport() bool {
 func NewHealthcheckMonitor(
 	maxAllowedFailures uint32,
 	maxAllowedRestartDuration time.Duration,
+) *HealthcheckMonitor {
+	return NewHealthcheckMonitorWithClock(
+		maxAllowedFailures,
+		maxAllowedRestartDuration,
+		SystemClock,
+	)
+}
+
+// NewHealthcheckMonitorWithClock works like NewHealthcheckMonitor, but it uses
+// the given Clock to measure how long the failed processes have been
+// restarting. The Clock should be the one given to the monitored supervision
+// tree via WithClock.
+func NewHealthcheckMonitorWithClock(
+	maxAllowedFailures uint32,
+	maxAllowedRestartDuration time.Duration,
+	clock Clock,
 ) *HealthcheckMonitor {
 	return &HealthcheckMonitor{
 		maxAllowedRestartDuration: maxAllowedRestartDuration,
 		maxAllowedFailures:        maxAllowedFailures,
 		failedEvs:                 make(map[string]Event),
+		clock:                     clock,
 	}
 }
 
@@ -95,7 +113,7 @@ func (h *HealthcheckMonitor) GetHealthReport() HealthReport {
 		}
 	}
 
-	currentTime := time.Now()
+	currentTime := h.clock.Now()
 	for processName, ev := range h.failedEvs {
 		dur := currentTime.Sub(ev.GetCreated())
 
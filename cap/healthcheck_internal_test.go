@@ -22,8 +22,8 @@ func TestHealthHappyPath(t *testing.T) {
 		healthcheckMonitor.HandleEvent(ev)
 	}
 
-	notifier.workerStarted("w1", time.Now())
-	notifier.workerStarted("w2", time.Now())
+	notifier.workerStarted(SystemClock, "w1", time.Now())
+	notifier.workerStarted(SystemClock, "w2", time.Now())
 	assert.True(t, healthcheckMonitor.IsHealthy())
 }
 
@@ -34,16 +34,16 @@ func TestAtMaxFailuresAndUnderRestartDuration(t *testing.T) {
 		healthcheckMonitor.HandleEvent(ev)
 	}
 
-	notifier.workerStarted("w1", time.Now())
-	notifier.workerStarted("w2", time.Now())
+	notifier.workerStarted(SystemClock, "w1", time.Now())
+	notifier.workerStarted(SystemClock, "w2", time.Now())
 	assert.True(t, healthcheckMonitor.IsHealthy())
 
 	// We tolerate 2 failures, so OK
-	notifier.workerFailed("w1", errors.New("w1 error"))
+	notifier.workerFailed(SystemClock, "w1", errors.New("w1 error"))
 	assert.True(t, healthcheckMonitor.IsHealthy())
 
 	// We tolerate 2 failures and this is #2, so OK
-	notifier.workerFailed("w2", errors.New("w2 error"))
+	notifier.workerFailed(SystemClock, "w2", errors.New("w2 error"))
 	assert.True(t, healthcheckMonitor.IsHealthy())
 }
 
@@ -54,7 +54,7 @@ func TestHealthyReport(t *testing.T) {
 		healthcheckMonitor.HandleEvent(ev)
 	}
 
-	notifier.workerStarted("w1", time.Now())
+	notifier.workerStarted(SystemClock, "w1", time.Now())
 
 	hr := healthcheckMonitor.GetHealthReport()
 	assert.True(t, hr.IsHealthyReport())
@@ -68,9 +68,9 @@ func TestUnhealthyFailuresReport(t *testing.T) {
 		healthcheckMonitor.HandleEvent(ev)
 	}
 
-	notifier.workerStarted("w1", time.Now())
+	notifier.workerStarted(SystemClock, "w1", time.Now())
 	// Unacceptable failure
-	notifier.workerFailed("w1", errors.New("w1 error"))
+	notifier.workerFailed(SystemClock, "w1", errors.New("w1 error"))
 
 	hr := healthcheckMonitor.GetHealthReport()
 	assert.False(t, hr.IsHealthyReport())
@@ -90,9 +90,9 @@ func TestUnhealthyDelaysReport(t *testing.T) {
 		healthcheckMonitor.HandleEvent(ev)
 	}
 
-	notifier.workerStarted("w1", time.Now())
+	notifier.workerStarted(SystemClock, "w1", time.Now())
 	// Unacceptable delay
-	notifier.workerFailed("w1", errors.New("w1 error"))
+	notifier.workerFailed(SystemClock, "w1", errors.New("w1 error"))
 
 	hr := healthcheckMonitor.GetHealthReport()
 	assert.False(t, hr.IsHealthyReport())
@@ -112,9 +112,9 @@ func TestHealthRestoredReport(t *testing.T) {
 		healthcheckMonitor.HandleEvent(ev)
 	}
 
-	notifier.workerStarted("w1", time.Now())
+	notifier.workerStarted(SystemClock, "w1", time.Now())
 	// Unacceptable failures and delays
-	notifier.workerFailed("w1", errors.New("w1 error"))
+	notifier.workerFailed(SystemClock, "w1", errors.New("w1 error"))
 
 	hr := healthcheckMonitor.GetHealthReport()
 	// Failures are over tolerance
@@ -128,6 +128,6 @@ func TestHealthRestoredReport(t *testing.T) {
 	assert.True(t, hr.GetDelayedRestartProcesses()["w1"])
 
 	// Failures recovered
-	notifier.workerStarted("w1", time.Now())
+	notifier.workerStarted(SystemClock, "w1", time.Now())
 	assert.True(t, healthcheckMonitor.GetHealthReport().IsHealthyReport())
 }
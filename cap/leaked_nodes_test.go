@@ -0,0 +1,160 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+func TestLeakedNodeTracking(t *testing.T) {
+	ctx := context.TODO()
+	evManager := NewEventManager()
+	evManager.StartCollector(ctx)
+
+	stuck, unblock := StuckWorker("child0", cap.WithShutdown(cap.Timeout(10*time.Millisecond)))
+	defer unblock()
+
+	supSpec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(stuck, WaitDoneWorker("child1")),
+		cap.WithNotifier(evManager.EventCollector(ctx)),
+	)
+
+	sup, startErr := supSpec.Start(ctx)
+	assert.NoError(t, startErr)
+	assert.Empty(t, sup.GetLeakedNodes())
+
+	err := sup.Terminate()
+	assert.Error(t, err)
+
+	// the supervisor finished, but the goroutine of the stuck worker is still
+	// running
+	leaked := sup.GetLeakedNodes()
+	if assert.Len(t, leaked, 1) {
+		assert.Equal(t, "root/child0", leaked[0].GetRuntimeName())
+		assert.True(t, leaked[0].GetLeakedDuration() >= 0)
+	}
+
+	evIt := evManager.Iterator()
+	unblock()
+	evIt.SkipTill(WorkerLeakFinished("root/child0"))
+
+	assert.Empty(t, sup.GetLeakedNodes())
+
+	AssertExactMatch(t, evManager.Snapshot(),
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerStarted("root/child1"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child1"),
+			WorkerFailedWith("root/child0", "child shutdown timeout"),
+			SupervisorFailed("root"),
+			WorkerLeakFinished("root/child0"),
+		},
+	)
+
+	evs := evManager.Snapshot()
+
+	// the failure event of the stuck worker reports a typed error
+	var timeoutErr *cap.ShutdownTimeoutError
+	if assert.True(t, errors.As(evs[4].Err(), &timeoutErr)) {
+		assert.Equal(t, "root/child0", timeoutErr.GetRuntimeName())
+		assert.Equal(t, 10*time.Millisecond, timeoutErr.GetTimeout())
+	}
+
+	// the leak finished event reports the result of the goroutine and for how
+	// long it was leaked
+	assert.NoError(t, evs[6].Err())
+	assert.True(t, evs[6].GetDuration() > 0)
+}
+
+func TestLeakedNodeOnSubtree(t *testing.T) {
+	ctx := context.TODO()
+	evManager := NewEventManager()
+	evManager.StartCollector(ctx)
+
+	stuck, unblock := StuckWorker("child0", cap.WithShutdown(cap.Timeout(10*time.Millisecond)))
+	defer unblock()
+
+	b0 := cap.NewSupervisorSpec("branch0", cap.WithNodes(stuck))
+
+	supSpec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(cap.Subtree(b0)),
+		cap.WithNotifier(evManager.EventCollector(ctx)),
+	)
+
+	sup, startErr := supSpec.Start(ctx)
+	assert.NoError(t, startErr)
+
+	assert.Error(t, sup.Terminate())
+
+	leaked := sup.GetLeakedNodes()
+	if assert.Len(t, leaked, 1) {
+		assert.Equal(t, "root/branch0/child0", leaked[0].GetRuntimeName())
+	}
+
+	evIt := evManager.Iterator()
+	unblock()
+	evIt.SkipTill(WorkerLeakFinished("root/branch0/child0"))
+
+	assert.Empty(t, sup.GetLeakedNodes())
+}
+
+func TestLeakedNodeLeaksAgain(t *testing.T) {
+	ctx := context.TODO()
+	evManager := NewEventManager()
+	evManager.StartCollector(ctx)
+
+	// every run of the worker ignores its termination until it gets released
+	releaseCh := make(chan struct{})
+	stuck := cap.NewWorker(
+		"child0",
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			<-releaseCh
+			return nil
+		},
+		cap.WithShutdown(cap.Timeout(10*time.Millisecond)),
+	)
+
+	supSpec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(stuck),
+		cap.WithNotifier(evManager.EventCollector(ctx)),
+	)
+
+	sup, startErr := supSpec.Start(ctx)
+	assert.NoError(t, startErr)
+	defer sup.Terminate()
+
+	// the first run leaks, the node stays stopped until the next restart
+	assert.Error(t, sup.RestartNode("root/child0"))
+	assert.NoError(t, sup.RestartNode("root/child0"))
+	// the second run leaks as well
+	assert.Error(t, sup.RestartNode("root/child0"))
+
+	leaked := sup.GetLeakedNodes()
+	if assert.Len(t, leaked, 2) {
+		assert.Equal(t, "root/child0", leaked[0].GetRuntimeName())
+		assert.Equal(t, "root/child0", leaked[1].GetRuntimeName())
+		assert.False(t, leaked[1].GetLeakedSince().Before(leaked[0].GetLeakedSince()))
+	}
+
+	// once one of the runs finishes, the other one is still reported
+	evIt := evManager.Iterator()
+	releaseCh <- struct{}{}
+	evIt.SkipTill(WorkerLeakFinished("root/child0"))
+	assert.Len(t, sup.GetLeakedNodes(), 1)
+
+	releaseCh <- struct{}{}
+	evIt.SkipTill(WorkerLeakFinished("root/child0"))
+	assert.Empty(t, sup.GetLeakedNodes())
+}
@@ -2,8 +2,10 @@ package cap
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/capatazlib/go-capataz/internal/c"
@@ -16,6 +18,7 @@ const nodeSepToken = "/"
 ////////////////////////////////////////////////////////////////////////////////
 
 func handleChildNodeError(
+	startCtx context.Context,
 	eventNotifier EventNotifier,
 	supRuntimeName string,
 	supChildren map[string]c.Child,
@@ -25,13 +28,19 @@ func handleChildNodeError(
 ) *c.ErrorToleranceReached {
 	chSpec := prevCh.GetSpec()
 
-	eventNotifier.processFailed(chSpec.GetTag(), prevCh.GetRuntimeName(), prevChErr)
+	eventNotifier.processFailed(
+		chSpec.GetClock(),
+		chSpec.GetTag(),
+		prevCh.GetRuntimeName(),
+		prevChErr,
+	)
 
 	switch chSpec.GetRestart() {
 	case c.Permanent, c.Transient:
 		// On error scenarios, Permanent and Transient try as much as possible
 		// to restart the failing child
 		return oneForOneRestartLoop(
+			startCtx,
 			eventNotifier,
 			supRuntimeName,
 			supChildren,
@@ -48,6 +57,7 @@ func handleChildNodeError(
 }
 
 func handleChildNodeCompletion(
+	startCtx context.Context,
 	eventNotifier EventNotifier,
 	supRuntimeName string,
 	supChildren map[string]c.Child,
@@ -56,7 +66,7 @@ func handleChildNodeCompletion(
 ) *c.ErrorToleranceReached {
 
 	if prevCh.IsWorker() {
-		eventNotifier.workerCompleted(prevCh.GetRuntimeName())
+		eventNotifier.workerCompleted(prevCh.GetSpec().GetClock(), prevCh.GetRuntimeName())
 	}
 
 	chSpec := prevCh.GetSpec()
@@ -71,6 +81,7 @@ func handleChildNodeCompletion(
 		// On child completion, the supervisor still restart the child when the
 		// c.Restart is Permanent
 		return oneForOneRestartLoop(
+			startCtx,
 			eventNotifier,
 			supRuntimeName,
 			supChildren,
@@ -82,6 +93,7 @@ func handleChildNodeCompletion(
 }
 
 func handleChildNodeNotification(
+	startCtx context.Context,
 	eventNotifier EventNotifier,
 	supRuntimeName string,
 	supChildren map[string]c.Child,
@@ -95,6 +107,7 @@ func handleChildNodeNotification(
 		// if the notification contains an error, we send a notification
 		// saying that the process failed
 		return handleChildNodeError(
+			startCtx,
 			eventNotifier,
 			supRuntimeName,
 			supChildren,
@@ -105,6 +118,7 @@ func handleChildNodeNotification(
 	}
 
 	return handleChildNodeCompletion(
+		startCtx,
 		eventNotifier,
 		supRuntimeName,
 		supChildren,
@@ -117,16 +131,18 @@ func handleChildNodeNotification(
 
 // startChildNode is responsible of starting a single child. This function will
 // deal with the child lifecycle notification. It will return an error if
-// something goes wrong with the initialization of this child.
+// something goes wrong with the initialization of this child, or if the given
+// context is done before the child started.
 func startChildNode(
+	ctx context.Context,
 	spec SupervisorSpec,
 	supRuntimeName string,
 	notifyCh chan c.ChildNotification,
 	chSpec c.ChildSpec,
 ) (c.Child, error) {
 	eventNotifier := spec.getEventNotifier()
-	startedTime := time.Now()
-	ch, chStartErr := chSpec.DoStart(supRuntimeName, notifyCh)
+	startedTime := chSpec.GetClock().Now()
+	ch, chStartErr := chSpec.DoStartContext(ctx, supRuntimeName, notifyCh)
 
 	// NOTE: The error handling code bellow gets executed when the children
 	// fails at start time
@@ -135,42 +151,109 @@ func startChildNode(
 			[]string{supRuntimeName, chSpec.GetName()},
 			nodeSepToken,
 		)
-		eventNotifier.processStartFailed(chSpec.GetTag(), cRuntimeName, chStartErr)
+		notifyChildNodeStartError(eventNotifier, chSpec, cRuntimeName, ch, chStartErr)
 		return c.Child{}, chStartErr
 	}
 
 	// NOTE: we only notify when child is a worker because sub-trees supervisors
 	// are responsible of their own notification
 	if chSpec.IsWorker() {
-		eventNotifier.workerStarted(ch.GetRuntimeName(), startedTime)
+		eventNotifier.workerStarted(chSpec.GetClock(), ch.GetRuntimeName(), startedTime)
 	}
 	return ch, nil
 }
 
+// notifyChildNodeStartError reports the start error of a child. A child that
+// got its start cancelled may not stop in time, we keep track of its goroutine
+// like we do on a regular termination; a sub-tree that reported its own start
+// before it got terminated is reported as terminated.
+func notifyChildNodeStartError(
+	eventNotifier EventNotifier,
+	chSpec c.ChildSpec,
+	chRuntimeName string,
+	ch c.Child,
+	chStartErr error,
+) {
+	clock := chSpec.GetClock()
+	var timeoutErr *ShutdownTimeoutError
+	if errors.As(chStartErr, &timeoutErr) {
+		eventNotifier.processFailed(clock, chSpec.GetTag(), chRuntimeName, chStartErr)
+		go watchLeakedChildNode(eventNotifier, ch, timeoutErr, clock.Now())
+		return
+	}
+	var abortErr *c.StartAbortedError
+	if errors.As(chStartErr, &abortErr) && !chSpec.IsWorker() {
+		eventNotifier.processTerminated(clock, chSpec.GetTag(), chRuntimeName, clock.Now())
+		return
+	}
+	eventNotifier.processStartFailed(chSpec.GetTag(), chRuntimeName, chStartErr)
+}
+
 // startChildNodes iterates over all the children (specified with `cap.WithNodes`
 // and `cap.WithSubtree`) starting a goroutine for each. The children iteration
 // will be sorted as specified with the `cap.WithStartOrder` option. In case any child
 // fails to start, the supervisor start operation will be aborted and all the
 // started children so far will be stopped in the reverse order.
+//
+// When the given context is done before all the children started, the start
+// operation is aborted as well (check cancelChildNodesStart).
 func startChildNodes(
+	ctx context.Context,
 	spec SupervisorSpec,
 	supChildrenSpecs []c.ChildSpec,
 	supRuntimeName string,
 	notifyCh chan c.ChildNotification,
 ) (map[string]c.Child, error) {
+	if spec.startConcurrency > 1 {
+		return startChildNodesInParallel(ctx, spec, supChildrenSpecs, supRuntimeName, notifyCh)
+	}
+
 	children := make(map[string]c.Child)
 
 	// Start children in the correct order
-	for _, chSpec := range spec.order.sortStart(supChildrenSpecs) {
+	sortedSpecs := spec.sortStart(supChildrenSpecs)
+	for i, chSpec := range sortedSpecs {
+		if ctx.Err() != nil {
+			return nil, cancelChildNodesStart(
+				ctx,
+				spec,
+				supRuntimeName,
+				supChildrenSpecs,
+				children,
+				sortedSpecs[i:],
+				nil, /* abort errors */
+			)
+		}
 		// the function above will modify the children internally
 		ch, chStartErr := startChildNode(
+			ctx,
 			spec,
 			supRuntimeName,
 			notifyCh,
 			chSpec,
 		)
+		// the child start gets interrupted when the context is done
+		if chStartErr != nil && ctx.Err() != nil {
+			return nil, cancelChildNodesStart(
+				ctx,
+				spec,
+				supRuntimeName,
+				supChildrenSpecs,
+				children,
+				sortedSpecs[i:],
+				abortStartErrors(map[string]error{chSpec.GetName(): chStartErr}),
+			)
+		}
 		if chStartErr != nil {
-			nodeErrMap := terminateChildNodes(spec, supChildrenSpecs, children)
+			nodeErrMap, _ := terminateChildNodes(
+				spec,
+				supRuntimeName,
+				supChildrenSpecs,
+				children,
+				true, /* drained: a rollback does not drain the children */
+				c.ParentFailureReason,
+				time.Time{}, /* no parent deadline */
+			)
 			// Is important we stop the children before we finish the supervisor
 			return nil, &SupervisorError{
 				supRuntimeName: supRuntimeName,
@@ -183,76 +266,352 @@ func startChildNodes(
 	return children, nil
 }
 
+// abortStartErrors filters the given start errors, it keeps the ones of the
+// children that did not stop in time after their start got cancelled
+func abortStartErrors(startErrMap map[string]error) map[string]error {
+	abortErrMap := make(map[string]error)
+	for chName, startErr := range startErrMap {
+		var timeoutErr *ShutdownTimeoutError
+		if errors.As(startErr, &timeoutErr) {
+			abortErrMap[chName] = startErr
+		}
+	}
+	return abortErrMap
+}
+
+// cancelChildNodesStart aborts the start of a supervisor once the given context
+// is done; it terminates the children that were started in the reverse order,
+// and returns a StartCancelledError that reports the given children specs that
+// did not start. If any of the started children fails to terminate, or a
+// child that was starting did not stop in time (given in abortErrMap), the
+// StartCancelledError is wrapped in a SupervisorError.
+func cancelChildNodesStart(
+	ctx context.Context,
+	spec SupervisorSpec,
+	supRuntimeName string,
+	supChildrenSpecs []c.ChildSpec,
+	supChildren map[string]c.Child,
+	pendingSpecs []c.ChildSpec,
+	abortErrMap map[string]error,
+) error {
+	nodeErrMap, _ := terminateChildNodes(
+		spec,
+		supRuntimeName,
+		supChildrenSpecs,
+		supChildren,
+		true, /* drained: a rollback does not drain the children */
+		getContextTerminationReason(ctx),
+		time.Time{}, /* no parent deadline */
+	)
+	for chName, abortErr := range abortErrMap {
+		nodeErrMap[chName] = abortErr
+	}
+
+	pendingNodes := make([]string, 0, len(pendingSpecs))
+	for _, chSpec := range pendingSpecs {
+		pendingNodes = append(
+			pendingNodes,
+			strings.Join([]string{supRuntimeName, chSpec.GetName()}, nodeSepToken),
+		)
+	}
+
+	cancelErr := &StartCancelledError{
+		supRuntimeName: supRuntimeName,
+		pendingNodes:   pendingNodes,
+		err:            ctx.Err(),
+	}
+
+	if len(nodeErrMap) > 0 {
+		return &SupervisorError{
+			supRuntimeName: supRuntimeName,
+			nodeErr:        cancelErr,
+			nodeErrMap:     nodeErrMap,
+		}
+	}
+	return cancelErr
+}
+
+// getContextTerminationReason returns the termination reason a parent
+// supervisor registered on the given context, or ShutdownReason if there is
+// none (e.g. root supervisors)
+func getContextTerminationReason(ctx context.Context) c.TerminationReason {
+	reason := c.GetTerminationReason(ctx)
+	if reason == c.UnknownReason {
+		return c.ShutdownReason
+	}
+	return reason
+}
+
 // terminateChildNode executes the Terminate procedure on the given child, in case
 // there is an error on termination it notifies the event system
 func terminateChildNode(
 	eventNotifier EventNotifier,
 	ch c.Child,
+	reason c.TerminationReason,
+) error {
+	return terminateChildNodeWith(eventNotifier, ch, func() error {
+		return ch.Terminate(reason)
+	})
+}
+
+// watchLeakedChildNode waits for the goroutine of a child that did not stop
+// in time, and notifies the event system once it finishes; the given timeout
+// error is the one that reported the leak.
+//
+// NOTE: the goroutine of the child may finish after the supervision tree
+// finished, the event gets reported anyway (check ProcessLeakFinished), this
+// way, the leaked nodes of a finished tree are kept up to date.
+func watchLeakedChildNode(
+	eventNotifier EventNotifier,
+	ch c.Child,
+	timeoutErr *ShutdownTimeoutError,
+	leakedSince time.Time,
+) {
+	leakErr := ch.WaitLeaked()
+	eventNotifier.processLeakFinished(
+		ch.GetSpec().GetClock(),
+		ch.GetTag(),
+		ch.GetRuntimeName(),
+		timeoutErr,
+		leakedSince,
+		leakErr,
+	)
+}
+
+// terminateChildNodeWith executes the given termination function on the given
+// child, in case there is an error on termination it notifies the event system
+func terminateChildNodeWith(
+	eventNotifier EventNotifier,
+	ch c.Child,
+	terminateFn func() error,
 ) error {
 	chSpec := ch.GetSpec()
-	stoppingTime := time.Now()
-	terminationErr := ch.Terminate()
+	clock := chSpec.GetClock()
+	stoppingTime := clock.Now()
+	terminationErr := terminateFn()
 
 	if terminationErr != nil {
 		// we also notify that the process failed
-		eventNotifier.processFailed(chSpec.GetTag(), ch.GetRuntimeName(), terminationErr)
+		eventNotifier.processFailed(clock, chSpec.GetTag(), ch.GetRuntimeName(), terminationErr)
+
+		// if the child did not stop in time, we keep track of its goroutine
+		var timeoutErr *ShutdownTimeoutError
+		if errors.As(terminationErr, &timeoutErr) {
+			go watchLeakedChildNode(eventNotifier, ch, timeoutErr, clock.Now())
+		}
 		return terminationErr
 	}
 	// we need to notify that the process stopped
-	eventNotifier.processTerminated(chSpec.GetTag(), ch.GetRuntimeName(), stoppingTime)
+	eventNotifier.processTerminated(clock, chSpec.GetTag(), ch.GetRuntimeName(), stoppingTime)
 	return nil
 }
 
+// shutdownDeadlineGrace is the minimum time a child is waited for once the
+// shutdown deadline of its supervisor is reached, this way the siblings that
+// are terminated after the deadline still get a chance to stop
+const shutdownDeadlineGrace = 100 * time.Millisecond
+
+// terminateChildNodeUntil executes the Terminate procedure on the given child,
+// waiting until the given deadline at most (when is not zero); once the
+// deadline is reached, the child is waited for a grace period at most. It
+// returns the runtime names of the nodes that did not stop before the deadline
+// (the child and the pending nodes of its sub-tree, if any), and the
+// termination error of the child.
+func terminateChildNodeUntil(
+	eventNotifier EventNotifier,
+	ch c.Child,
+	reason c.TerminationReason,
+	deadline time.Time,
+) ([]string, error) {
+	terminateFn := func() error {
+		return ch.Terminate(reason)
+	}
+	if !deadline.IsZero() {
+		terminateFn = func() error {
+			return ch.TerminateWithDeadline(reason, deadline, shutdownDeadlineGrace)
+		}
+	}
+	terminationErr := terminateChildNodeWith(eventNotifier, ch, terminateFn)
+
+	// once the deadline is reached, every child that fails to stop is
+	// considered a node that did not stop in time
+	if terminationErr == nil ||
+		deadline.IsZero() ||
+		ch.GetSpec().GetClock().Now().Before(deadline) {
+		return nil, terminationErr
+	}
+
+	// sub-trees get the same deadline, they report their own pending nodes
+	pendingNodes := []string{ch.GetRuntimeName()}
+	var deadlineErr *ShutdownDeadlineError
+	if errors.As(terminationErr, &deadlineErr) {
+		pendingNodes = append(pendingNodes, deadlineErr.GetPendingNodes()...)
+	}
+	return pendingNodes, terminationErr
+}
+
+// drainChildNodes notifies all the given children that they must stop
+// accepting new work, this is the first phase of a graceful termination.
+func drainChildNodes(
+	spec SupervisorSpec,
+	supChildrenSpecs0 []c.ChildSpec,
+	supChildren map[string]c.Child,
+) {
+	for _, chSpec := range spec.sortTermination(supChildrenSpecs0) {
+		if ch, ok := supChildren[chSpec.GetName()]; ok {
+			ch.Drain()
+		}
+	}
+}
+
+// waitDrainedChildNodes blocks until all the given children finished, or the
+// given timeout channel is ready
+func waitDrainedChildNodes(supChildren map[string]c.Child, timeoutCh <-chan time.Time) {
+	for _, ch := range supChildren {
+		select {
+		case <-ch.Done():
+		case <-timeoutCh:
+			return
+		}
+	}
+}
+
 // terminateChildNodes is used on the shutdown of the supervisor tree, it stops
-// children in the desired order.
+// children in the desired order. When the supervisor was configured with
+// WithShutdownTimeout, or the given parent deadline is not zero, children are
+// waited until the earliest deadline at most, and the ones that did not stop in
+// time are reported in the returned ShutdownDeadlineError.
+//
+// When the supervisor was configured with WithDrainTimeout, children get
+// drained and the supervisor waits for the drain period (or until all of them
+// finished) before their termination. This phase is skipped if the children
+// were already drained by a request of the parent supervisor, or when the
+// start of the supervisor is rolled back (the drained flag is given in both
+// cases).
+//
+// When the supervisor was configured with WithParallelTermination, children
+// that do not depend on each other are terminated at the same time.
+//
+// The given reason is made available to the children via their context.
 func terminateChildNodes(
 	spec SupervisorSpec,
+	supRuntimeName string,
 	supChildrenSpecs0 []c.ChildSpec,
 	supChildren map[string]c.Child,
-) map[string]error {
+	drained bool,
+	reason c.TerminationReason,
+	parentDeadline time.Time,
+) (map[string]error, *ShutdownDeadlineError) {
 	eventNotifier := spec.eventNotifier
-	supChildrenSpecs := spec.order.sortTermination(supChildrenSpecs0)
+	supChildrenSpecs := spec.sortTermination(supChildrenSpecs0)
 	supNodeErrMap := make(map[string]error)
+	pendingNodes := make([]string, 0)
+
+	clock := spec.getClock()
+
+	now := clock.Now()
+	deadline := parentDeadline
+	if spec.shutdownTimeout > 0 {
+		ownDeadline := now.Add(spec.shutdownTimeout)
+		if deadline.IsZero() || ownDeadline.Before(deadline) {
+			deadline = ownDeadline
+		}
+	}
+	var timeout time.Duration
+	if !deadline.IsZero() {
+		timeout = deadline.Sub(now)
+	}
+
+	if spec.drainTimeout > 0 && !drained {
+		drainChildNodes(spec, supChildrenSpecs0, supChildren)
 
-	for _, chSpec := range supChildrenSpecs {
-		ch, ok := supChildren[chSpec.GetName()]
-		// There are scenarios where is ok to ignore supChildren not having the
-		// entry:
-		//
-		// * On start, there may be a failure mid-way in the initialization and on
-		// the rollback we iterate over children spec that are not present in the
-		// runtime children map
-		//
-		// * On stop, there may be a Transient child that completed, or a Temporary child
-		// that completed or failed.
-		if ok {
-			terminationErr := terminateChildNode(eventNotifier, ch)
-			if terminationErr != nil {
-				// if a child fails to stop (either because of a legit failure or a
-				// timeout), we store the terminationError so that we can report all of them
-				// later
-				supNodeErrMap[chSpec.GetName()] = terminationErr
+		// the drain period is part of the time given to the termination
+		drainTimeout := spec.drainTimeout
+		if !deadline.IsZero() && deadline.Sub(clock.Now()) < drainTimeout {
+			drainTimeout = deadline.Sub(clock.Now())
+		}
+		waitDrainedChildNodes(supChildren, clock.After(drainTimeout))
+	}
+
+	if spec.parallelTermination {
+		supNodeErrMap, pendingNodes = terminateChildNodesInParallel(
+			spec,
+			supChildrenSpecs0,
+			supChildren,
+			deadline,
+			reason,
+		)
+	} else {
+		for _, chSpec := range supChildrenSpecs {
+			ch, ok := supChildren[chSpec.GetName()]
+			// There are scenarios where is ok to ignore supChildren not having the
+			// entry:
+			//
+			// * On start, there may be a failure mid-way in the initialization and on
+			// the rollback we iterate over children spec that are not present in the
+			// runtime children map
+			//
+			// * On stop, there may be a Transient child that completed, or a Temporary child
+			// that completed or failed.
+			if ok {
+				pending, terminationErr := terminateChildNodeUntil(eventNotifier, ch, reason, deadline)
+				if terminationErr != nil {
+					// if a child fails to stop (either because of a legit failure or a
+					// timeout), we store the terminationError so that we can report all of them
+					// later
+					supNodeErrMap[chSpec.GetName()] = terminationErr
+				}
+				pendingNodes = append(pendingNodes, pending...)
 			}
 		}
 	}
-	return supNodeErrMap
+
+	if len(pendingNodes) > 0 {
+		return supNodeErrMap, &ShutdownDeadlineError{
+			supRuntimeName: supRuntimeName,
+			timeout:        timeout,
+			pendingNodes:   pendingNodes,
+		}
+	}
+	return supNodeErrMap, nil
 }
 
 // terminateSupervisor stops all children an signal any errors to the
-// given onTerminate callback
+// given onTerminate callback. The given parent deadline bounds the termination
+// of the children (check terminateChildNodes), it is zero when the parent
+// supervisor did not give a deadline.
 func terminateSupervisor(
 	supSpec SupervisorSpec,
 	supChildrenSpecs []c.ChildSpec,
 	supRuntimeName string,
 	supRscCleanup CleanupResourcesFn,
 	supChildren map[string]c.Child,
+	drained bool,
 	onTerminate func(error),
 	restartErr *c.ErrorToleranceReached,
+	reason c.TerminationReason,
+	parentDeadline time.Time,
 ) error {
 	var terminateErr *SupervisorError
-	supNodeErrMap := terminateChildNodes(supSpec, supChildrenSpecs, supChildren)
+	supNodeErrMap, deadlineErr := terminateChildNodes(
+		supSpec,
+		supRuntimeName,
+		supChildrenSpecs,
+		supChildren,
+		drained,
+		reason,
+		parentDeadline,
+	)
 	supRscCleanupErr := supRscCleanup()
 
+	if deadlineErr != nil {
+		supSpec.getEventNotifier().supervisorShutdownDeadlineReached(
+			supSpec.getClock(),
+			supRuntimeName,
+			deadlineErr,
+		)
+	}
+
 	// If any of the children fails to stop, we should report that as an
 	// error
 	if len(supNodeErrMap) > 0 || supRscCleanupErr != nil {
@@ -264,6 +623,12 @@ func terminateSupervisor(
 			nodeErrMap:     supNodeErrMap,
 			rscCleanupErr:  supRscCleanupErr,
 		}
+
+		// If the shutdown deadline was reached, it becomes the cause of the
+		// termination error
+		if deadlineErr != nil {
+			terminateErr.nodeErr = deadlineErr
+		}
 	}
 
 	// If we have a terminateErr or a restartErr, we should report that back to the
@@ -313,6 +678,12 @@ func terminateSupervisor(
 // 2) When called with the sync strategy, these callbacks will return the given
 // error, note this implementation returns the result of the callback calls
 //
+// Children restarts happen in the background (check restartTracker); the loop
+// keeps handling notifications, control messages and termination requests while
+// a child is restarting. On termination, the starts of the ongoing restarts get
+// cancelled, and the loop waits for them so that the restarted children get
+// terminated as well.
+//
 func runMonitorLoop(
 	ctx context.Context,
 	supSpec SupervisorSpec,
@@ -327,6 +698,7 @@ func runMonitorLoop(
 ) error {
 	// Start children
 	supChildren, restartErr := startChildNodes(
+		ctx,
 		supSpec,
 		supChildrenSpecs,
 		supRuntimeName,
@@ -344,73 +716,292 @@ func runMonitorLoop(
 	// started (we would get race-conditions if we notify from the parent
 	// otherwise).
 	eventNotifier := supSpec.getEventNotifier()
-	eventNotifier.supervisorStarted(supRuntimeName, supStartTime)
+	eventNotifier.supervisorStarted(supSpec.getClock(), supRuntimeName, supStartTime)
 
 	/// Once children have been spawned, we notify to the caller thread that the
 	// main loop has started without errors.
 	onStart(nil)
 
+	// client APIs of the supervision tree (e.g. RestartNode) find this
+	// supervisor through its mailbox
+	if supSpec.nodeRegistry != nil {
+		mailbox := newSupervisorMailbox(ctrlCh)
+		supSpec.nodeRegistry.setMailbox(supRuntimeName, mailbox)
+
+		var closeOnce sync.Once
+		closeMailbox := func() {
+			closeOnce.Do(func() {
+				supSpec.nodeRegistry.removeMailbox(supRuntimeName, mailbox)
+				mailbox.close()
+			})
+		}
+		defer closeMailbox()
+
+		// the mailbox is closed before the termination gets notified; clients
+		// waiting on it must not block the termination callbacks (e.g. the stop
+		// functions of start hooks)
+		notifyTermination := onTerminate
+		onTerminate = func(err terminateError) {
+			closeMailbox()
+			notifyTermination(err)
+		}
+	}
+
+	// drainCh gets closed when the parent supervisor requests the drain of this
+	// sub-tree; root supervisors never receive this signal
+	drainCh := c.DrainSignal(ctx)
+	drained := false
+
+	// children restarts happen in the background, this way the supervisor is
+	// able to handle other notifications and requests while a child starts
+	restarts := newRestartTracker(ctx)
+
 	// Supervisor Loop
 	for {
 		select {
+		// parent supervisor is about to terminate this sub-tree
+		case <-drainCh:
+			drainChildNodes(supSpec, supChildrenSpecs, supChildren)
+			drained = true
+			// a closed channel is always ready, we stop listening to it
+			drainCh = nil
+
 		// parent context is done
 		case <-ctx.Done():
+			// children that are restarting must be terminated as well
+			supChildrenSpecs = waitChildNodeRestarts(
+				eventNotifier,
+				supSpec,
+				supChildrenSpecs,
+				supRuntimeName,
+				supChildren,
+				supNotifyCh,
+				restarts,
+			)
+			// sub-trees forward the reason given by their parent supervisor to
+			// their children, and they do not wait beyond the deadline of their
+			// parent supervisor (if any)
+			reason := getContextTerminationReason(ctx)
+			parentDeadline, _ := c.GetTerminationDeadline(ctx)
 			return terminateSupervisor(
 				supSpec,
 				supChildrenSpecs,
 				supRuntimeName,
 				supRscCleanup,
 				supChildren,
+				drained,
 				onTerminate,
 				nil, /* restart error */
+				reason,
+				parentDeadline,
 			)
 
 		case chNotification := <-supNotifyCh:
-			prevCh, ok := supChildren[chNotification.GetName()]
-
-			if !ok {
-				// TODO: Expand on this case, I think this is highly unlikely, but would
-				// like to exercise this branch in test somehow (if possible)
-				panic(
-					fmt.Errorf(
-						"something horribly wrong happened here (name: %s, tag: %s)",
-						prevCh.GetRuntimeName(),
-						prevCh.GetTag(),
-					),
-				)
-			}
-
-			restartErr := handleChildNodeNotification(
-				eventNotifier,
+			dispatchChildNodeNotification(
+				supSpec,
+				supChildrenSpecs,
 				supRuntimeName,
 				supChildren,
 				supNotifyCh,
-				prevCh,
+				restarts,
 				chNotification,
 			)
 
-			if restartErr != nil {
+		case result := <-restarts.resultCh:
+			notifs, msgs := restarts.finish(result, supChildren)
+
+			// children restarted during a drain must be drained as well
+			if drained {
+				drainChildNodes(supSpec, supChildrenSpecs, supChildren)
+			}
+
+			if result.restartErr != nil {
+				supChildrenSpecs = waitChildNodeRestarts(
+					eventNotifier,
+					supSpec,
+					supChildrenSpecs,
+					supRuntimeName,
+					supChildren,
+					supNotifyCh,
+					restarts,
+				)
 				return terminateSupervisor(
 					supSpec,
 					supChildrenSpecs,
 					supRuntimeName,
 					supRscCleanup,
 					supChildren,
+					drained,
 					onTerminate,
-					restartErr,
+					result.restartErr,
+					c.ParentFailureReason,
+					time.Time{}, /* no parent deadline */
+				)
+			}
+
+			// we handle what the restarted children reported while they were
+			// restarting
+			for _, chNotification := range notifs {
+				dispatchChildNodeNotification(
+					supSpec,
+					supChildrenSpecs,
+					supRuntimeName,
+					supChildren,
+					supNotifyCh,
+					restarts,
+					chNotification,
+				)
+			}
+			for _, msg := range msgs {
+				supChildrenSpecs, supChildren = dispatchCtrlMsg(
+					eventNotifier,
+					supSpec,
+					supChildrenSpecs,
+					supRuntimeName,
+					supChildren,
+					supNotifyCh,
+					restarts,
+					msg,
 				)
 			}
 
 		case msg := <-ctrlCh:
-			supChildrenSpecs, supChildren = handleCtrlMsg(
+			supChildrenSpecs, supChildren = dispatchCtrlMsg(
 				eventNotifier,
 				supSpec,
 				supChildrenSpecs,
 				supRuntimeName,
 				supChildren,
 				supNotifyCh,
+				restarts,
 				msg,
 			)
 		}
 	}
 }
+
+// dispatchChildNodeNotification handles the notification of a child node. When
+// the child must be restarted, the restart happens in the background and its
+// result is received later on the monitor loop (check restartTracker).
+func dispatchChildNodeNotification(
+	supSpec SupervisorSpec,
+	supChildrenSpecs []c.ChildSpec,
+	supRuntimeName string,
+	supChildren map[string]c.Child,
+	supNotifyCh chan c.ChildNotification,
+	restarts *restartTracker,
+	chNotification c.ChildNotification,
+) {
+	// the restarted child finished before we got the result of its restart
+	if restarts.isRestarting(chNotification.GetName()) {
+		restarts.deferNotification(chNotification)
+		return
+	}
+
+	prevCh, ok := supChildren[chNotification.GetName()]
+
+	if !ok {
+		// TODO: Expand on this case, I think this is highly unlikely, but would
+		// like to exercise this branch in test somehow (if possible)
+		panic(
+			fmt.Errorf(
+				"something horribly wrong happened here (name: %s, tag: %s)",
+				prevCh.GetRuntimeName(),
+				prevCh.GetTag(),
+			),
+		)
+	}
+
+	if restartsOnNotification(prevCh, chNotification) {
+		restarts.restart(
+			supSpec,
+			supChildrenSpecs,
+			supRuntimeName,
+			supChildren,
+			supNotifyCh,
+			prevCh,
+			chNotification,
+		)
+		return
+	}
+
+	// children that do not get restarted are handled right away, no error
+	// tolerance is checked on this scenario
+	_ = handleChildNodeNotification(
+		restarts.startCtx,
+		supSpec.getEventNotifier(),
+		supRuntimeName,
+		supChildren,
+		supNotifyCh,
+		prevCh,
+		chNotification,
+	)
+}
+
+// dispatchCtrlMsg handles the given control message, unless the message is for
+// a child that is restarting; in that case, the message gets handled once the
+// restart finishes.
+func dispatchCtrlMsg(
+	eventNotifier EventNotifier,
+	supSpec SupervisorSpec,
+	supChildrenSpecs []c.ChildSpec,
+	supRuntimeName string,
+	supChildren map[string]c.Child,
+	supNotifyCh chan c.ChildNotification,
+	restarts *restartTracker,
+	msg ctrlMsg,
+) ([]c.ChildSpec, map[string]c.Child) {
+	if nodeMsg, ok := msg.(nodeCtrlMsg); ok && restarts.isRestarting(nodeMsg.getNodeName()) {
+		restarts.deferCtrlMsg(nodeMsg)
+		return supChildrenSpecs, supChildren
+	}
+	// a manual restart happens in the background, like the restart of a failed
+	// child
+	if restartMsg, ok := msg.(restartNodeMsg); ok {
+		restarts.restartNode(
+			supSpec,
+			supChildrenSpecs,
+			supRuntimeName,
+			supChildren,
+			supNotifyCh,
+			restartMsg,
+		)
+		return supChildrenSpecs, supChildren
+	}
+	return handleCtrlMsg(
+		eventNotifier,
+		supSpec,
+		supChildrenSpecs,
+		supRuntimeName,
+		supChildren,
+		supNotifyCh,
+		msg,
+	)
+}
+
+// waitChildNodeRestarts waits for the restarts that are happening in the
+// background, and handles the control messages that were deferred while the
+// children were restarting. This function is used before the supervisor
+// terminates.
+func waitChildNodeRestarts(
+	eventNotifier EventNotifier,
+	supSpec SupervisorSpec,
+	supChildrenSpecs []c.ChildSpec,
+	supRuntimeName string,
+	supChildren map[string]c.Child,
+	supNotifyCh chan c.ChildNotification,
+	restarts *restartTracker,
+) []c.ChildSpec {
+	for _, msg := range restarts.wait(supChildren) {
+		supChildrenSpecs, _ = handleCtrlMsg(
+			eventNotifier,
+			supSpec,
+			supChildrenSpecs,
+			supRuntimeName,
+			supChildren,
+			supNotifyCh,
+			msg,
+		)
+	}
+	return supChildrenSpecs
+}
@@ -0,0 +1,177 @@
+package cap
+
+// This file contains the implementation of node overrides; these allow to
+// change the settings of nodes that are created by code we don't own (e.g.
+// sub-trees from a library).
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/capatazlib/go-capataz/internal/c"
+)
+
+// nodeOverride contains the WorkerOpt values that must be applied to the nodes
+// with a runtime name that matches the pattern
+type nodeOverride struct {
+	pattern string
+	opts    []WorkerOpt
+}
+
+// matches indicates if the given runtime name matches the override pattern
+func (no nodeOverride) matches(runtimeName string) bool {
+	// the pattern is validated when the override is created
+	matched, _ := path.Match(no.pattern, runtimeName)
+	return matched
+}
+
+// NodeOverrideMatch reports that a node override was applied to a node of the
+// supervision tree
+type NodeOverrideMatch struct {
+	pattern     string
+	runtimeName string
+}
+
+// GetPattern returns the pattern given to WithNodeOverride
+func (nom NodeOverrideMatch) GetPattern() string {
+	return nom.pattern
+}
+
+// GetRuntimeName returns the runtime name of the node that matched the pattern
+func (nom NodeOverrideMatch) GetRuntimeName() string {
+	return nom.runtimeName
+}
+
+// nodeOverrideReport keeps track of the node overrides that got applied on a
+// supervision tree. It is shared with all the sub-trees of a root supervisor.
+type nodeOverrideReport struct {
+	mux     *sync.Mutex
+	matches map[NodeOverrideMatch]struct{}
+}
+
+// newNodeOverrideReport creates a new nodeOverrideReport
+func newNodeOverrideReport() *nodeOverrideReport {
+	var mux sync.Mutex
+
+	return &nodeOverrideReport{
+		mux:     &mux,
+		matches: make(map[NodeOverrideMatch]struct{}),
+	}
+}
+
+// add registers that the given pattern matched the given runtime name
+func (nor *nodeOverrideReport) add(pattern, runtimeName string) {
+	nor.mux.Lock()
+	defer nor.mux.Unlock()
+	nor.matches[NodeOverrideMatch{pattern: pattern, runtimeName: runtimeName}] = struct{}{}
+}
+
+// list returns the registered matches sorted by runtime name and pattern
+func (nor *nodeOverrideReport) list() []NodeOverrideMatch {
+	nor.mux.Lock()
+	defer nor.mux.Unlock()
+
+	matches := make([]NodeOverrideMatch, 0, len(nor.matches))
+	for match := range nor.matches {
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].runtimeName != matches[j].runtimeName {
+			return matches[i].runtimeName < matches[j].runtimeName
+		}
+		return matches[i].pattern < matches[j].pattern
+	})
+	return matches
+}
+
+// applyNodeOverrides applies the WorkerOpt values of the overrides that match
+// the runtime name of the given child spec. The overrides are applied in
+// order, when many of them match, the last one wins.
+//
+// Overrides never change the tag of a node, nor the Shutdown setting of a
+// sub-tree (check Subtree); those are restored after the overrides are applied.
+func (spec SupervisorSpec) applyNodeOverrides(
+	supRuntimeName string,
+	chSpec c.ChildSpec,
+) c.ChildSpec {
+	if len(spec.nodeOverrides) == 0 {
+		return chSpec
+	}
+
+	tag, shutdown := chSpec.GetTag(), chSpec.Shutdown
+
+	chRuntimeName := fmt.Sprintf("%s%s%s", supRuntimeName, nodeSepToken, chSpec.GetName())
+	for _, override := range spec.nodeOverrides {
+		if !override.matches(chRuntimeName) {
+			continue
+		}
+		for _, optFn := range override.opts {
+			optFn(&chSpec)
+		}
+		if spec.nodeOverrideReport != nil {
+			spec.nodeOverrideReport.add(override.pattern, chRuntimeName)
+		}
+	}
+
+	chSpec.Tag = tag
+	if tag == c.Supervisor {
+		// sub-trees must always wait for the termination of their children
+		chSpec.Shutdown = shutdown
+	}
+	return chSpec
+}
+
+// WithNodeOverride is an Opt that applies the given WorkerOpt values to every
+// descendant node with a runtime name that matches the given pattern. The
+// pattern follows the syntax of path.Match, where a `*` does not match the
+// `/` separator of sub-trees.
+//
+// This function allows to change settings of nodes that are created by code
+// you don't own (e.g. a sub-tree from a library), or to tune them for a
+// specific environment.
+//
+// Overrides are applied after the node settings; when many overrides match a
+// node, they are applied in the order they were given, and the overrides of a
+// supervisor are applied after the ones of its sub-trees (e.g. the ones of the
+// root supervisor win).
+//
+// Overrides can't change the Shutdown setting of sub-trees, nor the kind of a
+// node (worker or supervisor); those settings are ignored on the overrides
+// (check Subtree for the Shutdown setting of sub-trees).
+//
+// The GetNodeOverrideMatches method of Supervisor reports which overrides
+// matched which nodes.
+//
+// Example:
+//
+//   // Give all the db workers of the root's sub-trees more time to stop
+//   cap.NewSupervisorSpec("root",
+//     cap.WithNodes(...),
+//     cap.WithNodeOverride("root/*/db-*", cap.WithShutdown(cap.Timeout(30*time.Second))),
+//   )
+//
+// The given pattern must be valid, otherwise, the system will panic.
+//
+func WithNodeOverride(pattern string, opts ...WorkerOpt) Opt {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(fmt.Sprintf("invalid node override pattern %q: %v", pattern, err))
+	}
+	return func(spec *SupervisorSpec) {
+		spec.nodeOverrides = append(
+			spec.nodeOverrides,
+			nodeOverride{pattern: pattern, opts: opts},
+		)
+	}
+}
+
+// GetNodeOverrideMatches returns the overrides given with WithNodeOverride that
+// matched nodes of the supervision tree (including dynamically spawned nodes
+// and the nodes of restarted sub-trees), sorted by runtime name.
+func (sup Supervisor) GetNodeOverrideMatches() []NodeOverrideMatch {
+	if sup.spec.nodeOverrideReport == nil {
+		return []NodeOverrideMatch{}
+	}
+	return sup.spec.nodeOverrideReport.list()
+}
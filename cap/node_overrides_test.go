@@ -0,0 +1,169 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	"github.com/capatazlib/go-capataz/internal/c"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// getShutdownTimeout returns the timeout reported by the node with the given
+// name on the given termination error
+func getShutdownTimeout(t *testing.T, err error, nodeName string) time.Duration {
+	var supErr *cap.SupervisorError
+	if !assert.True(t, errors.As(err, &supErr)) {
+		return 0
+	}
+	var timeoutErr *cap.ShutdownTimeoutError
+	if !assert.True(t, errors.As(supErr.GetNodeErrors()[nodeName], &timeoutErr)) {
+		return 0
+	}
+	return timeoutErr.GetTimeout()
+}
+
+func TestNodeOverrideOnSubtree(t *testing.T) {
+	stuck, unblock := StuckWorker("db-primary")
+	defer unblock()
+
+	b0 := cap.NewSupervisorSpec(
+		"branch0",
+		cap.WithNodes(stuck, WaitDoneWorker("cache")),
+	)
+
+	supSpec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(cap.Subtree(b0), WaitDoneWorker("db-main")),
+		cap.WithNodeOverride("root/*/db-*", cap.WithShutdown(cap.Timeout(10*time.Millisecond))),
+		cap.WithNodeOverride("root/branch0/cache", cap.WithRestart(cap.Temporary)),
+	)
+
+	sup, err := supSpec.Start(context.TODO())
+	assert.NoError(t, err)
+
+	matches := sup.GetNodeOverrideMatches()
+	if assert.Len(t, matches, 2) {
+		// NOTE: a `*` does not match the separator of sub-trees, so the
+		// root/db-main node does not match
+		assert.Equal(t, "root/branch0/cache", matches[0].GetRuntimeName())
+		assert.Equal(t, "root/branch0/cache", matches[0].GetPattern())
+		assert.Equal(t, "root/branch0/db-primary", matches[1].GetRuntimeName())
+		assert.Equal(t, "root/*/db-*", matches[1].GetPattern())
+	}
+
+	// the stuck worker did not wait its default of 5 seconds
+	terminateErr := sup.Terminate()
+
+	var supErr *cap.SupervisorError
+	if assert.True(t, errors.As(terminateErr, &supErr)) {
+		assert.Equal(
+			t,
+			10*time.Millisecond,
+			getShutdownTimeout(t, supErr.GetNodeErrors()["branch0"], "db-primary"),
+		)
+	}
+}
+
+func TestNodeOverridePrecedence(t *testing.T) {
+	stuck, unblock := StuckWorker(
+		"db-primary",
+		cap.WithShutdown(cap.Timeout(30*time.Millisecond)),
+	)
+	defer unblock()
+
+	b0 := cap.NewSupervisorSpec(
+		"branch0",
+		cap.WithNodes(stuck),
+		cap.WithNodeOverride("root/branch0/db-primary", cap.WithShutdown(cap.Timeout(20*time.Millisecond))),
+	)
+
+	supSpec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(cap.Subtree(b0)),
+		cap.WithNodeOverride("root/*/*", cap.WithShutdown(cap.Timeout(15*time.Millisecond))),
+		cap.WithNodeOverride("root/*/db-*", cap.WithShutdown(cap.Timeout(10*time.Millisecond))),
+	)
+
+	sup, err := supSpec.Start(context.TODO())
+	assert.NoError(t, err)
+
+	assert.Len(t, sup.GetNodeOverrideMatches(), 3)
+
+	// the last override of the root supervisor wins
+	var supErr *cap.SupervisorError
+	if assert.True(t, errors.As(sup.Terminate(), &supErr)) {
+		assert.Equal(
+			t,
+			10*time.Millisecond,
+			getShutdownTimeout(t, supErr.GetNodeErrors()["branch0"], "db-primary"),
+		)
+	}
+}
+
+func TestNodeOverrideOnDynSupervisor(t *testing.T) {
+	dyn, err := cap.NewDynSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodeOverride("root/db-*", cap.WithShutdown(cap.Timeout(10*time.Millisecond))),
+	)
+	assert.NoError(t, err)
+
+	stuck, unblock := StuckWorker("db-primary")
+	defer unblock()
+
+	_, err = dyn.Spawn(stuck)
+	assert.NoError(t, err)
+
+	_, err = dyn.Spawn(WaitDoneWorker("cache"))
+	assert.NoError(t, err)
+
+	matches := dyn.GetNodeOverrideMatches()
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, "root/db-primary", matches[0].GetRuntimeName())
+	}
+
+	assert.Equal(t, 10*time.Millisecond, getShutdownTimeout(t, dyn.Terminate(), "db-primary"))
+}
+
+func TestNodeOverrideKeepsSubtreeSettings(t *testing.T) {
+	b0 := cap.NewSupervisorSpec("branch0", cap.WithNodes(WaitDoneWorker("child0")))
+
+	// a WorkerOpt that tries to turn nodes into workers
+	asWorker := func(chSpec *c.ChildSpec) { chSpec.Tag = cap.WorkerT }
+
+	info, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(cap.Subtree(b0), WaitDoneWorker("cache")),
+		cap.WithNodeOverride(
+			"root/*",
+			cap.WithShutdown(cap.Timeout(10*time.Millisecond)),
+			cap.WithRestart(cap.Transient),
+			asWorker,
+		),
+	).Describe()
+	assert.NoError(t, err)
+
+	children := info.GetChildren()
+	if assert.Len(t, children, 2) {
+		// the sub-tree keeps its tag and waits for its children indefinitely,
+		// the other settings are overridden
+		assert.Equal(t, cap.SupervisorT, children[0].GetTag())
+		assert.Equal(t, cap.Indefinitely, children[0].GetShutdown())
+		assert.Equal(t, cap.Transient, children[0].GetRestart())
+
+		assert.Equal(t, cap.WorkerT, children[1].GetTag())
+		assert.Equal(t, cap.Timeout(10*time.Millisecond), children[1].GetShutdown())
+		assert.Equal(t, cap.Transient, children[1].GetRestart())
+	}
+}
+
+func TestNodeOverrideInvalidPattern(t *testing.T) {
+	assert.Panics(t, func() {
+		cap.WithNodeOverride("root/[", cap.WithRestart(cap.Temporary))
+	})
+}
@@ -1,13 +1,14 @@
 package cap
 
 import (
+	"context"
 	"errors"
-	"time"
 
 	"github.com/capatazlib/go-capataz/internal/c"
 )
 
 func oneForOneRestart(
+	startCtx context.Context,
 	eventNotifier EventNotifier,
 	supRuntimeName string,
 	supChildren map[string]c.Child,
@@ -18,24 +19,35 @@ func oneForOneRestart(
 	chSpec := prevCh.GetSpec()
 	chName := chSpec.GetName()
 
-	startTime := time.Now()
-	newCh, chRestartErr := prevCh.Restart(supRuntimeName, supNotifyCh, wasComplete)
+	startTime := chSpec.GetClock().Now()
+	newCh, chRestartErr := prevCh.Restart(startCtx, supRuntimeName, supNotifyCh, wasComplete)
 
-	if chRestartErr != nil {
+	if chRestartErr != nil && startCtx.Err() != nil {
+		// the supervisor is terminating and the start got cancelled
+		notifyChildNodeStartError(
+			eventNotifier, chSpec, prevCh.GetRuntimeName(), newCh, chRestartErr,
+		)
 		return c.Child{}, chRestartErr
 	}
 
+	if chRestartErr != nil {
+		// on a start failure, the returned child keeps track of the restart count
+		// for the next restart
+		return newCh, chRestartErr
+	}
+
 	// We want to keep track of the updated restartCount which is in the newCh
 	// record, we must override the child independently of the outcome.
 	supChildren[chName] = newCh
 
 	if newCh.GetTag() == c.Worker {
-		eventNotifier.workerStarted(newCh.GetRuntimeName(), startTime)
+		eventNotifier.workerStarted(chSpec.GetClock(), newCh.GetRuntimeName(), startTime)
 	}
 	return newCh, nil
 }
 
 func oneForOneRestartLoop(
+	startCtx context.Context,
 	eventNotifier EventNotifier,
 	supRuntimeName string,
 	supChildren map[string]c.Child,
@@ -45,6 +57,7 @@ func oneForOneRestartLoop(
 ) *c.ErrorToleranceReached {
 	for {
 		newCh, restartErr := oneForOneRestart(
+			startCtx,
 			eventNotifier,
 			supRuntimeName,
 			supChildren,
@@ -68,7 +81,15 @@ func oneForOneRestartLoop(
 			return toleranceErr
 		}
 
-		// otherwise, repeat until error threshold is met
+		// the supervisor is terminating, the child is not running anymore
+		if startCtx.Err() != nil {
+			delete(supChildren, prevCh.GetName())
+			return nil
+		}
+
+		// otherwise, repeat until error threshold is met; the start failures
+		// count against the error tolerance of the child
 		prevCh = newCh
+		wasComplete = false
 	}
 }
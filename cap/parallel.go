@@ -0,0 +1,260 @@
+package cap
+
+// This file contains the parallel start and termination of the children nodes
+// of a supervisor (check WithParallelStart and WithParallelTermination)
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/capatazlib/go-capataz/internal/c"
+)
+
+// startLevels returns the children grouped in levels sorted for the supervisor
+// start; the children of a level only depend on children of previous levels,
+// so they can be started at the same time.
+func (spec SupervisorSpec) startLevels(supChildrenSpecs []c.ChildSpec) [][]c.ChildSpec {
+	sorted := spec.sortStart(supChildrenSpecs)
+
+	levelByName := make(map[string]int, len(sorted))
+	for _, chSpec := range sorted {
+		levelByName[chSpec.GetName()] = 0
+	}
+
+	levels := make([][]c.ChildSpec, 0)
+	for _, chSpec := range sorted {
+		level := 0
+		for _, depName := range chSpec.GetDependencies() {
+			// dependencies that are not present are ignored, like in sortStart
+			if depLevel, ok := levelByName[depName]; ok && depLevel >= level {
+				level = depLevel + 1
+			}
+		}
+		levelByName[chSpec.GetName()] = level
+		if level == len(levels) {
+			levels = append(levels, make([]c.ChildSpec, 0))
+		}
+		levels[level] = append(levels[level], chSpec)
+	}
+	return levels
+}
+
+// terminationLevels returns the children grouped in levels sorted for the
+// supervisor stop, which is the reverse of the start levels
+func (spec SupervisorSpec) terminationLevels(supChildrenSpecs []c.ChildSpec) [][]c.ChildSpec {
+	levels := spec.startLevels(supChildrenSpecs)
+	for i, j := 0, len(levels)-1; i < j; i, j = i+1, j-1 {
+		levels[i], levels[j] = levels[j], levels[i]
+	}
+	for _, level := range levels {
+		for i, j := 0, len(level)-1; i < j; i, j = i+1, j-1 {
+			level[i], level[j] = level[j], level[i]
+		}
+	}
+	return levels
+}
+
+// startChildNodesInParallel starts the children one level at a time (check
+// startLevels), running at most spec.startConcurrency starts at the same time.
+// In case any child fails to start (or the given context is done), the children
+// that were not launched yet are not started, and the ones that got started are
+// terminated.
+func startChildNodesInParallel(
+	ctx context.Context,
+	spec SupervisorSpec,
+	supChildrenSpecs []c.ChildSpec,
+	supRuntimeName string,
+	notifyCh chan c.ChildNotification,
+) (map[string]c.Child, error) {
+	children := make(map[string]c.Child)
+	levels := spec.startLevels(supChildrenSpecs)
+
+	for levelIx, level := range levels {
+		startedChildren := make([]c.Child, len(level))
+		startErrs := make([]error, len(level))
+		launched := make([]bool, len(level))
+
+		var wg sync.WaitGroup
+		semCh := make(chan struct{}, spec.startConcurrency)
+
+		// abortCh gets closed once a child fails to start, no more children get
+		// started after that
+		abortCh := make(chan struct{})
+		var abortOnce sync.Once
+
+	launchLoop:
+		for i, chSpec := range level {
+			select {
+			case semCh <- struct{}{}:
+			case <-abortCh:
+				break launchLoop
+			case <-ctx.Done():
+				// no more children get started once the context is done
+				break launchLoop
+			}
+			// the start of a sibling may have failed while we waited
+			select {
+			case <-abortCh:
+				<-semCh
+				break launchLoop
+			default:
+			}
+			launched[i] = true
+			wg.Add(1)
+			go func(i int, chSpec c.ChildSpec) {
+				defer func() {
+					<-semCh
+					wg.Done()
+				}()
+				startedChildren[i], startErrs[i] = startChildNode(
+					ctx,
+					spec,
+					supRuntimeName,
+					notifyCh,
+					chSpec,
+				)
+				if startErrs[i] != nil {
+					abortOnce.Do(func() { close(abortCh) })
+				}
+			}(i, chSpec)
+		}
+		wg.Wait()
+
+		if ctx.Err() != nil {
+			pendingSpecs := make([]c.ChildSpec, 0)
+			startErrMap := make(map[string]error)
+			for i, chSpec := range level {
+				if launched[i] && startErrs[i] == nil {
+					children[chSpec.GetName()] = startedChildren[i]
+					continue
+				}
+				if startErrs[i] != nil {
+					startErrMap[chSpec.GetName()] = startErrs[i]
+				}
+				pendingSpecs = append(pendingSpecs, chSpec)
+			}
+			for _, nextLevel := range levels[levelIx+1:] {
+				pendingSpecs = append(pendingSpecs, nextLevel...)
+			}
+			// when all the children started, the monitor loop handles the context
+			if len(pendingSpecs) > 0 {
+				return nil, cancelChildNodesStart(
+					ctx,
+					spec,
+					supRuntimeName,
+					supChildrenSpecs,
+					children,
+					pendingSpecs,
+					abortStartErrors(startErrMap),
+				)
+			}
+		}
+
+		var chStartErr error
+		startErrMap := make(map[string]error)
+		pendingNodes := make([]string, 0)
+		for i, chSpec := range level {
+			if !launched[i] {
+				pendingNodes = append(pendingNodes, chSpec.GetName())
+				continue
+			}
+			if startErrs[i] != nil {
+				// the error of the first child (in start order) that failed is the
+				// cause of the supervisor error, the errors of all of them are
+				// reported per child
+				if chStartErr == nil {
+					chStartErr = startErrs[i]
+				}
+				startErrMap[chSpec.GetName()] = startErrs[i]
+				continue
+			}
+			children[chSpec.GetName()] = startedChildren[i]
+		}
+
+		if chStartErr != nil {
+			for _, nextLevel := range levels[levelIx+1:] {
+				for _, chSpec := range nextLevel {
+					pendingNodes = append(pendingNodes, chSpec.GetName())
+				}
+			}
+			for i, chName := range pendingNodes {
+				pendingNodes[i] = strings.Join([]string{supRuntimeName, chName}, nodeSepToken)
+			}
+
+			nodeErrMap, _ := terminateChildNodes(
+				spec,
+				supRuntimeName,
+				supChildrenSpecs,
+				children,
+				true, /* drained: a rollback does not drain the children */
+				c.ParentFailureReason,
+				time.Time{}, /* no parent deadline */
+			)
+			for chName, startErr := range startErrMap {
+				nodeErrMap[chName] = startErr
+			}
+			// Is important we stop the children before we finish the supervisor
+			return nil, &SupervisorError{
+				supRuntimeName: supRuntimeName,
+				nodeErr:        chStartErr,
+				nodeErrMap:     nodeErrMap,
+				pendingNodes:   pendingNodes,
+			}
+		}
+	}
+	return children, nil
+}
+
+// terminateChildNodesInParallel terminates the children one level at a time
+// (check terminationLevels); the children of the same level get terminated at
+// the same time. It returns the termination errors of the children, and the
+// runtime names of the children that did not stop before the given deadline
+// (if any) in termination order.
+func terminateChildNodesInParallel(
+	spec SupervisorSpec,
+	supChildrenSpecs []c.ChildSpec,
+	supChildren map[string]c.Child,
+	deadline time.Time,
+	reason c.TerminationReason,
+) (map[string]error, []string) {
+	eventNotifier := spec.eventNotifier
+	supNodeErrMap := make(map[string]error)
+	pendingNodes := make([]string, 0)
+
+	for _, level := range spec.terminationLevels(supChildrenSpecs) {
+		terminationErrs := make([]error, len(level))
+		pending := make([][]string, len(level))
+
+		var wg sync.WaitGroup
+		for i, chSpec := range level {
+			// check terminateChildNodes for the scenarios where a child is not
+			// present
+			ch, ok := supChildren[chSpec.GetName()]
+			if !ok {
+				continue
+			}
+			wg.Add(1)
+			go func(i int, ch c.Child) {
+				defer wg.Done()
+				pending[i], terminationErrs[i] = terminateChildNodeUntil(
+					eventNotifier,
+					ch,
+					reason,
+					deadline,
+				)
+			}(i, ch)
+		}
+		wg.Wait()
+
+		for i, chSpec := range level {
+			if terminationErrs[i] != nil {
+				supNodeErrMap[chSpec.GetName()] = terminationErrs[i]
+			}
+			pendingNodes = append(pendingNodes, pending[i]...)
+		}
+	}
+
+	return supNodeErrMap, pendingNodes
+}
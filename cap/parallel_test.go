@@ -0,0 +1,324 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// concurrencyGauge keeps track of the maximum number of workers that were
+// executing an operation at the same time
+type concurrencyGauge struct {
+	mux     sync.Mutex
+	current int
+	max     int
+}
+
+func (g *concurrencyGauge) track(delay time.Duration) {
+	g.mux.Lock()
+	g.current++
+	if g.current > g.max {
+		g.max = g.current
+	}
+	g.mux.Unlock()
+
+	time.Sleep(delay)
+
+	g.mux.Lock()
+	g.current--
+	g.mux.Unlock()
+}
+
+func (g *concurrencyGauge) getMax() int {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return g.max
+}
+
+// slowWorker creates a worker that takes the given delay to start and to
+// terminate, the start and termination are tracked by the given gauges
+func slowWorker(
+	name string,
+	delay time.Duration,
+	startGauge, stopGauge *concurrencyGauge,
+	opts ...cap.WorkerOpt,
+) cap.Node {
+	return cap.NewWorkerWithNotifyStart(
+		name,
+		func(ctx context.Context, notifyStart cap.NotifyStartFn) error {
+			startGauge.track(delay)
+			notifyStart(nil)
+			<-ctx.Done()
+			stopGauge.track(delay)
+			return nil
+		},
+		opts...,
+	)
+}
+
+func TestParallelStartAndTermination(t *testing.T) {
+	var startGauge, stopGauge concurrencyGauge
+
+	nodes := make([]cap.Node, 0, 8)
+	for i := 0; i < 8; i++ {
+		nodes = append(
+			nodes,
+			slowWorker(fmt.Sprintf("child%d", i), 20*time.Millisecond, &startGauge, &stopGauge),
+		)
+	}
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(nodes...),
+		[]cap.Opt{cap.WithParallelStart(3), cap.WithParallelTermination()},
+		func(EventManager) {},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, startGauge.getMax())
+	assert.Equal(t, 8, stopGauge.getMax())
+
+	AssertPartialMatch(t, events,
+		[]EventP{
+			SupervisorStarted("root"),
+			SupervisorTerminated("root"),
+		},
+	)
+	assert.Equal(t, 18, len(events))
+}
+
+func TestParallelStartAndTerminationWithDependencies(t *testing.T) {
+	var startGauge, stopGauge concurrencyGauge
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			slowWorker("http-server", time.Millisecond, &startGauge, &stopGauge,
+				cap.WithDependsOn("cache", "db-pool")),
+			slowWorker("cache", time.Millisecond, &startGauge, &stopGauge),
+			slowWorker("db-pool", time.Millisecond, &startGauge, &stopGauge),
+		),
+		[]cap.Opt{cap.WithParallelStart(3), cap.WithParallelTermination()},
+		func(EventManager) {},
+	)
+
+	assert.NoError(t, err)
+
+	// dependencies are started before, and stopped after their dependents
+	AssertPartialMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/cache"),
+			WorkerStarted("root/http-server"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/http-server"),
+			WorkerTerminated("root/cache"),
+			SupervisorTerminated("root"),
+		},
+	)
+	AssertPartialMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/db-pool"),
+			WorkerStarted("root/http-server"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/http-server"),
+			WorkerTerminated("root/db-pool"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestParallelStartFailure(t *testing.T) {
+	var startGauge, stopGauge concurrencyGauge
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			slowWorker("child0", time.Millisecond, &startGauge, &stopGauge),
+			FailStartWorker("child1"),
+			FailTerminationWorker("child2", errors.New("child2 termination failed")),
+			slowWorker("child3", time.Millisecond, &startGauge, &stopGauge,
+				cap.WithDependsOn("child1")),
+		),
+		[]cap.Opt{cap.WithParallelStart(4)},
+		func(EventManager) {},
+	)
+
+	var supErr *cap.SupervisorError
+	if assert.True(t, errors.As(err, &supErr)) {
+		assert.Equal(t, "FailStartWorker child1", errors.Unwrap(supErr).Error())
+		// the start errors and the termination errors of the rollback are
+		// reported per child
+		nodeErrs := supErr.GetNodeErrors()
+		assert.Equal(t, 2, len(nodeErrs))
+		assert.Equal(t, "FailStartWorker child1", nodeErrs["child1"].Error())
+		assert.Equal(t, "child2 termination failed", nodeErrs["child2"].Error())
+	}
+
+	// children that depend on the failing child are never started, and the
+	// started ones are terminated in reverse order
+	AssertExactMatch(t, events[3:],
+		[]EventP{
+			WorkerFailed("root/child2"),
+			WorkerTerminated("root/child0"),
+			SupervisorStartFailed("root"),
+		},
+	)
+}
+
+func TestParallelStartMultipleFailures(t *testing.T) {
+	// the failing workers are launched before any of them reports its start
+	// failure
+	var arrived int32
+	barrierCh := make(chan struct{})
+	failStartAfterBarrier := func(name string) cap.Node {
+		return cap.NewWorkerWithNotifyStart(
+			name,
+			func(ctx context.Context, notifyStart cap.NotifyStartFn) error {
+				if atomic.AddInt32(&arrived, 1) == 2 {
+					close(barrierCh)
+				}
+				<-barrierCh
+				err := fmt.Errorf("%s start failed", name)
+				notifyStart(err)
+				return err
+			},
+		)
+	}
+
+	_, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			FailTerminationWorker("child0", errors.New("child0 termination failed")),
+			failStartAfterBarrier("child1"),
+			failStartAfterBarrier("child2"),
+		),
+		[]cap.Opt{cap.WithParallelStart(3)},
+		func(EventManager) {},
+	)
+
+	var supErr *cap.SupervisorError
+	if assert.True(t, errors.As(err, &supErr)) {
+		assert.Equal(t, "child1 start failed", errors.Unwrap(supErr).Error())
+		nodeErrs := supErr.GetNodeErrors()
+		assert.Equal(t, 3, len(nodeErrs))
+		assert.Equal(t, "child0 termination failed", nodeErrs["child0"].Error())
+		assert.Equal(t, "child1 start failed", nodeErrs["child1"].Error())
+		assert.Equal(t, "child2 start failed", nodeErrs["child2"].Error())
+	}
+}
+
+func TestParallelStartFailureStopsLaunches(t *testing.T) {
+	var starts int32
+	countedWorker := func(name string, opts ...cap.WorkerOpt) cap.Node {
+		return cap.NewWorker(name, func(ctx context.Context) error {
+			atomic.AddInt32(&starts, 1)
+			<-ctx.Done()
+			return nil
+		}, opts...)
+	}
+
+	var startGauge, stopGauge concurrencyGauge
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			FailStartWorker("child0"),
+			// child0 fails while child1 is starting
+			slowWorker("child1", 50*time.Millisecond, &startGauge, &stopGauge),
+			countedWorker("child2"),
+			countedWorker("child3", cap.WithDependsOn("child1")),
+		),
+		[]cap.Opt{cap.WithParallelStart(2)},
+		func(EventManager) {},
+	)
+
+	var supErr *cap.SupervisorError
+	if assert.True(t, errors.As(err, &supErr)) {
+		assert.Equal(t, "FailStartWorker child0", errors.Unwrap(supErr).Error())
+		assert.Equal(t, []string{"root/child2", "root/child3"}, supErr.GetPendingNodes())
+	}
+
+	// the children that were not launched yet are never started
+	assert.Equal(t, int32(0), atomic.LoadInt32(&starts))
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStartFailed("root/child0"),
+			WorkerStarted("root/child1"),
+			WorkerTerminated("root/child1"),
+			SupervisorStartFailed("root"),
+		},
+	)
+}
+
+func TestParallelTerminationErrors(t *testing.T) {
+	_, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			FailTerminationWorker("child0", errors.New("child0 termination failed")),
+			WaitDoneWorker("child1"),
+			FailTerminationWorker("child2", errors.New("child2 termination failed")),
+		),
+		[]cap.Opt{cap.WithParallelTermination()},
+		func(EventManager) {},
+	)
+
+	var supErr *cap.SupervisorError
+	if assert.True(t, errors.As(err, &supErr)) {
+		nodeErrs := supErr.GetNodeErrors()
+		assert.Equal(t, 2, len(nodeErrs))
+		assert.Equal(t, "child0 termination failed", nodeErrs["child0"].Error())
+		assert.Equal(t, "child2 termination failed", nodeErrs["child2"].Error())
+	}
+}
+
+func TestParallelStartInvalidConcurrency(t *testing.T) {
+	assert.Panics(t, func() { cap.WithParallelStart(0) })
+}
+
+func benchmarkStartAndTerminate(b *testing.B, opts ...cap.Opt) {
+	var startGauge, stopGauge concurrencyGauge
+
+	nodes := make([]cap.Node, 0, 50)
+	for i := 0; i < 50; i++ {
+		nodes = append(
+			nodes,
+			slowWorker(fmt.Sprintf("child%d", i), time.Millisecond, &startGauge, &stopGauge),
+		)
+	}
+	supSpec := cap.NewSupervisorSpec("root", cap.WithNodes(nodes...), opts...)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sup, err := supSpec.Start(context.TODO())
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := sup.Terminate(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSequentialStartAndTermination(b *testing.B) {
+	benchmarkStartAndTerminate(b)
+}
+
+func BenchmarkParallelStartAndTermination(b *testing.B) {
+	benchmarkStartAndTerminate(b, cap.WithParallelStart(10), cap.WithParallelTermination())
+}
@@ -0,0 +1,149 @@
+package cap_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+)
+
+// labelsWorker creates a worker that reports the pprof labels of its context
+// on the given channel, it fails the given number of times before waiting for
+// its termination
+func labelsWorker(
+	name string,
+	failCount int,
+	labelsCh chan<- map[string]string,
+	opts ...cap.WorkerOpt,
+) cap.Node {
+	return cap.NewWorker(name, func(ctx context.Context) error {
+		labels := make(map[string]string)
+		pprof.ForLabels(ctx, func(k, v string) bool {
+			labels[k] = v
+			return true
+		})
+		labelsCh <- labels
+		if failCount > 0 {
+			failCount--
+			return errors.New("failing worker")
+		}
+		<-ctx.Done()
+		return nil
+	}, opts...)
+}
+
+func TestPprofLabels(t *testing.T) {
+	labelsCh := make(chan map[string]string, 3)
+
+	b0 := cap.NewSupervisorSpec("branch0", cap.WithNodes(labelsWorker("child0", 0, labelsCh)))
+
+	_, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			cap.Subtree(b0),
+			labelsWorker("child1", 1, labelsCh, cap.WithTolerance(1, 5*time.Second)),
+		),
+		[]cap.Opt{},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			evIt.SkipTill(WorkerStarted("root/child1"))
+			evIt.SkipTill(WorkerStarted("root/child1"))
+		},
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(
+		t,
+		map[string]string{
+			"capataz.runtime_name":  "root/branch0/child0",
+			"capataz.node_tag":      "Worker",
+			"capataz.restart_count": "0",
+		},
+		<-labelsCh,
+	)
+	assert.Equal(
+		t,
+		map[string]string{
+			"capataz.runtime_name":  "root/child1",
+			"capataz.node_tag":      "Worker",
+			"capataz.restart_count": "0",
+		},
+		<-labelsCh,
+	)
+	// the restarted worker reports the number of restarts
+	assert.Equal(
+		t,
+		map[string]string{
+			"capataz.runtime_name":  "root/child1",
+			"capataz.node_tag":      "Worker",
+			"capataz.restart_count": "1",
+		},
+		<-labelsCh,
+	)
+}
+
+func TestPprofLabelsInheritedBySpawnedGoroutines(t *testing.T) {
+	spawnedCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	worker := cap.NewWorker("child0", func(ctx context.Context) error {
+		go func() {
+			close(spawnedCh)
+			<-doneCh
+		}()
+		<-ctx.Done()
+		return nil
+	})
+
+	supSpec := cap.NewSupervisorSpec("root", cap.WithNodes(worker))
+	sup, err := supSpec.Start(context.TODO())
+	assert.NoError(t, err)
+	<-spawnedCh
+
+	// the worker goroutine and the goroutine it spawned have labels
+	var buffer bytes.Buffer
+	assert.NoError(t, pprof.Lookup("goroutine").WriteTo(&buffer, 1))
+	assert.Equal(
+		t,
+		2,
+		bytes.Count(buffer.Bytes(), []byte(`"capataz.runtime_name":"root/child0"`)),
+	)
+
+	assert.NoError(t, sup.Terminate())
+}
+
+func TestWithoutPprofLabels(t *testing.T) {
+	labelsCh := make(chan map[string]string, 2)
+
+	b0 := cap.NewSupervisorSpec("branch0", cap.WithNodes(labelsWorker("child0", 0, labelsCh)))
+
+	_, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(cap.Subtree(b0), labelsWorker("child1", 0, labelsCh)),
+		[]cap.Opt{cap.WithoutPprofLabels()},
+		func(EventManager) {},
+	)
+	assert.NoError(t, err)
+
+	// the setting does not propagate to sub-trees
+	assert.Equal(
+		t,
+		map[string]string{
+			"capataz.runtime_name":  "root/branch0/child0",
+			"capataz.node_tag":      "Worker",
+			"capataz.restart_count": "0",
+		},
+		<-labelsCh,
+	)
+	assert.Empty(t, <-labelsCh)
+}
@@ -0,0 +1,440 @@
+package cap_test
+
+//
+// NOTE: The tests in this file generate random supervision trees, start them
+// and verify invariants that must hold on every tree. Check the gopter
+// documentation for details on generators and properties.
+//
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+const (
+	// propMaxDepth is the maximum number of sub-tree levels of a generated
+	// supervision tree
+	propMaxDepth = 2
+	// propMaxFanOut is the maximum number of children of a generated
+	// supervisor
+	propMaxFanOut = 3
+	// propErrWindow is the error window of the workers and sub-trees of a
+	// generated tree, it is big enough to never elapse while a test runs
+	propErrWindow = time.Minute
+)
+
+// treeModel is the specification of a generated supervision tree
+type treeModel struct {
+	order cap.Order
+	// tolerance is the number of errors the parent supervisor tolerates from
+	// this tree (ignored on the root tree)
+	tolerance uint32
+	children  []nodeModel
+}
+
+// nodeModel is the specification of a child of a generated supervision tree,
+// it is a worker when subtree is nil
+type nodeModel struct {
+	name    string
+	restart cap.Restart
+	subtree *treeModel
+}
+
+// supervisorRef references a supervisor of a generated supervision tree
+type supervisorRef struct {
+	path      string
+	tolerance uint32
+}
+
+// workerRef references a worker of a generated supervision tree
+type workerRef struct {
+	path    string
+	name    string
+	restart cap.Restart
+	// ancestors contains the supervisors of the worker, from its parent up to
+	// the root supervisor
+	ancestors []supervisorRef
+}
+
+// failureModel is the failure schedule of a worker of a generated supervision
+// tree
+type failureModel struct {
+	// workerIx is the index (modulo the number of workers) of the failing worker
+	workerIx int
+	// tolerance is the number of errors tolerated on the failing worker
+	tolerance uint32
+	// errCount is the number of times the failing worker fails
+	errCount int32
+}
+
+// sortedChildren returns the children of the tree in start order
+func (tree treeModel) sortedChildren() []nodeModel {
+	children := append(tree.children[:0:0], tree.children...)
+	if tree.order == cap.RightToLeft {
+		for i, j := 0, len(children)-1; i < j; i, j = i+1, j-1 {
+			children[i], children[j] = children[j], children[i]
+		}
+	}
+	return children
+}
+
+// workers returns all the workers of the tree
+func (tree treeModel) workers(path string, ancestors []supervisorRef) []workerRef {
+	ancestors = append(
+		[]supervisorRef{{path: path, tolerance: tree.tolerance}},
+		ancestors...,
+	)
+	output := make([]workerRef, 0)
+	for _, child := range tree.children {
+		childPath := strings.Join([]string{path, child.name}, "/")
+		if child.subtree != nil {
+			output = append(output, child.subtree.workers(childPath, ancestors)...)
+			continue
+		}
+		output = append(output, workerRef{
+			path:      childPath,
+			name:      child.name,
+			restart:   child.restart,
+			ancestors: ancestors,
+		})
+	}
+	return output
+}
+
+// buildNodes returns the nodes of the tree, the worker with the given path is
+// replaced with the given failing node
+func (tree treeModel) buildNodes(path, failingPath string, failingNode cap.Node) []cap.Node {
+	nodes := make([]cap.Node, 0, len(tree.children))
+	for _, child := range tree.children {
+		childPath := strings.Join([]string{path, child.name}, "/")
+		switch {
+		case child.subtree != nil:
+			nodes = append(nodes, cap.Subtree(
+				cap.NewSupervisorSpec(
+					child.name,
+					cap.WithNodes(child.subtree.buildNodes(childPath, failingPath, failingNode)...),
+					cap.WithStartOrder(child.subtree.order),
+				),
+				cap.WithTolerance(child.subtree.tolerance, propErrWindow),
+			))
+		case childPath == failingPath:
+			nodes = append(nodes, failingNode)
+		default:
+			nodes = append(nodes, WaitDoneWorker(child.name, cap.WithRestart(child.restart)))
+		}
+	}
+	return nodes
+}
+
+// startEvents returns the events the tree emits when it starts
+func (tree treeModel) startEvents(path string) []EventP {
+	evs := make([]EventP, 0)
+	for _, child := range tree.sortedChildren() {
+		childPath := strings.Join([]string{path, child.name}, "/")
+		if child.subtree != nil {
+			evs = append(evs, child.subtree.startEvents(childPath)...)
+			continue
+		}
+		evs = append(evs, WorkerStarted(childPath))
+	}
+	return append(evs, SupervisorStarted(path))
+}
+
+// terminateEvents returns the events the tree emits when it terminates
+func (tree treeModel) terminateEvents(path string) []EventP {
+	evs := make([]EventP, 0)
+	children := tree.sortedChildren()
+	for i := len(children) - 1; i >= 0; i-- {
+		child := children[i]
+		childPath := strings.Join([]string{path, child.name}, "/")
+		if child.subtree != nil {
+			evs = append(evs, child.subtree.terminateEvents(childPath)...)
+			continue
+		}
+		evs = append(evs, WorkerTerminated(childPath))
+	}
+	return append(evs, SupervisorTerminated(path))
+}
+
+// normalize adapts the failure schedule to the given worker: temporary workers
+// only fail once (they are not restarted), and workers do not fail after they
+// surpassed their error tolerance.
+func (failure failureModel) normalize(worker workerRef) failureModel {
+	if worker.restart == cap.Temporary {
+		failure.errCount = 1
+	} else if failure.errCount > int32(failure.tolerance)+1 {
+		failure.errCount = int32(failure.tolerance) + 1
+	}
+	return failure
+}
+
+// escalates returns true if the failure schedule surpasses the error
+// tolerance of the given worker
+func (failure failureModel) escalates(worker workerRef) bool {
+	return worker.restart != cap.Temporary && failure.errCount > int32(failure.tolerance)
+}
+
+// escalation returns the supervisors that fail when the worker surpasses its
+// error tolerance, from the worker's parent up to the first supervisor that
+// gets restarted by its own parent (or the root supervisor).
+func (worker workerRef) escalation() []string {
+	failed := make([]string, 0, len(worker.ancestors))
+	for i, sup := range worker.ancestors {
+		failed = append(failed, sup.path)
+		if i == len(worker.ancestors)-1 || sup.tolerance > 0 {
+			break
+		}
+	}
+	return failed
+}
+
+// genTreeModel generates supervision trees with the given number of sub-tree
+// levels at most
+func genTreeModel(depth int) gopter.Gen {
+	return gen.IntRange(1, propMaxFanOut).FlatMap(func(v interface{}) gopter.Gen {
+		fanOut := v.(int)
+		gens := []gopter.Gen{
+			gen.OneConstOf(cap.LeftToRight, cap.RightToLeft),
+			gen.UInt32Range(0, 1),
+		}
+		for i := 0; i < fanOut; i++ {
+			gens = append(gens, genNodeModel(fmt.Sprintf("n%d", i), depth))
+		}
+		return gopter.CombineGens(gens...).Map(func(values []interface{}) treeModel {
+			tree := treeModel{
+				order:     values[0].(cap.Order),
+				tolerance: values[1].(uint32),
+				children:  make([]nodeModel, 0, fanOut),
+			}
+			for _, child := range values[2:] {
+				tree.children = append(tree.children, child.(nodeModel))
+			}
+			return tree
+		})
+	}, reflect.TypeOf(treeModel{}))
+}
+
+// genNodeModel generates workers, or sub-trees when the given depth allows it
+func genNodeModel(name string, depth int) gopter.Gen {
+	worker := gen.OneConstOf(cap.Permanent, cap.Transient, cap.Temporary).
+		Map(func(restart cap.Restart) nodeModel {
+			return nodeModel{name: name, restart: restart}
+		})
+	if depth == 0 {
+		return worker
+	}
+	subtree := genTreeModel(depth - 1).Map(func(tree treeModel) nodeModel {
+		return nodeModel{name: name, subtree: &tree}
+	})
+	return gen.OneGenOf(worker, subtree)
+}
+
+// genFailureModel generates failure schedules
+func genFailureModel() gopter.Gen {
+	return gopter.CombineGens(
+		gen.IntRange(0, 100),
+		gen.UInt32Range(0, 2),
+		gen.Int32Range(1, 3),
+	).Map(func(values []interface{}) failureModel {
+		return failureModel{
+			workerIx:  values[0].(int),
+			tolerance: values[1].(uint32),
+			errCount:  values[2].(int32),
+		}
+	})
+}
+
+// propertyParameters returns the parameters of the property tests
+func propertyParameters() *gopter.TestParameters {
+	params := gopter.DefaultTestParameters()
+	params.MinSuccessfulTests = 50
+	if testing.Short() {
+		params.MinSuccessfulTests = 10
+	}
+	return params
+}
+
+// startTreeModel starts a supervision tree from the given tree model; the
+// events are collected until the given context is done
+func startTreeModel(
+	ctx context.Context,
+	tree treeModel,
+	failingPath string,
+	failingNode cap.Node,
+) (cap.Supervisor, EventManager, error) {
+	evManager := NewEventManager()
+	evManager.StartCollector(ctx)
+
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(tree.buildNodes("root", failingPath, failingNode)...),
+		cap.WithStartOrder(tree.order),
+		cap.WithNotifier(evManager.EventCollector(ctx)),
+	).Start(context.TODO())
+
+	return sup, evManager, err
+}
+
+func TestPropertyStartAndTerminationOrder(t *testing.T) {
+	properties := gopter.NewProperties(propertyParameters())
+
+	properties.Property(
+		"trees start in order, terminate in reverse order and leave no nodes running",
+		prop.ForAll(
+			func(tree treeModel) string {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				sup, evManager, err := startTreeModel(ctx, tree, "", nil)
+				if err != nil {
+					return fmt.Sprintf("start failed: %v", err)
+				}
+
+				terminateErr := sup.Terminate()
+				evIt := evManager.Iterator()
+				evIt.SkipTill(SupervisorTerminated("root"))
+
+				if terminateErr != nil {
+					return fmt.Sprintf("termination failed: %v", terminateErr)
+				}
+				if running := sup.GetRunningNodes(); len(running) > 0 {
+					return fmt.Sprintf("nodes running after termination: %v", running)
+				}
+
+				preds := append(tree.startEvents("root"), tree.terminateEvents("root")...)
+				if matchErr := VerifyExactMatch(evManager.Snapshot(), preds); matchErr != nil {
+					return matchErr.Error()
+				}
+				return ""
+			},
+			genTreeModel(propMaxDepth),
+		),
+	)
+
+	properties.TestingRun(t)
+}
+
+func TestPropertyToleranceEscalation(t *testing.T) {
+	properties := gopter.NewProperties(propertyParameters())
+
+	properties.Property(
+		"failures escalate up to the correct ancestor and leave no nodes running",
+		prop.ForAll(
+			func(tree treeModel, failure0 failureModel) string {
+				workers := tree.workers("root", []supervisorRef{})
+				worker := workers[failure0.workerIx%len(workers)]
+				failure := failure0.normalize(worker)
+
+				failingNode, failWorker := FailOnSignalWorker(
+					failure.errCount,
+					worker.name,
+					cap.WithRestart(worker.restart),
+					cap.WithTolerance(failure.tolerance, propErrWindow),
+				)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				sup, evManager, err := startTreeModel(ctx, tree, worker.path, failingNode)
+				if err != nil {
+					return fmt.Sprintf("start failed: %v", err)
+				}
+
+				evIt := evManager.Iterator()
+				evIt.SkipTill(SupervisorStarted("root"))
+
+				for i := int32(0); i < failure.errCount; i++ {
+					failWorker(false /* done */)
+					evIt.SkipTill(WorkerFailed(worker.path))
+				}
+
+				failed := []string{}
+				if failure.escalates(worker) {
+					failed = worker.escalation()
+				}
+
+				preds := make([]EventP, 0)
+				for i := int32(0); i < failure.errCount; i++ {
+					preds = append(preds, WorkerFailed(worker.path))
+				}
+				for _, supPath := range failed {
+					preds = append(preds, SupervisorFailed(supPath))
+				}
+
+				rootFailed := len(failed) > 0 && failed[len(failed)-1] == "root"
+
+				// we wait for the supervision tree to recover from the failures
+				switch {
+				case len(failed) > 0 && !rootFailed:
+					top := failed[len(failed)-1]
+					evIt.SkipTill(SupervisorFailed(top))
+					evIt.SkipTill(SupervisorStarted(top))
+					preds = append(preds, SupervisorStarted(top))
+				case len(failed) == 0 && worker.restart != cap.Temporary:
+					evIt.SkipTill(WorkerStarted(worker.path))
+				case rootFailed:
+					evIt.SkipTill(SupervisorFailed("root"))
+				}
+				failWorker(true /* done */)
+
+				terminateErr := sup.Terminate()
+				if !rootFailed {
+					evIt.SkipTill(SupervisorTerminated("root"))
+				}
+
+				if rootFailed {
+					if terminateErr == nil {
+						return "expected root supervisor to fail"
+					}
+				} else {
+					if terminateErr != nil {
+						return fmt.Sprintf("termination failed: %v", terminateErr)
+					}
+					preds = append(preds, SupervisorTerminated("root"))
+				}
+
+				if running := sup.GetRunningNodes(); len(running) > 0 {
+					return fmt.Sprintf("nodes running after termination: %v", running)
+				}
+
+				events := evManager.Snapshot()
+				if matchErr := VerifyPartialMatch(events, preds); matchErr != nil {
+					return matchErr.Error()
+				}
+
+				// only the supervisors on the escalation path fail
+				supFailedCount := 0
+				for _, ev := range events {
+					if ev.GetTag() == cap.ProcessFailed && ev.GetNodeTag() == cap.SupervisorT {
+						supFailedCount++
+					}
+				}
+				if supFailedCount != len(failed) {
+					return fmt.Sprintf(
+						"expected %d supervisor failures (%v), got %d",
+						len(failed),
+						failed,
+						supFailedCount,
+					)
+				}
+				return ""
+			},
+			genTreeModel(propMaxDepth),
+			genFailureModel(),
+		),
+	)
+
+	properties.TestingRun(t)
+}
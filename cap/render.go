@@ -0,0 +1,162 @@
+package cap
+
+// This file contains the rendering of the NodeInfo of a supervision tree as an
+// ASCII tree and as a Graphviz DOT graph.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// settings returns the human readable settings of the node
+func (ni NodeInfo) settings() []string {
+	settings := []string{}
+	if !ni.root {
+		maxErrCount, errWindow := ni.GetTolerance()
+		settings = append(
+			settings,
+			ni.restart.String(),
+			fmt.Sprintf("shutdown: %s", ni.shutdown),
+			fmt.Sprintf("tolerance: %d in %v", maxErrCount, errWindow),
+		)
+	} else {
+		settings = append(settings, fmt.Sprintf("shutdown: %s", ni.shutdown))
+	}
+	if ni.tag == SupervisorT {
+		settings = append(settings, fmt.Sprintf("start order: %s", ni.order))
+	}
+	if len(ni.dependsOn) > 0 {
+		settings = append(settings, fmt.Sprintf("depends on: %s", strings.Join(ni.dependsOn, ", ")))
+	}
+	return settings
+}
+
+// asciiLabel returns the text of the node on an ASCII tree
+func (ni NodeInfo) asciiLabel() string {
+	label := fmt.Sprintf("%s (%s)", ni.name, strings.Join(ni.settings(), ", "))
+	if ni.status != UnknownStatus {
+		label = fmt.Sprintf("%s [%s]", label, ni.status)
+	}
+	return label
+}
+
+// renderASCII writes the children of the node on the given builder, every line
+// starts with the given prefix
+func (ni NodeInfo) renderASCII(builder *strings.Builder, prefix string) {
+	for i, child := range ni.children {
+		last := i == len(ni.children)-1
+		marker, childPrefix := "`", prefix+"| "
+		if child.tag == SupervisorT {
+			marker = "+"
+		}
+		if last {
+			childPrefix = prefix + "  "
+		}
+		builder.WriteString(prefix + "|\n")
+		builder.WriteString(fmt.Sprintf("%s%s %s\n", prefix, marker, child.asciiLabel()))
+		child.renderASCII(builder, childPrefix)
+	}
+}
+
+// RenderASCII returns an ASCII tree of the node and its descendants, with the
+// settings of every node (and its NodeStatus, when the node was described from
+// a running Supervisor).
+//
+// Example:
+//
+//   + root (shutdown: Indefinitely, start order: LeftToRight)
+//   |
+//   + api (Permanent, shutdown: Indefinitely, tolerance: 1 in 5s, start order: LeftToRight)
+//   | |
+//   | ` listen-and-serve (Permanent, shutdown: Timeout(5s), tolerance: 1 in 5s)
+//   |
+//   ` cache (Transient, shutdown: Timeout(5s), tolerance: 1 in 5s)
+//
+func (ni NodeInfo) RenderASCII() string {
+	var builder strings.Builder
+	marker := "`"
+	if ni.tag == SupervisorT {
+		marker = "+"
+	}
+	builder.WriteString(fmt.Sprintf("%s %s\n", marker, ni.asciiLabel()))
+	ni.renderASCII(&builder, "")
+
+	// the prefix of the last children leaves trailing spaces
+	lines := strings.Split(builder.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dotColors contains the colors of the nodes on a DOT graph by NodeStatus
+var dotColors = map[NodeStatus]string{
+	UnknownStatus: "black",
+	RunningStatus: "darkgreen",
+	StoppedStatus: "gray",
+	LeakedStatus:  "red",
+}
+
+// renderDOT writes the node, its descendants and their edges on the given
+// builder
+func (ni NodeInfo) renderDOT(builder *strings.Builder) {
+	lines := append([]string{ni.name}, ni.settings()...)
+	if ni.status != UnknownStatus {
+		lines = append(lines, fmt.Sprintf("status: %s", ni.status))
+	}
+	shape := "box"
+	if ni.tag == SupervisorT {
+		shape = "folder"
+	}
+	builder.WriteString(
+		fmt.Sprintf(
+			"  %s [shape=%s, color=%s, label=%s];\n",
+			strconv.Quote(ni.runtimeName),
+			shape,
+			dotColors[ni.status],
+			strconv.Quote(strings.Join(lines, "\n")),
+		),
+	)
+
+	for _, child := range ni.children {
+		child.renderDOT(builder)
+		builder.WriteString(
+			fmt.Sprintf("  %s -> %s;\n", strconv.Quote(ni.runtimeName), strconv.Quote(child.runtimeName)),
+		)
+	}
+
+	// dependencies are rendered as dashed edges between siblings
+	for _, child := range ni.children {
+		for _, dep := range child.dependsOn {
+			depRuntimeName := fmt.Sprintf("%s%s%s", ni.runtimeName, nodeSepToken, dep)
+			builder.WriteString(
+				fmt.Sprintf(
+					"  %s -> %s [style=dashed, label=\"depends on\"];\n",
+					strconv.Quote(child.runtimeName),
+					strconv.Quote(depRuntimeName),
+				),
+			)
+		}
+	}
+}
+
+// RenderDOT returns a Graphviz DOT graph of the node and its descendants, with
+// the settings of every node. Supervisors are rendered as folders, workers as
+// boxes, and the dependencies between siblings (check WithDependsOn) as dashed
+// edges. When the node was described from a running Supervisor, the color of
+// every node reflects its NodeStatus.
+//
+// Example:
+//
+//   info, _ := spec.Describe()
+//   ioutil.WriteFile("tree.dot", []byte(info.RenderDOT()), 0644)
+//   // dot -Tsvg tree.dot > tree.svg
+//
+func (ni NodeInfo) RenderDOT() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("digraph %s {\n", strconv.Quote(ni.runtimeName)))
+	ni.renderDOT(&builder)
+	builder.WriteString("}\n")
+	return builder.String()
+}
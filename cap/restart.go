@@ -0,0 +1,311 @@
+package cap
+
+// This file contains the logic that allows the monitor loop to restart
+// children nodes in the background; this way, the supervisor keeps handling
+// notifications of other children, control messages and termination requests
+// while a child is starting.
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/capatazlib/go-capataz/internal/c"
+)
+
+// nodeCtrlMsg is a ctrlMsg that operates over a single child node; these
+// messages are deferred while the child node is restarting.
+type nodeCtrlMsg interface {
+	ctrlMsg
+	// getNodeName returns the name of the child node the message operates over
+	getNodeName() string
+}
+
+// restartResult is the outcome of a restart that happened in the background
+type restartResult struct {
+	// names contains the name of the restarted child and the names of its
+	// dependents
+	names []string
+	// restarted contains the children that are running after the restart
+	restarted map[string]c.Child
+	// restartErr is set when the error tolerance of a child was reached
+	restartErr *c.ErrorToleranceReached
+	// stopped contains the children of a manual restart that could not be
+	// started again, in start order
+	stopped []c.Child
+}
+
+// restartTracker keeps track of the children restarts that are happening in
+// the background, as well as the notifications and control messages that got
+// received for those children while they were restarting.
+//
+// This record must only be used from the monitor loop goroutine.
+type restartTracker struct {
+	// startCtx is given to the starts of the restarted children, it is
+	// cancelled once the supervisor waits for the restarts before its
+	// termination
+	startCtx      context.Context
+	cancelStarts  func()
+	resultCh      chan restartResult
+	restarting    map[string]bool
+	pendingCount  int
+	pendingNotifs []c.ChildNotification
+	pendingMsgs   []nodeCtrlMsg
+	// stopped contains the children that stay stopped after a failed manual
+	// restart, indexed by the name of the first one of them
+	stopped map[string][]c.Child
+}
+
+// newRestartTracker creates a new restartTracker, the starts of the restarted
+// children get cancelled when the given supervisor context is done
+func newRestartTracker(ctx context.Context) *restartTracker {
+	startCtx, cancelStarts := context.WithCancel(ctx)
+	return &restartTracker{
+		startCtx:      startCtx,
+		cancelStarts:  cancelStarts,
+		resultCh:      make(chan restartResult),
+		restarting:    make(map[string]bool),
+		pendingNotifs: make([]c.ChildNotification, 0),
+		pendingMsgs:   make([]nodeCtrlMsg, 0),
+		stopped:       make(map[string][]c.Child),
+	}
+}
+
+// isRestarting returns true if the child with the given name is restarting
+func (rt *restartTracker) isRestarting(chName string) bool {
+	return rt.restarting[chName]
+}
+
+// deferNotification stores a notification of a restarting child, it gets
+// handled once the restart finishes.
+//
+// A restarted child may finish before the monitor loop gets the result of its
+// restart (e.g. it fails right after it started).
+func (rt *restartTracker) deferNotification(chNotification c.ChildNotification) {
+	rt.pendingNotifs = append(rt.pendingNotifs, chNotification)
+}
+
+// deferCtrlMsg stores a control message for a restarting child, it gets handled
+// once the restart finishes.
+func (rt *restartTracker) deferCtrlMsg(msg nodeCtrlMsg) {
+	rt.pendingMsgs = append(rt.pendingMsgs, msg)
+}
+
+// restart stops the children that depend on the given child, and then restarts
+// the given child and its dependents in the background. The given child and
+// its dependents are removed from the children map until the result of the
+// restart is received on the resultCh.
+func (rt *restartTracker) restart(
+	spec SupervisorSpec,
+	supChildrenSpecs []c.ChildSpec,
+	supRuntimeName string,
+	supChildren map[string]c.Child,
+	supNotifyCh chan c.ChildNotification,
+	prevCh c.Child,
+	chNotification c.ChildNotification,
+) {
+	eventNotifier := spec.getEventNotifier()
+
+	// the nodes that depend on a node that is about to restart are stopped
+	// before the restart, and started again after it
+	dependents := stopDependentNodes(
+		spec,
+		supChildrenSpecs,
+		supChildren,
+		prevCh.GetName(),
+	)
+	delete(supChildren, prevCh.GetName())
+
+	names := []string{prevCh.GetName()}
+	for _, ch := range dependents {
+		names = append(names, ch.GetName())
+	}
+	for _, name := range names {
+		rt.restarting[name] = true
+	}
+	rt.pendingCount++
+
+	go func() {
+		// the restart functions register the running children on this map
+		// rather than on the one of the monitor loop
+		restarted := make(map[string]c.Child)
+
+		restartErr := handleChildNodeNotification(
+			rt.startCtx,
+			eventNotifier,
+			supRuntimeName,
+			restarted,
+			supNotifyCh,
+			prevCh,
+			chNotification,
+		)
+
+		if restartErr == nil && len(dependents) > 0 {
+			restartErr = startDependentNodes(
+				rt.startCtx,
+				spec,
+				supRuntimeName,
+				restarted,
+				supNotifyCh,
+				dependents,
+			)
+		}
+
+		rt.resultCh <- restartResult{
+			names:      names,
+			restarted:  restarted,
+			restartErr: restartErr,
+		}
+	}()
+}
+
+// restartNode terminates the child of the given restartNodeMsg and the children
+// that depend on it, and then starts them again (unless the message requests a
+// termination only); both steps happen in the background. Unlike the restart
+// of a failed child, a manual restart never counts against the error tolerance
+// of the supervisor: when a child does not terminate or start, the error is
+// reported to the client, and the child (with its dependents) stays stopped
+// until the next manual restart of the child.
+func (rt *restartTracker) restartNode(
+	spec SupervisorSpec,
+	supChildrenSpecs []c.ChildSpec,
+	supRuntimeName string,
+	supChildren map[string]c.Child,
+	supNotifyCh chan c.ChildNotification,
+	msg restartNodeMsg,
+) {
+	// toStop contains the running children in start order, toStart contains
+	// the children that are stopped already
+	var toStop, toStart []c.Child
+
+	if prevCh, ok := supChildren[msg.nodeName]; ok {
+		toStop = []c.Child{prevCh}
+		for _, depSpec := range spec.getDependentNodes(supChildrenSpecs, msg.nodeName) {
+			if ch, ok := supChildren[depSpec.GetName()]; ok {
+				toStop = append(toStop, ch)
+			}
+		}
+	} else if stopped, ok := rt.stopped[msg.nodeName]; ok && !msg.terminate {
+		delete(rt.stopped, msg.nodeName)
+		toStart = stopped
+	} else {
+		msg.reply(&UnknownNodeError{
+			supRuntimeName: supRuntimeName,
+			nodeName:       msg.nodeName,
+		})
+		return
+	}
+
+	names := make([]string, 0, len(toStop)+len(toStart))
+	for _, ch := range append(toStop, toStart...) {
+		names = append(names, ch.GetName())
+		delete(supChildren, ch.GetName())
+		rt.restarting[ch.GetName()] = true
+	}
+	rt.pendingCount++
+
+	go func() {
+		eventNotifier := spec.getEventNotifier()
+
+		// dependents are stopped before the node they depend on
+		var terminateErr error
+		for i := len(toStop) - 1; i >= 0; i-- {
+			ch := toStop[i]
+			// errors are also reported on the event system by terminateChildNode
+			if err := terminateChildNode(eventNotifier, ch, c.RestartReason); err != nil && terminateErr == nil {
+				terminateErr = fmt.Errorf("node %s did not terminate: %w", ch.GetRuntimeName(), err)
+			}
+		}
+
+		// the restart functions register the running children on this map
+		// rather than on the one of the monitor loop
+		restarted := make(map[string]c.Child)
+		stopped := append(toStop, toStart...)
+		var restartErr error
+
+		if terminateErr != nil || msg.terminate {
+			// a node that did not terminate may still be running, we do not start
+			// a second copy of it
+			restartErr = terminateErr
+		} else {
+			stopped, restartErr = startStoppedNodes(
+				rt.startCtx,
+				eventNotifier,
+				supRuntimeName,
+				restarted,
+				supNotifyCh,
+				stopped,
+			)
+		}
+		msg.reply(restartErr)
+
+		rt.resultCh <- restartResult{
+			names:     names,
+			restarted: restarted,
+			stopped:   stopped,
+		}
+	}()
+}
+
+// finish registers the children of the given restart result on the children
+// map. It returns the notifications and control messages that were deferred
+// while these children were restarting.
+func (rt *restartTracker) finish(
+	result restartResult,
+	supChildren map[string]c.Child,
+) ([]c.ChildNotification, []nodeCtrlMsg) {
+	rt.pendingCount--
+	for _, name := range result.names {
+		delete(rt.restarting, name)
+	}
+	for name, ch := range result.restarted {
+		supChildren[name] = ch
+	}
+	if len(result.stopped) > 0 {
+		rt.stopped[result.stopped[0].GetName()] = result.stopped
+	}
+
+	notifs := make([]c.ChildNotification, 0)
+	pendingNotifs := rt.pendingNotifs[:0:0]
+	for _, chNotification := range rt.pendingNotifs {
+		if rt.isRestarting(chNotification.GetName()) {
+			pendingNotifs = append(pendingNotifs, chNotification)
+			continue
+		}
+		notifs = append(notifs, chNotification)
+	}
+	rt.pendingNotifs = pendingNotifs
+
+	msgs := make([]nodeCtrlMsg, 0)
+	pendingMsgs := rt.pendingMsgs[:0:0]
+	for _, msg := range rt.pendingMsgs {
+		if rt.isRestarting(msg.getNodeName()) {
+			pendingMsgs = append(pendingMsgs, msg)
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+	rt.pendingMsgs = pendingMsgs
+
+	return notifs, msgs
+}
+
+// wait cancels the starts of the restarting children, and blocks until all the
+// restarts that are happening in the background finish; the restarted children
+// are registered on the children map. This function is used before the
+// supervisor terminates, so that restarted children get terminated as well.
+//
+// It returns the control messages that were deferred while the children were
+// restarting; deferred notifications are discarded given the children that
+// sent them are done already.
+func (rt *restartTracker) wait(supChildren map[string]c.Child) []nodeCtrlMsg {
+	// a child that hangs on its start must not block the termination
+	rt.cancelStarts()
+
+	msgs := make([]nodeCtrlMsg, 0)
+	for rt.pendingCount > 0 {
+		_, resultMsgs := rt.finish(<-rt.resultCh, supChildren)
+		msgs = append(msgs, resultMsgs...)
+	}
+	rt.pendingNotifs = rt.pendingNotifs[:0]
+	return msgs
+}
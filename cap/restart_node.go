@@ -0,0 +1,134 @@
+package cap
+
+// This file contains the manual restart and termination of the nodes of a
+// running supervision tree
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/capatazlib/go-capataz/internal/c"
+)
+
+// restartNodeMsg is a message sent from clients to tell a supervisor to restart
+// (or terminate) one of its children nodes. It is handled by the restartTracker
+// of the monitor loop.
+type restartNodeMsg struct {
+	nodeName string
+	// terminate is set when the node must not be started again
+	terminate  bool
+	resultChan chan<- error
+}
+
+// getNodeName returns the name of the node to restart
+func (rnm restartNodeMsg) getNodeName() string {
+	return rnm.nodeName
+}
+
+// reply sends the result of the restart to the client, without blocking
+func (rnm restartNodeMsg) reply(err error) {
+	select {
+	case rnm.resultChan <- err:
+	default:
+	}
+}
+
+// processMsg is only called for messages that were deferred while the
+// supervisor was terminating; the node is not restarted in that case.
+func (rnm restartNodeMsg) processMsg(
+	evNotifier EventNotifier,
+	spec SupervisorSpec,
+	specChildren []c.ChildSpec,
+	supRuntimeName string,
+	supChildren map[string]c.Child,
+	supNotifyCh chan c.ChildNotification,
+) ([]c.ChildSpec, map[string]c.Child) {
+	// REMEMBER: WE ARE RUNNING THIS CODE IN THE SUPERVISOR THREAD
+	rnm.reply(fmt.Errorf("supervisor %s is terminating", supRuntimeName))
+	return specChildren, supChildren
+}
+
+var _ nodeCtrlMsg = restartNodeMsg{}
+
+// RestartNode terminates the node with the given runtime name (e.g.
+// "root/api/listener") and starts it again using its spec; the nodes that
+// depend on it (check WithDependsOn) get restarted as well. The node may be
+// a child of any supervisor of the tree, sub-trees included.
+//
+// The restart emits the regular termination and start events of the nodes, and
+// it does not count against the error tolerance of the supervisor; the
+// failures of the nodes that happened before the restart are still taken into
+// account. This function blocks until the node started again, or until the
+// supervisor terminates.
+//
+// When the node (or one of its dependents) does not terminate or start again,
+// the error is returned and the supervisor keeps running; the nodes that could
+// not be started stay stopped until the next RestartNode call of the node.
+//
+// An UnknownNodeError is returned when there is no running node with the given
+// runtime name (e.g. a sub-tree of the path is restarting).
+//
+func (sup Supervisor) RestartNode(runtimeName string) error {
+	return sup.sendNodeMsg(runtimeName, false /* terminate */)
+}
+
+// TerminateNode terminates the node with the given runtime name (e.g.
+// "root/api/listener") and the nodes that depend on it (check WithDependsOn),
+// without starting them again; the nodes stay stopped until a RestartNode call
+// of the node. The node may be a child of any supervisor of the tree, sub-trees
+// included.
+//
+// The termination emits the regular termination events of the nodes, and it
+// does not count against the error tolerance of the supervisor. This function
+// blocks until the nodes terminated.
+//
+// An UnknownNodeError is returned when there is no running node with the given
+// runtime name.
+//
+func (sup Supervisor) TerminateNode(runtimeName string) error {
+	return sup.sendNodeMsg(runtimeName, true /* terminate */)
+}
+
+// sendNodeMsg sends a restartNodeMsg to the supervisor of the node with the
+// given runtime name, and waits for its result
+func (sup Supervisor) sendNodeMsg(runtimeName string, terminate bool) error {
+	// REMEMBER: WE ARE RUNNING ON THE CLIENT API THREAD
+
+	sepIx := strings.LastIndex(runtimeName, nodeSepToken)
+	if sepIx < 0 || sup.spec.nodeRegistry == nil {
+		return &UnknownNodeError{nodeName: runtimeName}
+	}
+	supRuntimeName, nodeName := runtimeName[:sepIx], runtimeName[sepIx+1:]
+
+	mailbox, ok := sup.spec.nodeRegistry.getMailbox(supRuntimeName)
+	if !ok {
+		return &UnknownNodeError{supRuntimeName: supRuntimeName, nodeName: nodeName}
+	}
+
+	// we initialize the resultCh with a buffer of 1, we may store the result
+	// before the client is ready to read it.
+	resultCh := make(chan error, 1)
+	msg := restartNodeMsg{
+		nodeName:   nodeName,
+		terminate:  terminate,
+		resultChan: resultCh,
+	}
+
+	if !mailbox.send(msg) {
+		return errors.New("could not talk to supervisor")
+	}
+
+	select {
+	case err := <-resultCh:
+		return err
+	case <-mailbox.doneCh:
+		// the supervisor may reply right before it finishes
+		select {
+		case err := <-resultCh:
+			return err
+		default:
+			return fmt.Errorf("supervisor %s terminated before handling %s", supRuntimeName, nodeName)
+		}
+	}
+}
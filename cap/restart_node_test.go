@@ -0,0 +1,364 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// withStartedSupervisor returns an Opt that stores the started root supervisor
+// on the given pointer
+func withStartedSupervisor(sup *cap.Supervisor) cap.Opt {
+	return cap.WithStartHook(func(started cap.Supervisor) (func(), error) {
+		*sup = started
+		return func() {}, nil
+	})
+}
+
+func TestRestartNode(t *testing.T) {
+	var sup cap.Supervisor
+
+	b0 := cap.NewSupervisorSpec(
+		"branch0",
+		cap.WithNodes(WaitDoneWorker("child2")),
+	)
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			WaitDoneWorker("child0"),
+			WaitDoneWorker("child1", cap.WithDependsOn("child0")),
+			cap.Subtree(b0),
+		),
+		[]cap.Opt{withStartedSupervisor(&sup)},
+		func(EventManager) {
+			assert.NoError(t, sup.RestartNode("root/child0"))
+			assert.NoError(t, sup.RestartNode("root/branch0/child2"))
+		},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerStarted("root/child1"),
+			WorkerStarted("root/branch0/child2"),
+			SupervisorStarted("root/branch0"),
+			SupervisorStarted("root"),
+			// dependents are restarted as well
+			WorkerTerminated("root/child1"),
+			WorkerTerminated("root/child0"),
+			WorkerStarted("root/child0"),
+			WorkerStarted("root/child1"),
+			// nodes of sub-trees are restarted by their own supervisor
+			WorkerTerminated("root/branch0/child2"),
+			WorkerStarted("root/branch0/child2"),
+			// regular termination
+			WorkerTerminated("root/branch0/child2"),
+			SupervisorTerminated("root/branch0"),
+			WorkerTerminated("root/child1"),
+			WorkerTerminated("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestRestartNodeErrorTolerance(t *testing.T) {
+	var sup cap.Supervisor
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			// a single failure would terminate the supervisor
+			WaitDoneWorker("child0", cap.WithTolerance(0, time.Minute)),
+		),
+		[]cap.Opt{withStartedSupervisor(&sup)},
+		func(EventManager) {
+			for i := 0; i < 3; i++ {
+				assert.NoError(t, sup.RestartNode("root/child0"))
+			}
+		},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child0"),
+			WorkerStarted("root/child0"),
+			WorkerTerminated("root/child0"),
+			WorkerStarted("root/child0"),
+			WorkerTerminated("root/child0"),
+			WorkerStarted("root/child0"),
+			WorkerTerminated("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestRestartNodeStartFailure(t *testing.T) {
+	var sup cap.Supervisor
+	var starts int32
+
+	// the second start of child0 fails
+	child0 := cap.NewWorkerWithNotifyStart(
+		"child0",
+		func(ctx context.Context, notifyStart cap.NotifyStartFn) error {
+			if atomic.AddInt32(&starts, 1) == 2 {
+				err := errors.New("start failure")
+				notifyStart(err)
+				return err
+			}
+			notifyStart(nil)
+			<-ctx.Done()
+			return nil
+		},
+	)
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			child0,
+			WaitDoneWorker("child1", cap.WithDependsOn("child0")),
+		),
+		[]cap.Opt{withStartedSupervisor(&sup)},
+		func(EventManager) {
+			assert.EqualError(t, sup.RestartNode("root/child0"), "start failure")
+			// the dependent is not running, it stays stopped with child0
+			var nodeErr *cap.UnknownNodeError
+			assert.True(t, errors.As(sup.RestartNode("root/child1"), &nodeErr))
+			// a new restart starts the stopped nodes again
+			assert.NoError(t, sup.RestartNode("root/child0"))
+		},
+	)
+
+	// the supervisor survives the start failure
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerStarted("root/child1"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child1"),
+			WorkerTerminated("root/child0"),
+			WorkerStartFailed("root/child0"),
+			WorkerStarted("root/child0"),
+			WorkerStarted("root/child1"),
+			WorkerTerminated("root/child1"),
+			WorkerTerminated("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestRestartNodeKeepsErrorCount(t *testing.T) {
+	var sup cap.Supervisor
+
+	failCh := make(chan struct{})
+	child0 := cap.NewWorker(
+		"child0",
+		func(ctx context.Context) error {
+			select {
+			case <-failCh:
+				return errors.New("child0 failed")
+			case <-ctx.Done():
+				return nil
+			}
+		},
+		cap.WithTolerance(1, time.Minute),
+	)
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(child0),
+		[]cap.Opt{withStartedSupervisor(&sup)},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			evIt.SkipTill(SupervisorStarted("root"))
+
+			failCh <- struct{}{}
+			evIt.SkipTill(WorkerStarted("root/child0"))
+
+			// the manual restart does not forget the failure above
+			assert.NoError(t, sup.RestartNode("root/child0"))
+
+			failCh <- struct{}{}
+			evIt.SkipTill(SupervisorFailed("root"))
+		},
+	)
+
+	// the second failure surpasses the error tolerance
+	assert.Error(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			SupervisorStarted("root"),
+			WorkerFailed("root/child0"),
+			WorkerStarted("root/child0"),
+			WorkerTerminated("root/child0"),
+			WorkerStarted("root/child0"),
+			WorkerFailed("root/child0"),
+			SupervisorFailed("root"),
+		},
+	)
+}
+
+func TestRestartNodeTerminationWhileStartHangs(t *testing.T) {
+	slow, _, releaseSlow := slowRestartWorker(
+		"slow",
+		cap.WithShutdown(cap.Timeout(10*time.Millisecond)),
+	)
+	defer releaseSlow()
+
+	evManager := NewEventManager()
+	evManager.StartCollector(context.TODO())
+
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(slow),
+		cap.WithNotifier(evManager.EventCollector(context.TODO())),
+	).Start(context.TODO())
+	assert.NoError(t, err)
+
+	evIt := evManager.Iterator()
+	evIt.SkipTill(SupervisorStarted("root"))
+
+	restartCh := make(chan error)
+	go func() {
+		restartCh <- sup.RestartNode("root/slow")
+	}()
+	// the start of the restarted node never finishes
+	evIt.SkipTill(WorkerTerminated("root/slow"))
+
+	terminateCh := make(chan error)
+	go func() {
+		terminateCh <- sup.Terminate()
+	}()
+
+	select {
+	case err := <-terminateCh:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not terminate")
+	}
+
+	// the caller of the restart gets the error of the cancelled start
+	var timeoutErr *cap.ShutdownTimeoutError
+	assert.True(t, errors.As(<-restartCh, &timeoutErr))
+
+	releaseSlow()
+	evIt.SkipTill(WorkerLeakFinished("root/slow"))
+
+	AssertExactMatch(t, evManager.Snapshot(),
+		[]EventP{
+			WorkerStarted("root/slow"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/slow"),
+			// the cancelled start did not stop in time
+			WorkerFailed("root/slow"),
+			SupervisorTerminated("root"),
+			WorkerLeakFinished("root/slow"),
+		},
+	)
+}
+
+func TestTerminateNode(t *testing.T) {
+	var sup cap.Supervisor
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			WaitDoneWorker("child0"),
+			WaitDoneWorker("child1", cap.WithDependsOn("child0")),
+			WaitDoneWorker("child2"),
+		),
+		[]cap.Opt{withStartedSupervisor(&sup)},
+		func(EventManager) {
+			assert.NoError(t, sup.TerminateNode("root/child0"))
+			// terminated nodes are not running anymore
+			var nodeErr *cap.UnknownNodeError
+			assert.True(t, errors.As(sup.TerminateNode("root/child0"), &nodeErr))
+			// a restart starts the terminated nodes again
+			assert.NoError(t, sup.RestartNode("root/child0"))
+		},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerStarted("root/child1"),
+			WorkerStarted("root/child2"),
+			SupervisorStarted("root"),
+			// dependents are terminated as well
+			WorkerTerminated("root/child1"),
+			WorkerTerminated("root/child0"),
+			WorkerStarted("root/child0"),
+			WorkerStarted("root/child1"),
+			// regular termination
+			WorkerTerminated("root/child2"),
+			WorkerTerminated("root/child1"),
+			WorkerTerminated("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestRestartNodeUnknownNode(t *testing.T) {
+	b0 := cap.NewSupervisorSpec(
+		"branch0",
+		cap.WithNodes(WaitDoneWorker("child1")),
+	)
+
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(WaitDoneWorker("child0"), cap.Subtree(b0)),
+	).Start(context.TODO())
+	assert.NoError(t, err)
+
+	for _, runtimeName := range []string{
+		"root",
+		"root/unknown",
+		"root/branch0/unknown",
+		"root/child0/unknown",
+		"other/child0",
+	} {
+		var nodeErr *cap.UnknownNodeError
+		err := sup.RestartNode(runtimeName)
+		if assert.True(t, errors.As(err, &nodeErr), runtimeName) {
+			assert.Equal(t, "unknown node "+runtimeName, err.Error())
+		}
+	}
+
+	var nodeErr *cap.UnknownNodeError
+	err = sup.RestartNode("root/branch0/unknown")
+	if assert.True(t, errors.As(err, &nodeErr)) {
+		assert.Equal(t, "root/branch0", nodeErr.GetRuntimeName())
+		assert.Equal(t, "unknown", nodeErr.GetNodeName())
+	}
+
+	assert.NoError(t, sup.Terminate())
+
+	// nodes of a terminated supervisor are unknown
+	err = sup.RestartNode("root/child0")
+	assert.True(t, errors.As(err, &nodeErr))
+}
@@ -0,0 +1,281 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// slowRestartWorker creates a worker that fails once the returned fail function
+// is called; after that, it does not finish its restart until the returned
+// release function is called (even if its start gets cancelled).
+func slowRestartWorker(name string, opts ...cap.WorkerOpt) (cap.Node, func(), func()) {
+	var startCount int32
+	failCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+
+	node := cap.NewWorkerWithNotifyStart(
+		name,
+		func(ctx context.Context, notifyStart cap.NotifyStartFn) error {
+			if atomic.AddInt32(&startCount, 1) > 1 {
+				<-releaseCh
+				notifyStart(nil)
+				<-ctx.Done()
+				return nil
+			}
+			notifyStart(nil)
+			select {
+			case <-failCh:
+				return errors.New("slow restart worker failed")
+			case <-ctx.Done():
+				return nil
+			}
+		},
+		opts...,
+	)
+
+	var failed, released int32
+	fail := func() {
+		if atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			close(failCh)
+		}
+	}
+	release := func() {
+		if atomic.CompareAndSwapInt32(&released, 0, 1) {
+			close(releaseCh)
+		}
+	}
+	return node, fail, release
+}
+
+func TestRestartDoesNotBlockMonitorLoop(t *testing.T) {
+	slow, failSlow, releaseSlow := slowRestartWorker("slow")
+	failing, failFailing := FailOnSignalWorker(1, "failing")
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(slow, failing),
+		[]cap.Opt{},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			evIt.SkipTill(SupervisorStarted("root"))
+
+			failSlow()
+			evIt.SkipTill(WorkerFailed("root/slow"))
+
+			// the supervisor handles the failing worker while the slow worker is
+			// still restarting
+			failFailing(true /* done */)
+			evIt.SkipTill(WorkerStarted("root/failing"))
+
+			releaseSlow()
+			evIt.SkipTill(WorkerStarted("root/slow"))
+		},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/slow"),
+			WorkerStarted("root/failing"),
+			SupervisorStarted("root"),
+			WorkerFailed("root/slow"),
+			WorkerFailed("root/failing"),
+			WorkerStarted("root/failing"),
+			WorkerStarted("root/slow"),
+			WorkerTerminated("root/failing"),
+			WorkerTerminated("root/slow"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestRestartTerminationWhileRestarting(t *testing.T) {
+	slow, failSlow, releaseSlow := slowRestartWorker("slow")
+
+	evManager := NewEventManager()
+	evManager.StartCollector(context.TODO())
+
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(slow, WaitDoneWorker("child1")),
+		cap.WithNotifier(evManager.EventCollector(context.TODO())),
+	).Start(context.TODO())
+	assert.NoError(t, err)
+
+	evIt := evManager.Iterator()
+	evIt.SkipTill(SupervisorStarted("root"))
+	failSlow()
+	evIt.SkipTill(WorkerFailed("root/slow"))
+
+	terminateCh := make(chan error)
+	go func() {
+		terminateCh <- sup.Terminate()
+	}()
+
+	// the termination cancels the restart, the supervisor waits for the
+	// cancelled start within the shutdown setting of the child
+	select {
+	case <-terminateCh:
+		t.Fatal("supervisor terminated while a child was restarting")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	releaseSlow()
+	assert.NoError(t, <-terminateCh)
+	evIt.SkipTill(SupervisorTerminated("root"))
+
+	AssertExactMatch(t, evManager.Snapshot(),
+		[]EventP{
+			WorkerStarted("root/slow"),
+			WorkerStarted("root/child1"),
+			SupervisorStarted("root"),
+			WorkerFailed("root/slow"),
+			WorkerStartFailed("root/slow"),
+			WorkerTerminated("root/child1"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestRestartTerminationWhileRestartHangs(t *testing.T) {
+	slow, failSlow, releaseSlow := slowRestartWorker(
+		"slow",
+		cap.WithShutdown(cap.Timeout(10*time.Millisecond)),
+	)
+	defer releaseSlow()
+
+	evManager := NewEventManager()
+	evManager.StartCollector(context.TODO())
+
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(slow, WaitDoneWorker("child1")),
+		cap.WithNotifier(evManager.EventCollector(context.TODO())),
+	).Start(context.TODO())
+	assert.NoError(t, err)
+
+	evIt := evManager.Iterator()
+	evIt.SkipTill(SupervisorStarted("root"))
+	failSlow()
+	evIt.SkipTill(WorkerFailed("root/slow"))
+
+	terminateCh := make(chan error)
+	go func() {
+		terminateCh <- sup.Terminate()
+	}()
+
+	// the restart never finishes, the termination gives up on it once the
+	// shutdown timeout of the child is reached
+	select {
+	case err := <-terminateCh:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not terminate")
+	}
+	evIt.SkipTill(SupervisorTerminated("root"))
+
+	// the hung child is tracked as a leaked node
+	leaked := sup.GetLeakedNodes()
+	if assert.Len(t, leaked, 1) {
+		assert.Equal(t, "root/slow", leaked[0].GetRuntimeName())
+	}
+
+	releaseSlow()
+	evIt.SkipTill(WorkerLeakFinished("root/slow"))
+
+	AssertExactMatch(t, evManager.Snapshot(),
+		[]EventP{
+			WorkerStarted("root/slow"),
+			WorkerStarted("root/child1"),
+			SupervisorStarted("root"),
+			WorkerFailed("root/slow"),
+			// the cancelled start did not stop in time
+			WorkerFailed("root/slow"),
+			WorkerTerminated("root/child1"),
+			SupervisorTerminated("root"),
+			WorkerLeakFinished("root/slow"),
+		},
+	)
+	assert.Empty(t, sup.GetLeakedNodes())
+}
+
+func TestRestartNotificationWhileRestarting(t *testing.T) {
+	var startCount int32
+
+	// the worker fails right after it restarts, the failure may be received
+	// before the result of the restart
+	flaky := cap.NewWorkerWithNotifyStart(
+		"flaky",
+		func(ctx context.Context, notifyStart cap.NotifyStartFn) error {
+			count := atomic.AddInt32(&startCount, 1)
+			notifyStart(nil)
+			if count < 3 {
+				return errors.New("flaky worker failed")
+			}
+			<-ctx.Done()
+			return nil
+		},
+		cap.WithTolerance(5, 10*time.Second),
+	)
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(flaky),
+		[]cap.Opt{},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			evIt.SkipTill(SupervisorStarted("root"))
+			evIt.SkipTill(WorkerStarted("root/flaky"))
+			evIt.SkipTill(WorkerStarted("root/flaky"))
+		},
+	)
+
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/flaky"),
+			SupervisorStarted("root"),
+			WorkerFailed("root/flaky"),
+			WorkerStarted("root/flaky"),
+			WorkerFailed("root/flaky"),
+			WorkerStarted("root/flaky"),
+			WorkerTerminated("root/flaky"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestRestartDynSpawnWhileRestarting(t *testing.T) {
+	slow, failSlow, releaseSlow := slowRestartWorker("slow")
+	defer releaseSlow()
+
+	dyn, err := cap.NewDynSupervisor(context.TODO(), "root")
+	assert.NoError(t, err)
+
+	_, err = dyn.Spawn(slow)
+	assert.NoError(t, err)
+
+	failSlow()
+	// give the supervisor time to start the restart
+	time.Sleep(10 * time.Millisecond)
+
+	// the supervisor is able to spawn workers while a child is restarting
+	_, err = dyn.Spawn(WaitDoneWorker("child1"))
+	assert.NoError(t, err)
+
+	releaseSlow()
+	assert.NoError(t, dyn.Terminate())
+}
@@ -10,7 +10,6 @@ package cap
 import (
 	"context"
 	"strings"
-	"time"
 
 	"github.com/capatazlib/go-capataz/internal/c"
 )
@@ -64,15 +63,29 @@ func (spec SupervisorSpec) rootStart(
 	// startCh is used to track when the supervisor loop thread has started
 	startCh := make(chan startError)
 
-	// terminateCh is used when waiting for cancelFn to complete
-	terminateCh := make(chan terminateError)
-
 	supRuntimeName := buildRuntimeName(spec, parentName)
 
+	// runningNodes keeps track of the nodes of the tree that have not finished,
+	// we hook it in the EventNotifier given sub-trees inherit it
+	rn := newRunningNodes()
+	spec.eventNotifier = rn.trackEvents(spec.getEventNotifier())
+
+	// leakedNodes keeps track of the nodes of the tree that did not stop in time
+	ln := newLeakedNodes(spec.getClock())
+	spec.eventNotifier = ln.trackEvents(spec.getEventNotifier())
+
 	eventNotifier := spec.getEventNotifier()
 
+	// nodeOverrideReport keeps track of the node overrides applied on the tree,
+	// sub-trees inherit it from their parent
+	spec.nodeOverrideReport = newNodeOverrideReport()
+
+	// nodeRegistry keeps track of the nodes of the tree, sub-trees inherit it
+	// from their parent
+	spec.nodeRegistry = newNodeRegistry()
+
 	// Build childrenSpec and resource cleanup
-	childrenSpecs, supRscCleanup, rscAllocError := spec.buildChildrenSpecs()
+	childrenSpecs, supRscCleanup, rscAllocError := spec.buildChildrenSpecs(supRuntimeName)
 
 	// Do not even start the monitor loop if we find an error on the resource
 	// allocation logic
@@ -89,41 +102,14 @@ func (spec SupervisorSpec) rootStart(
 		runtimeName: supRuntimeName,
 		ctrlCh:      ctrlCh,
 
-		terminateCh:      terminateCh,
 		terminateManager: tm,
+		runningNodes:     rn,
+		leakedNodes:      ln,
 
 		spec:     spec,
 		children: make(map[string]c.Child, len(childrenSpecs)),
 
 		cancel: cancelFn,
-		wait: func(stopingTime time.Time, startErr error) error {
-
-			// We check if there was an start error reported, if this is the case, we
-			// notify that the supervisor start failed
-			if startErr != nil {
-				eventNotifier.supervisorStartFailed(supRuntimeName, startErr)
-				return startErr
-			}
-
-			// Let us wait for the Supervisor goroutine to terminate, if there are
-			// errors in the termination (e.g. Timeout of child, error tolerance
-			// surpassed, etc.), the terminateCh is going to return an error,
-			// otherwise it will return nil
-			_, supErr := getCrashError(
-				true, /* block */
-				eventNotifier,
-				supRuntimeName,
-				terminateCh,
-				tm,
-				stopingTime,
-			)
-
-			if supErr != nil {
-				return supErr
-			}
-
-			return nil
-		},
 	}
 
 	onStart := func(err startError) {
@@ -133,19 +119,31 @@ func (spec SupervisorSpec) rootStart(
 		close(startCh)
 	}
 
+	// stops contains the stop functions of the start hooks, they are called
+	// before the termination gets registered
+	stops := &hookStops{}
+
 	onTerminate := func(err terminateError) {
-		if err != nil {
-			terminateCh <- err
-		}
 		close(ctrlCh)
-		close(terminateCh)
+		if hookErr := stops.stopAll(); hookErr != nil {
+			// the supervisor got terminated because a start hook failed, the
+			// termination is reported as a start failure (the start hook error
+			// is returned by Start)
+			eventNotifier.supervisorStartFailed(supRuntimeName, hookErr)
+			tm.setTerminationErr(err)
+			return
+		}
+		// If there are errors in the termination (e.g. Timeout of child, error
+		// tolerance surpassed, etc.), we register them as the final state of the
+		// supervisor
+		storeTerminationErr(eventNotifier, spec.getClock(), supRuntimeName, tm, err)
 	}
 
 	// spawn goroutine with supervisor monitorLoop
 	go func() {
 		// NOTE: we ignore the returned error as that is being handled by the
 		// onStart and onTerminate callbacks
-		startTime := time.Now()
+		startTime := spec.getClock().Now()
 		_ = runMonitorLoop(
 			ctx,
 			spec,
@@ -160,21 +158,23 @@ func (spec SupervisorSpec) rootStart(
 		)
 	}()
 
-	// TODO: Figure out stop before start finish
 	// TODO: Figure out start with timeout
 
 	// We check if there was an start error reported from the monitorLoop, if this
-	// is the case, we wait for the termination of started children and return the
-	// reported error
+	// is the case, the started children were already terminated, we notify that
+	// the supervisor start failed and return the reported error.
+	//
+	// When the parent context is done before the start finishes, the monitor
+	// loop aborts the start and reports a StartCancelledError.
 	startErr := <-startCh
 	if startErr != nil {
-		// Let's wait for the supervisor to stop all children before returning the
-		// final error
-		stopingTime := time.Now()
-		_ /* err */ = sup.wait(stopingTime, startErr)
-
+		eventNotifier.supervisorStartFailed(supRuntimeName, startErr)
 		return Supervisor{}, startErr
 	}
 
+	if hookErr := spec.runStartHooks(sup, stops); hookErr != nil {
+		return Supervisor{}, hookErr
+	}
+
 	return sup, nil
 }
@@ -0,0 +1,130 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+func TestShutdownTimeoutStackDump(t *testing.T) {
+	ctx := context.TODO()
+	evManager := NewEventManager()
+	evManager.StartCollector(ctx)
+
+	stuck, unblock := StuckWorker("child0", cap.WithShutdown(cap.Timeout(10*time.Millisecond)))
+	defer unblock()
+
+	supSpec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(stuck, WaitDoneWorker("child1")),
+		cap.WithNotifier(evManager.EventCollector(ctx)),
+	)
+
+	sup, startErr := supSpec.Start(ctx)
+	assert.NoError(t, startErr)
+
+	err := sup.Terminate()
+
+	// the termination error contains the stack dump of the stuck worker
+	var supErr *cap.SupervisorError
+	if assert.True(t, errors.As(err, &supErr)) {
+		var timeoutErr *cap.ShutdownTimeoutError
+		if assert.True(t, errors.As(supErr.GetNodeErrors()["child0"], &timeoutErr)) {
+			assert.Contains(t, timeoutErr.GetStackDump(), "stest.StuckWorker")
+			assert.Contains(t, timeoutErr.GetStackDump(), `"capataz.runtime_name":"root/child0"`)
+		}
+		assert.Contains(t, supErr.KVs(), "supervisor.node.child0.stop.stack_dump")
+	}
+
+	evIt := evManager.Iterator()
+	unblock()
+	evIt.SkipTill(WorkerLeakFinished("root/child0"))
+
+	// the failure event reports the stack dump as well
+	failedEv, ok := evManager.GetEventIx(4)
+	if assert.True(t, ok) && assert.True(t, WorkerFailed("root/child0").Call(failedEv)) {
+		var timeoutErr *cap.ShutdownTimeoutError
+		if assert.True(t, errors.As(failedEv.Err(), &timeoutErr)) {
+			assert.Contains(t, timeoutErr.GetStackDump(), "stest.StuckWorker")
+		}
+	}
+}
+
+func TestShutdownTimeoutStackDumpSubtree(t *testing.T) {
+	ctx := context.TODO()
+	evManager := NewEventManager()
+	evManager.StartCollector(ctx)
+
+	// the restart of the worker hangs, and keeps the sub-tree from terminating
+	slow, failSlow, releaseSlow := slowRestartWorker(
+		"child0",
+		cap.WithShutdown(cap.Timeout(time.Second)),
+	)
+	defer releaseSlow()
+
+	b0 := cap.NewSupervisorSpec("branch0", cap.WithNodes(slow))
+
+	supSpec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(cap.Subtree(b0)),
+		cap.WithShutdownTimeout(10*time.Millisecond),
+		cap.WithNotifier(evManager.EventCollector(ctx)),
+	)
+
+	sup, startErr := supSpec.Start(ctx)
+	assert.NoError(t, startErr)
+
+	evIt := evManager.Iterator()
+	failSlow()
+	evIt.SkipTill(WorkerFailed("root/branch0/child0"))
+
+	err := sup.Terminate()
+
+	// the stack dump of the sub-tree contains the stacks of its descendants
+	var supErr *cap.SupervisorError
+	if assert.True(t, errors.As(err, &supErr)) {
+		var timeoutErr *cap.ShutdownTimeoutError
+		if assert.True(t, errors.As(supErr.GetNodeErrors()["branch0"], &timeoutErr)) {
+			assert.Contains(t, timeoutErr.GetStackDump(), "slowRestartWorker")
+			assert.Contains(
+				t,
+				timeoutErr.GetStackDump(),
+				`"capataz.runtime_name":"root/branch0/child0"`,
+			)
+		}
+	}
+
+	releaseSlow()
+	evIt.SkipTill(SupervisorLeakFinished("root/branch0"))
+}
+
+func TestShutdownTimeoutStackDumpWithoutPprofLabels(t *testing.T) {
+	stuck, unblock := StuckWorker("child0", cap.WithShutdown(cap.Timeout(10*time.Millisecond)))
+	defer unblock()
+
+	supSpec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(stuck),
+		cap.WithoutPprofLabels(),
+	)
+
+	sup, startErr := supSpec.Start(context.TODO())
+	assert.NoError(t, startErr)
+
+	err := sup.Terminate()
+
+	var supErr *cap.SupervisorError
+	if assert.True(t, errors.As(err, &supErr)) {
+		var timeoutErr *cap.ShutdownTimeoutError
+		if assert.True(t, errors.As(supErr.GetNodeErrors()["child0"], &timeoutErr)) {
+			assert.Empty(t, timeoutErr.GetStackDump())
+		}
+	}
+}
@@ -38,22 +38,6 @@ func (o Order) sortStart(input0 []c.ChildSpec) []c.ChildSpec {
 	}
 }
 
-// sortTermination returns children sorted for the supervisor stop
-func (o Order) sortTermination(input0 []c.ChildSpec) []c.ChildSpec {
-	input := append(input0[:0:0], input0...)
-	switch o {
-	case LeftToRight:
-		for i, j := 0, len(input)-1; i < j; i, j = i+1, j-1 {
-			input[i], input[j] = input[j], input[i]
-		}
-		return input
-	case RightToLeft:
-		return input
-	default:
-		panic("Invalid cap.Order value")
-	}
-}
-
 // Strategy specifies how children get restarted when one of them reports an
 // error
 type Strategy uint32
@@ -75,6 +59,15 @@ func (spec SupervisorSpec) getEventNotifier() EventNotifier {
 	return spec.eventNotifier
 }
 
+// getClock returns the configured Clock or SystemClock (if none is given via
+// WithClock)
+func (spec SupervisorSpec) getClock() Clock {
+	if spec.clock == nil {
+		return SystemClock
+	}
+	return spec.clock
+}
+
 // CleanupResourcesFn is a function that cleans up resources that were
 // allocated in a BuildNodesFn function.
 //
@@ -103,21 +96,78 @@ type SupervisorSpec struct {
 	order           Order
 	strategy        Strategy
 	shutdownTimeout time.Duration
+	drainTimeout    time.Duration
+	noPprofLabels   bool
 	eventNotifier   EventNotifier
+	clock           Clock
+
+	startConcurrency    int
+	parallelTermination bool
+
+	nodeOverrides      []nodeOverride
+	nodeOverrideReport *nodeOverrideReport
+
+	nodeRegistry *nodeRegistry
+
+	startHooks []StartHook
+
+	// runtimeName is the runtime name of the supervisor, it is only set while
+	// the supervisor builds its children nodes
+	runtimeName string
+}
+
+// buildChildSpec constructs the childSpec record of the given node, applying
+// the settings this supervisor enforces on all its children, and the node
+// overrides that match the child runtime name. Children that enable fault
+// reporting run with a context that allows them to report injected faults
+// (check WithFaultReporting).
+func (spec SupervisorSpec) buildChildSpec(supRuntimeName string, node Node) c.ChildSpec {
+	// sub-trees use the runtime name of their parent to build their own
+	spec.runtimeName = supRuntimeName
+	chSpec := node(spec)
+	if spec.noPprofLabels {
+		c.WithPprofLabels(false)(&chSpec)
+	}
+	c.WithClock(spec.getClock())(&chSpec)
+	chSpec = spec.applyNodeOverrides(supRuntimeName, chSpec)
+	if !chSpec.ReportsFaults() {
+		return chSpec
+	}
+	return spec.withFaultReporter(supRuntimeName, chSpec)
 }
 
 // buildChildren constructs the childSpec records that the Supervisor is going
 // to monitor at runtime.
-func (spec SupervisorSpec) buildChildrenSpecs() ([]c.ChildSpec, CleanupResourcesFn, error) {
+func (spec SupervisorSpec) buildChildrenSpecs(
+	supRuntimeName string,
+) ([]c.ChildSpec, CleanupResourcesFn, error) {
 	nodes, cleanup, err := spec.buildNodes()
 	if err != nil {
 		return []c.ChildSpec{}, cleanup, err
 	}
 
 	children := make([]c.ChildSpec, 0, len(nodes))
-	for _, buildChildSpec := range nodes {
-		children = append(children, buildChildSpec(spec))
+	for _, node := range nodes {
+		children = append(children, spec.buildChildSpec(supRuntimeName, node))
 	}
+
+	// the resources got allocated already, we must clean them up if the
+	// dependencies between the children are not valid
+	if depErr := validateNodeDependencies(supRuntimeName, children); depErr != nil {
+		if cleanupErr := cleanup(); cleanupErr != nil {
+			return []c.ChildSpec{}, cleanup, &SupervisorError{
+				supRuntimeName: supRuntimeName,
+				nodeErr:        depErr,
+				rscCleanupErr:  cleanupErr,
+			}
+		}
+		return []c.ChildSpec{}, cleanup, depErr
+	}
+
+	if spec.nodeRegistry != nil {
+		spec.nodeRegistry.setChildren(supRuntimeName, children)
+	}
+
 	return children, cleanup, nil
 }
 
@@ -223,9 +273,8 @@ func (spec SupervisorSpec) buildChildrenSpecs() ([]c.ChildSpec, CleanupResources
 //
 func NewSupervisorSpec(name string, buildNodes BuildNodesFn, opts ...Opt) SupervisorSpec {
 	spec := SupervisorSpec{
-		buildNodes:      buildNodes,
-		shutdownTimeout: defaultSupShutdownTimeout,
-		eventNotifier:   emptyEventNotifier,
+		buildNodes:    buildNodes,
+		eventNotifier: emptyEventNotifier,
 	}
 
 	// Check name cannot be empty
@@ -264,6 +313,14 @@ func NewSupervisorSpec(name string, buildNodes BuildNodesFn, opts ...Opt) Superv
 // in reverse order all the child nodes that have been started, finally
 // returning an error value.
 //
+// Cancellation of the Supervisor Start
+//
+// In the scenario that the given context is done before all the child nodes
+// started, the Start algorithm is going to abort the start of the child node
+// that is starting (its context gets cancelled), it will not start the
+// remaining child nodes, and is going to stop in reverse order all the child
+// nodes that have been started, finally returning a StartCancelledError.
+//
 func (spec SupervisorSpec) Start(parentCtx context.Context) (Supervisor, error) {
 	sup, err := spec.rootStart(parentCtx, rootSupervisorName)
 	if err != nil {
@@ -0,0 +1,242 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// slowStartWorker creates a worker that does not notify its start until its
+// context is done, or until the given start delay passes
+func slowStartWorker(name string, startDelay time.Duration) cap.Node {
+	return cap.NewWorkerWithNotifyStart(
+		name,
+		func(ctx context.Context, notifyStart cap.NotifyStartFn) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(startDelay):
+			}
+			notifyStart(nil)
+			<-ctx.Done()
+			return nil
+		},
+	)
+}
+
+// observeCancelledStart starts a supervisor with a context that is cancelled
+// after the given delay, it returns the events of the supervision tree and the
+// start error
+func observeCancelledStart(
+	delay time.Duration,
+	buildNodes cap.BuildNodesFn,
+	opts ...cap.Opt,
+) ([]cap.Event, error) {
+	ctx, cancelFn := context.WithTimeout(context.Background(), delay)
+	defer cancelFn()
+
+	evManager := NewEventManager()
+	evManager.StartCollector(context.TODO())
+
+	opts = append([]cap.Opt{cap.WithNotifier(evManager.EventCollector(context.TODO()))}, opts...)
+	_, err := cap.NewSupervisorSpec("root", buildNodes, opts...).Start(ctx)
+
+	evIt := evManager.Iterator()
+	evIt.SkipTill(SupervisorStartFailed("root"))
+
+	return evManager.Snapshot(), err
+}
+
+func TestStartCancelled(t *testing.T) {
+	events, err := observeCancelledStart(
+		20*time.Millisecond,
+		cap.WithNodes(
+			WaitDoneWorker("child0"),
+			slowStartWorker("child1", time.Minute),
+			WaitDoneWorker("child2"),
+		),
+	)
+
+	var cancelErr *cap.StartCancelledError
+	if assert.True(t, errors.As(err, &cancelErr)) {
+		assert.Equal(t, "root", cancelErr.GetRuntimeName())
+		assert.Equal(t, []string{"root/child1", "root/child2"}, cancelErr.GetPendingNodes())
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	}
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerStartFailed("root/child1"),
+			WorkerTerminated("root/child0"),
+			SupervisorStartFailed("root"),
+		},
+	)
+}
+
+func TestStartCancelledChildStartsAfterCancel(t *testing.T) {
+	reasonCh := make(chan cap.TerminationReason, 1)
+
+	// this worker does not check its context on start
+	lateWorker := cap.NewWorkerWithNotifyStart(
+		"child1",
+		func(ctx context.Context, notifyStart cap.NotifyStartFn) error {
+			time.Sleep(50 * time.Millisecond)
+			notifyStart(nil)
+			<-ctx.Done()
+			reasonCh <- cap.GetTerminationReason(ctx)
+			return nil
+		},
+	)
+
+	events, err := observeCancelledStart(
+		10*time.Millisecond,
+		cap.WithNodes(WaitDoneWorker("child0"), lateWorker),
+	)
+
+	var cancelErr *cap.StartCancelledError
+	if assert.True(t, errors.As(err, &cancelErr)) {
+		assert.Equal(t, []string{"root/child1"}, cancelErr.GetPendingNodes())
+	}
+
+	// the worker that started late got terminated
+	assert.Equal(t, cap.ShutdownReason, <-reasonCh)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerStartFailed("root/child1"),
+			WorkerTerminated("root/child0"),
+			SupervisorStartFailed("root"),
+		},
+	)
+}
+
+func TestStartCancelledChildDoesNotStop(t *testing.T) {
+	releaseCh := make(chan struct{})
+
+	// this worker ignores its context while it is starting
+	stuckWorker := cap.NewWorkerWithNotifyStart(
+		"child1",
+		func(ctx context.Context, notifyStart cap.NotifyStartFn) error {
+			<-releaseCh
+			notifyStart(nil)
+			return nil
+		},
+		cap.WithShutdown(cap.Timeout(20*time.Millisecond)),
+	)
+
+	evManager := NewEventManager()
+	evManager.StartCollector(context.TODO())
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelFn()
+
+	_, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(WaitDoneWorker("child0"), stuckWorker),
+		cap.WithNotifier(evManager.EventCollector(context.TODO())),
+	).Start(ctx)
+
+	// the start gives up on the child once its shutdown timeout is reached
+	var cancelErr *cap.StartCancelledError
+	if assert.True(t, errors.As(err, &cancelErr)) {
+		assert.Equal(t, []string{"root/child1"}, cancelErr.GetPendingNodes())
+	}
+	var supErr *cap.SupervisorError
+	if assert.True(t, errors.As(err, &supErr)) {
+		var timeoutErr *cap.ShutdownTimeoutError
+		assert.True(t, errors.As(supErr.GetNodeErrors()["child1"], &timeoutErr))
+	}
+
+	// the leaked goroutine of the child is reported once it finishes
+	close(releaseCh)
+	evIt := evManager.Iterator()
+	evIt.SkipTill(WorkerLeakFinished("root/child1"))
+
+	AssertExactMatch(t, evManager.Snapshot(),
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerFailed("root/child1"),
+			WorkerTerminated("root/child0"),
+			SupervisorStartFailed("root"),
+			WorkerLeakFinished("root/child1"),
+		},
+	)
+}
+
+func TestStartCancelledOnSubtree(t *testing.T) {
+	b0 := cap.NewSupervisorSpec(
+		"branch0",
+		cap.WithNodes(
+			WaitDoneWorker("child1"),
+			slowStartWorker("child2", time.Minute),
+		),
+	)
+
+	events, err := observeCancelledStart(
+		20*time.Millisecond,
+		cap.WithNodes(
+			WaitDoneWorker("child0"),
+			cap.Subtree(b0),
+			WaitDoneWorker("child3"),
+		),
+	)
+
+	var cancelErr *cap.StartCancelledError
+	if assert.True(t, errors.As(err, &cancelErr)) {
+		assert.Equal(t, []string{"root/branch0", "root/child3"}, cancelErr.GetPendingNodes())
+	}
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerStarted("root/branch0/child1"),
+			WorkerStartFailed("root/branch0/child2"),
+			WorkerTerminated("root/branch0/child1"),
+			SupervisorStartFailed("root/branch0"),
+			WorkerTerminated("root/child0"),
+			SupervisorStartFailed("root"),
+		},
+	)
+}
+
+func TestStartCancelledInParallel(t *testing.T) {
+	events, err := observeCancelledStart(
+		20*time.Millisecond,
+		cap.WithNodes(
+			WaitDoneWorker("child0"),
+			slowStartWorker("child1", time.Minute),
+			WaitDoneWorker("child2", cap.WithDependsOn("child1")),
+		),
+		cap.WithParallelStart(2),
+	)
+
+	var cancelErr *cap.StartCancelledError
+	if assert.True(t, errors.As(err, &cancelErr)) {
+		assert.Equal(t, []string{"root/child1", "root/child2"}, cancelErr.GetPendingNodes())
+	}
+
+	AssertPartialMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerTerminated("root/child0"),
+			SupervisorStartFailed("root"),
+		},
+	)
+	AssertPartialMatch(t, events,
+		[]EventP{
+			WorkerStartFailed("root/child1"),
+			WorkerTerminated("root/child0"),
+			SupervisorStartFailed("root"),
+		},
+	)
+	assert.Equal(t, 4, len(events))
+}
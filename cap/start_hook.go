@@ -0,0 +1,98 @@
+package cap
+
+// This file contains the start hooks of root supervisors; they allow other
+// packages to run logic that requires a running Supervisor (e.g. serve its
+// tree) for as long as the Supervisor runs.
+
+import (
+	"fmt"
+	"sync"
+)
+
+// StartHook is a function that gets called with a root Supervisor once it
+// started. The returned function (if any) gets called when the Supervisor
+// terminates; it must not wait on the Supervisor (e.g. call Wait or Terminate).
+type StartHook func(Supervisor) (stop func(), err error)
+
+// WithStartHook is an Opt that registers a StartHook on a root supervisor.
+// Hooks get called in the order they were registered, and their stop functions
+// in the reverse order. When a hook fails, the supervisor gets terminated and
+// Start returns the hook error; the termination of the supervisor is reported
+// with an Event with an EventTag of ProcessStartFailed.
+//
+// Hooks are only called on root supervisors (including DynSupervisor); the
+// hooks of a sub-tree spec are ignored.
+//
+func WithStartHook(hook StartHook) Opt {
+	return func(spec *SupervisorSpec) {
+		spec.startHooks = append(spec.startHooks, hook)
+	}
+}
+
+// hookStops keeps track of the stop functions of the start hooks of a root
+// supervisor. The supervisor may terminate while the hooks are still being
+// called; the stop functions registered after the termination are called
+// right away.
+type hookStops struct {
+	mux     sync.Mutex
+	stops   []func()
+	stopped bool
+	// startErr is the error of the start hook that failed, if any
+	startErr error
+}
+
+// add registers the stop function of a start hook
+func (hs *hookStops) add(stop func()) {
+	if stop == nil {
+		return
+	}
+	hs.mux.Lock()
+	if hs.stopped {
+		hs.mux.Unlock()
+		stop()
+		return
+	}
+	hs.stops = append(hs.stops, stop)
+	hs.mux.Unlock()
+}
+
+// fail registers the error of a start hook that failed, it must be called
+// before the supervisor gets terminated
+func (hs *hookStops) fail(err error) {
+	hs.mux.Lock()
+	hs.startErr = err
+	hs.mux.Unlock()
+}
+
+// stopAll calls the registered stop functions in the reverse order they were
+// registered; it returns the error of the start hook that failed, if any
+func (hs *hookStops) stopAll() error {
+	hs.mux.Lock()
+	hs.stopped = true
+	stops := hs.stops
+	hs.stops = nil
+	startErr := hs.startErr
+	hs.mux.Unlock()
+
+	for i := len(stops) - 1; i >= 0; i-- {
+		stops[i]()
+	}
+	return startErr
+}
+
+// runStartHooks calls the start hooks of the given root supervisor, if one of
+// them fails, the supervisor gets terminated and its termination is reported as
+// a start failure
+func (spec SupervisorSpec) runStartHooks(sup Supervisor, stops *hookStops) error {
+	for _, hook := range spec.startHooks {
+		stop, err := hook(sup)
+		if err != nil {
+			startErr := fmt.Errorf("start hook of supervisor %s failed: %w", sup.runtimeName, err)
+			stops.fail(startErr)
+			_ = sup.Terminate()
+			return startErr
+		}
+		stops.add(stop)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+func TestStartHooks(t *testing.T) {
+	calls := []string{}
+	hook := func(name string) cap.StartHook {
+		return func(sup cap.Supervisor) (func(), error) {
+			calls = append(calls, "start "+name+" "+sup.GetName())
+			return func() { calls = append(calls, "stop "+name) }, nil
+		}
+	}
+
+	b0 := cap.NewSupervisorSpec(
+		"branch0",
+		cap.WithNodes(WaitDoneWorker("child0")),
+		// hooks of sub-trees are ignored
+		cap.WithStartHook(hook("ignored")),
+	)
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(cap.Subtree(b0)),
+		cap.WithStartHook(hook("first")),
+		cap.WithStartHook(hook("second")),
+	).Start(context.TODO())
+	assert.NoError(t, err)
+
+	assert.NoError(t, sup.Terminate())
+	assert.Equal(
+		t,
+		[]string{"start first root", "start second root", "stop second", "stop first"},
+		calls,
+	)
+}
+
+func TestStartHookFailure(t *testing.T) {
+	stopped := false
+	hookErr := errors.New("hook failure")
+
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(WaitDoneWorker("child0")),
+		cap.WithStartHook(func(cap.Supervisor) (func(), error) {
+			return func() { stopped = true }, nil
+		}),
+		cap.WithStartHook(func(cap.Supervisor) (func(), error) {
+			return nil, hookErr
+		}),
+	).Start(context.TODO())
+
+	assert.True(t, errors.Is(err, hookErr))
+	assert.Equal(t, "start hook of supervisor root failed: hook failure", err.Error())
+	assert.Equal(t, "", sup.GetName())
+	// the supervisor got terminated, calling the stop functions of the hooks
+	// that succeeded
+	assert.True(t, stopped)
+}
+
+func TestStartHookFailureEvents(t *testing.T) {
+	hookErr := errors.New("hook failure")
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(WaitDoneWorker("child0")),
+		[]cap.Opt{
+			cap.WithStartHook(func(cap.Supervisor) (func(), error) {
+				return nil, hookErr
+			}),
+		},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			WaitForEvent(t, &evIt, SupervisorStartFailed("root"), time.Second)
+		},
+	)
+
+	assert.True(t, errors.Is(err, hookErr))
+
+	// the supervisor reported it started before the hook got called, its
+	// termination is reported as a start failure
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child0"),
+			SupervisorStartFailed("root"),
+		},
+	)
+	assert.True(t, errors.Is(events[3].Err(), hookErr))
+}
@@ -21,10 +21,13 @@ import (
 func (spec SupervisorSpec) run(
 	ctx context.Context,
 	parentName string,
+	ownEventNotifier EventNotifier,
 	onStart c.NotifyStartFn,
 ) error {
+	supRuntimeName := buildRuntimeName(spec, parentName)
+
 	// Build childrenSpec and resource cleanup
-	supChildrenSpecs, supRscCleanup, rscAllocError := spec.buildChildrenSpecs()
+	supChildrenSpecs, supRscCleanup, rscAllocError := spec.buildChildrenSpecs(supRuntimeName)
 
 	// Do not even start the monitor loop if we find an error on the resource
 	// allocation logic
@@ -39,11 +42,20 @@ func (spec SupervisorSpec) run(
 	// ctrlCh is used to keep track of request from client APIs (e.g. spawn child)
 	ctrlCh := make(chan ctrlMsg)
 
-	supRuntimeName := buildRuntimeName(spec, parentName)
-
-	onTerminate := func(err terminateError) {}
+	// the termination of a sub-tree is notified by its parent supervisor, which
+	// only uses its own notifier; we notify the sub-tree's own notifier here
+	onTerminate := func(err terminateError) {
+		if ownEventNotifier == nil {
+			return
+		}
+		if err != nil {
+			ownEventNotifier.supervisorFailed(spec.getClock(), supRuntimeName, err)
+			return
+		}
+		ownEventNotifier.supervisorTerminated(spec.getClock(), supRuntimeName, spec.getClock().Now())
+	}
 
-	startTime := time.Now()
+	startTime := spec.getClock().Now()
 	// spawn goroutine with supervisor monitorLoop
 	return runMonitorLoop(
 		ctx,
@@ -64,6 +76,7 @@ func (spec SupervisorSpec) run(
 func subtreeMain(
 	parentName string,
 	supSpec SupervisorSpec,
+	ownEventNotifier EventNotifier,
 ) func(context.Context, c.NotifyStartFn) error {
 	// we use the start version that receives the notifyChildStart callback, this
 	// is essential, as we need this callback to signal the sub-tree children have
@@ -73,7 +86,7 @@ func subtreeMain(
 		// to spawn yet another goroutine
 		ctx, cancelFn := context.WithCancel(parentCtx)
 		defer cancelFn()
-		return supSpec.run(ctx, parentName, notifyChildStart)
+		return supSpec.run(ctx, parentName, ownEventNotifier, notifyChildStart)
 	}
 }
 
@@ -84,30 +97,77 @@ func (spec SupervisorSpec) subtree(
 	subtreeSpec SupervisorSpec,
 	copts0 ...c.Opt,
 ) c.ChildSpec {
-	subtreeSpec.eventNotifier = spec.eventNotifier
+	// the sub-tree notifies its events to the parent's notifier, and to its own
+	// notifier (if any) as well
+	ownEventNotifier := subtreeSpec.eventNotifier
+	subtreeSpec.eventNotifier = spec.eventNotifier.withNotifier(ownEventNotifier)
+
+	// the node overrides of the parent supervisor are applied after the
+	// sub-tree ones, and the matches are reported to the root supervisor
+	subtreeSpec.nodeOverrides = append(
+		subtreeSpec.nodeOverrides[:len(subtreeSpec.nodeOverrides):len(subtreeSpec.nodeOverrides)],
+		spec.nodeOverrides...,
+	)
+	subtreeSpec.nodeOverrideReport = spec.nodeOverrideReport
+	subtreeSpec.nodeRegistry = spec.nodeRegistry
+
+	// the sub-tree uses the clock of the parent supervisor, unless it has its
+	// own
+	if subtreeSpec.clock == nil {
+		subtreeSpec.clock = spec.clock
+	}
+
+	// NOTE: The default tolerance of a sub-tree is applied before the caller
+	// options, this way a WithTolerance given to Subtree takes precedence.
+	copts := make([]c.Opt, 0, len(copts0)+3)
+	copts = append(copts, c.WithTolerance(1, 5*time.Second))
+	copts = append(copts, copts0...)
 
 	// NOTE: Child goroutines that are running a sub-tree supervisor must always
 	// have a timeout of Infinity, as specified in the documentation from OTP
 	// http://erlang.org/doc/design_principles/sup_princ.html#child-specification
-	copts := append(
-		copts0,
+	//
+	// The termination of a sub-tree is bounded by the sub-tree's own
+	// WithShutdownTimeout setting, or by the deadline of a parent supervisor.
+	copts = append(
+		copts,
 		c.WithShutdown(c.Indefinitely),
 		c.WithTag(c.Supervisor),
-		c.WithTolerance(1, 5*time.Second),
 	)
 
-	return c.NewWithNotifyStart(
+	chSpec := c.NewWithNotifyStart(
 		subtreeSpec.GetName(),
-		subtreeMain(spec.name, subtreeSpec),
+		subtreeMain(spec.runtimeName, subtreeSpec, ownEventNotifier),
 		copts...,
 	)
+	// the sub-tree spec is kept to introspect the supervision tree (check
+	// Describe)
+	chSpec.Subtree = subtreeSpec
+	return chSpec
 }
 
 // Subtree transforms SupervisorSpec into a Node. This function allows you to
 // insert a black-box sub-system into a bigger supervised system.
 //
-// Note the subtree SupervisorSpec is going to inherit the event notifier from
-// its parent supervisor.
+// The given WorkerOpt values configure how the parent supervisor restarts the
+// sub-tree; the precedence of the sub-tree settings is the following:
+//
+// * WithTolerance -- the given value is used, by default a sub-tree tolerates
+// 1 error every 5 seconds
+//
+// * WithRestart -- the given value is used, by default a sub-tree is Permanent
+//
+// * WithShutdown -- the given value is ignored, the parent supervisor always
+// waits Indefinitely for a sub-tree to stop (the sub-tree's own
+// WithShutdownTimeout bounds its termination)
+//
+// * Node overrides (check WithNodeOverride) from the parent supervisors are
+// applied after all the settings above
+//
+// Note the subtree SupervisorSpec is going to notify its events to the event
+// notifier of its parent supervisor. If the subtree SupervisorSpec has its own
+// event notifier (given with WithNotifier), this one gets called as well
+// (after the parent's one), and it only receives the events of the sub-tree.
 //
 // Example:
 //
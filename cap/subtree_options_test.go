@@ -0,0 +1,177 @@
+package cap_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// failingSubtree creates a sub-tree spec that fails every time the returned
+// function is called, for a total of two failures
+func failingSubtree(opts ...cap.Opt) (cap.SupervisorSpec, func(bool)) {
+	child1, failWorker1 := FailOnSignalWorker(
+		2,
+		"child1",
+		// the sub-tree fails on every worker failure
+		cap.WithTolerance(0, 10*time.Second),
+	)
+	return cap.NewSupervisorSpec("subtree1", cap.WithNodes(child1), opts...), failWorker1
+}
+
+func TestSubtreeWithTolerance(t *testing.T) {
+	tree1, failWorker1 := failingSubtree()
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(cap.Subtree(tree1, cap.WithTolerance(2, 10*time.Second))),
+		[]cap.Opt{},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			evIt.SkipTill(SupervisorStarted("root"))
+
+			failWorker1(false /* done */)
+			evIt.SkipTill(SupervisorStarted("root/subtree1"))
+
+			failWorker1(true /* done */)
+			evIt.SkipTill(SupervisorStarted("root/subtree1"))
+		},
+	)
+
+	// the default tolerance of 1 error would have made the root supervisor fail
+	assert.NoError(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/subtree1/child1"),
+			SupervisorStarted("root/subtree1"),
+			SupervisorStarted("root"),
+			WorkerFailed("root/subtree1/child1"),
+			SupervisorFailed("root/subtree1"),
+			WorkerStarted("root/subtree1/child1"),
+			SupervisorStarted("root/subtree1"),
+			WorkerFailed("root/subtree1/child1"),
+			SupervisorFailed("root/subtree1"),
+			WorkerStarted("root/subtree1/child1"),
+			SupervisorStarted("root/subtree1"),
+			WorkerTerminated("root/subtree1/child1"),
+			SupervisorTerminated("root/subtree1"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestSubtreeDefaultTolerance(t *testing.T) {
+	tree1, failWorker1 := failingSubtree()
+
+	_, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(cap.Subtree(tree1)),
+		[]cap.Opt{},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			evIt.SkipTill(SupervisorStarted("root"))
+
+			failWorker1(false /* done */)
+			evIt.SkipTill(SupervisorStarted("root/subtree1"))
+
+			failWorker1(true /* done */)
+			evIt.SkipTill(SupervisorFailed("root"))
+		},
+	)
+
+	assert.Error(t, err)
+}
+
+func TestSubtreeWithRestart(t *testing.T) {
+	tree1, failWorker1 := failingSubtree()
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			cap.Subtree(tree1, cap.WithRestart(cap.Temporary)),
+			WaitDoneWorker("child2"),
+		),
+		[]cap.Opt{},
+		func(em EventManager) {
+			evIt := em.Iterator()
+			evIt.SkipTill(SupervisorStarted("root"))
+
+			failWorker1(true /* done */)
+			evIt.SkipTill(SupervisorFailed("root/subtree1"))
+		},
+	)
+
+	assert.NoError(t, err)
+
+	// the temporary sub-tree does not get restarted
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/subtree1/child1"),
+			SupervisorStarted("root/subtree1"),
+			WorkerStarted("root/child2"),
+			SupervisorStarted("root"),
+			WorkerFailed("root/subtree1/child1"),
+			SupervisorFailed("root/subtree1"),
+			WorkerTerminated("root/child2"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestSubtreeWithNotifier(t *testing.T) {
+	ctx := context.TODO()
+	subtreeEvManager := NewEventManager()
+	subtreeEvManager.StartCollector(ctx)
+
+	tree1 := cap.NewSupervisorSpec(
+		"subtree1",
+		cap.WithNodes(WaitDoneWorker("child1")),
+		cap.WithNotifier(subtreeEvManager.EventCollector(ctx)),
+	)
+
+	events, err := ObserveSupervisor(
+		ctx,
+		"root",
+		cap.WithNodes(cap.Subtree(tree1), WaitDoneWorker("child2")),
+		[]cap.Opt{},
+		func(EventManager) {},
+	)
+
+	assert.NoError(t, err)
+
+	// the parent notifier gets all the events of the tree
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/subtree1/child1"),
+			SupervisorStarted("root/subtree1"),
+			WorkerStarted("root/child2"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child2"),
+			WorkerTerminated("root/subtree1/child1"),
+			SupervisorTerminated("root/subtree1"),
+			SupervisorTerminated("root"),
+		},
+	)
+
+	// the sub-tree notifier only gets the events of the sub-tree
+	subtreeEvIt := subtreeEvManager.Iterator()
+	subtreeEvIt.SkipTill(SupervisorTerminated("root/subtree1"))
+
+	AssertExactMatch(t, subtreeEvManager.Snapshot(),
+		[]EventP{
+			WorkerStarted("root/subtree1/child1"),
+			SupervisorStarted("root/subtree1"),
+			WorkerTerminated("root/subtree1/child1"),
+			SupervisorTerminated("root/subtree1"),
+		},
+	)
+}
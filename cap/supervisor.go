@@ -4,6 +4,9 @@ package cap
 // Supervisor API
 
 import (
+	"context"
+	"errors"
+	"sort"
 	"sync"
 	"time"
 
@@ -21,6 +24,8 @@ type terminationManager struct {
 	mux          *sync.Mutex
 	terminated   bool
 	terminateErr error
+	stoppingTime time.Time
+	doneCh       chan struct{}
 }
 
 // newTerminationManager creates a new terminationManager
@@ -31,6 +36,7 @@ func newTerminationManager() *terminationManager {
 		mux:          &mux,
 		terminated:   false,
 		terminateErr: nil,
+		doneCh:       make(chan struct{}),
 	}
 }
 
@@ -48,12 +54,187 @@ func (tm *terminationManager) getTerminateErr() (bool, error) {
 }
 
 // setTerminationErr is a concurrent-safe function that registers the final
-// state of a Supervisor.
+// state of a Supervisor. Only the first registered state is kept.
 func (tm *terminationManager) setTerminationErr(err error) {
 	tm.mux.Lock()
 	defer tm.mux.Unlock()
+	if tm.terminated {
+		return
+	}
 	tm.terminated = true
 	tm.terminateErr = err
+	close(tm.doneCh)
+}
+
+// setStoppingTime is a concurrent-safe function that registers the moment a
+// termination of the Supervisor was requested. Only the first request is kept.
+func (tm *terminationManager) setStoppingTime(stoppingTime time.Time) {
+	tm.mux.Lock()
+	defer tm.mux.Unlock()
+	if tm.stoppingTime == (time.Time{}) {
+		tm.stoppingTime = stoppingTime
+	}
+}
+
+// getStoppingTime is a concurrent-safe function that returns the moment a
+// termination of the Supervisor was requested, if there is none, it returns a
+// zero time.Time.
+func (tm *terminationManager) getStoppingTime() time.Time {
+	tm.mux.Lock()
+	defer tm.mux.Unlock()
+	return tm.stoppingTime
+}
+
+// runningNodes keeps track of the nodes of a supervision tree that have been
+// started and have not terminated yet. It gets updated from the events of the
+// supervision system.
+type runningNodes struct {
+	mux   *sync.Mutex
+	nodes map[string]struct{}
+}
+
+// newRunningNodes creates a new runningNodes
+func newRunningNodes() *runningNodes {
+	var mux sync.Mutex
+
+	return &runningNodes{
+		mux:   &mux,
+		nodes: make(map[string]struct{}),
+	}
+}
+
+// handleEvent updates the running nodes with the given Event
+func (rn *runningNodes) handleEvent(ev Event) {
+	rn.mux.Lock()
+	defer rn.mux.Unlock()
+
+	switch ev.GetTag() {
+	case ProcessStarted:
+		rn.nodes[ev.GetProcessRuntimeName()] = struct{}{}
+	case ProcessTerminated, ProcessFailed, ProcessCompleted, ProcessStartFailed:
+		delete(rn.nodes, ev.GetProcessRuntimeName())
+	}
+}
+
+// trackEvents returns an EventNotifier that keeps the running nodes up to date
+// before calling the given EventNotifier
+func (rn *runningNodes) trackEvents(eventNotifier EventNotifier) EventNotifier {
+	return func(ev Event) {
+		rn.handleEvent(ev)
+		eventNotifier(ev)
+	}
+}
+
+// list returns the runtime names of the running nodes in lexicographical order
+func (rn *runningNodes) list() []string {
+	rn.mux.Lock()
+	defer rn.mux.Unlock()
+
+	names := make([]string, 0, len(rn.nodes))
+	for name := range rn.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LeakedNode represents a node of the supervision tree that did not stop
+// within its shutdown timeout, and whose goroutine is still running.
+type LeakedNode struct {
+	runtimeName string
+	leakedSince time.Time
+	clock       Clock
+}
+
+// GetRuntimeName returns the runtime name of the leaked node
+func (ln LeakedNode) GetRuntimeName() string {
+	return ln.runtimeName
+}
+
+// GetLeakedSince returns the moment the supervisor gave up on the termination
+// of the node
+func (ln LeakedNode) GetLeakedSince() time.Time {
+	return ln.leakedSince
+}
+
+// GetLeakedDuration returns for how long the node has been leaked
+func (ln LeakedNode) GetLeakedDuration() time.Duration {
+	return ln.clock.Now().Sub(ln.leakedSince)
+}
+
+// leakedNodes keeps track of the nodes of a supervision tree that did not stop
+// within their shutdown timeout and have not finished yet. It gets updated from
+// the events of the supervision system.
+//
+// Leaks are indexed by the error that reported them rather than by runtime
+// name; a node that leaked may be restarted and leak again before its first
+// goroutine finishes.
+type leakedNodes struct {
+	mux   *sync.Mutex
+	nodes map[*ShutdownTimeoutError]LeakedNode
+	clock Clock
+}
+
+// newLeakedNodes creates a new leakedNodes, the given clock is used to report
+// for how long the nodes have been leaked
+func newLeakedNodes(clock Clock) *leakedNodes {
+	var mux sync.Mutex
+
+	return &leakedNodes{
+		mux:   &mux,
+		nodes: make(map[*ShutdownTimeoutError]LeakedNode),
+		clock: clock,
+	}
+}
+
+// handleEvent updates the leaked nodes with the given Event
+func (ln *leakedNodes) handleEvent(ev Event) {
+	ln.mux.Lock()
+	defer ln.mux.Unlock()
+
+	switch ev.GetTag() {
+	case ProcessFailed:
+		var timeoutErr *ShutdownTimeoutError
+		// the error of a supervisor may wrap the timeout error of a child
+		if errors.As(ev.Err(), &timeoutErr) &&
+			timeoutErr.GetRuntimeName() == ev.GetProcessRuntimeName() {
+			ln.nodes[timeoutErr] = LeakedNode{
+				runtimeName: ev.GetProcessRuntimeName(),
+				leakedSince: ev.GetCreated(),
+				clock:       ln.clock,
+			}
+		}
+	case ProcessLeakFinished:
+		delete(ln.nodes, ev.leak)
+	}
+}
+
+// trackEvents returns an EventNotifier that keeps the leaked nodes up to date
+// before calling the given EventNotifier
+func (ln *leakedNodes) trackEvents(eventNotifier EventNotifier) EventNotifier {
+	return func(ev Event) {
+		ln.handleEvent(ev)
+		eventNotifier(ev)
+	}
+}
+
+// list returns the leaked nodes sorted by runtime name, the leaks of the same
+// node are sorted by the moment they happened
+func (ln *leakedNodes) list() []LeakedNode {
+	ln.mux.Lock()
+	defer ln.mux.Unlock()
+
+	nodes := make([]LeakedNode, 0, len(ln.nodes))
+	for _, node := range ln.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].runtimeName != nodes[j].runtimeName {
+			return nodes[i].runtimeName < nodes[j].runtimeName
+		}
+		return nodes[i].leakedSince.Before(nodes[j].leakedSince)
+	})
+	return nodes
 }
 
 // Supervisor represents the root of a tree of goroutines. A Supervisor may have
@@ -64,35 +245,65 @@ func (tm *terminationManager) setTerminationErr(err error) {
 type Supervisor struct {
 	runtimeName string
 
-	ctrlCh      chan ctrlMsg
-	terminateCh chan error
+	ctrlCh chan ctrlMsg
 
 	terminateManager *terminationManager
+	runningNodes     *runningNodes
+	leakedNodes      *leakedNodes
 
 	spec     SupervisorSpec
 	children map[string]c.Child
 	cancel   func()
-	wait     func(time.Time, startError) error
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Public API
 
-const defaultSupShutdownTimeout = 5 * time.Second
-
 // Terminate is a synchronous procedure that halts the execution of the whole
 // supervision tree.
 func (sup Supervisor) Terminate() error {
-	stopingTime := time.Now()
+	return sup.TerminateContext(context.Background())
+}
+
+// TerminateContext is a synchronous procedure that halts the execution of the
+// whole supervision tree. It returns a TerminationPendingError when the given
+// context is done before the supervision tree finishes its termination.
+//
+// Giving up on the termination does not affect the termination procedure
+// itself; it keeps going on the background, and it is still possible to get
+// its final result with a later call to Wait.
+func (sup Supervisor) TerminateContext(ctx context.Context) error {
+	sup.terminateManager.setStoppingTime(sup.spec.getClock().Now())
 	sup.cancel()
-	err := sup.wait(stopingTime, nil /* no startErr */)
-	return err
+	return sup.WaitContext(ctx)
 }
 
 // Wait blocks the execution of the current goroutine until the Supervisor
 // finishes it execution.
 func (sup Supervisor) Wait() error {
-	return sup.wait(time.Time{}, nil /* no startErr */)
+	return sup.WaitContext(context.Background())
+}
+
+// WaitContext blocks the execution of the current goroutine until the
+// Supervisor finishes it execution, or until the given context is done. In
+// the later case, it returns a TerminationPendingError that reports the nodes
+// that are still running.
+//
+// Giving up on the wait does not affect the state of the Supervisor, a later
+// call to Wait is still going to return the final result of the supervision
+// tree.
+func (sup Supervisor) WaitContext(ctx context.Context) error {
+	select {
+	case <-sup.terminateManager.doneCh:
+		_, terminateErr := sup.terminateManager.getTerminateErr()
+		return terminateErr
+	case <-ctx.Done():
+		return &TerminationPendingError{
+			supRuntimeName: sup.runtimeName,
+			pendingNodes:   sup.runningNodes.list(),
+			err:            ctx.Err(),
+		}
+	}
 }
 
 // GetName returns the name of the Spec used to start this Supervisor
@@ -104,79 +315,98 @@ func (sup Supervisor) GetName() string {
 // supervisor and to signal the event notifications system
 func storeTerminationErr(
 	eventNotifier EventNotifier,
+	clock Clock,
 	supRuntimeName string,
 	tm *terminationManager,
 	err error,
-	stopingTime time.Time,
 ) {
-	tm.setTerminationErr(err)
+	// we notify before registering the final state, this way, the termination
+	// event is always emitted by the time the public API returns
 	if err != nil {
-		eventNotifier.supervisorFailed(supRuntimeName, err)
+		eventNotifier.supervisorFailed(clock, supRuntimeName, err)
+		tm.setTerminationErr(err)
 		return
 	}
 
-	// stopingTime is only relevant when we call the internal wait function
-	// from the Terminate() public API; if we just called from Wait(), we don't
-	// need to keep track of the stop duration
-	if stopingTime == (time.Time{}) {
-		stopingTime = time.Now()
+	// stoppingTime is only registered when the termination was requested via
+	// the Terminate() public API; if the supervisor finished by other means, we
+	// don't need to keep track of the stop duration
+	stoppingTime := tm.getStoppingTime()
+	if stoppingTime == (time.Time{}) {
+		stoppingTime = clock.Now()
 	}
-	eventNotifier.supervisorTerminated(supRuntimeName, stopingTime)
+	eventNotifier.supervisorTerminated(clock, supRuntimeName, stoppingTime)
+	tm.setTerminationErr(nil)
 }
 
 // getCrashError will return an error if the supervisor crashed, otherwise
 // returns nil.
 func getCrashError(
 	block bool,
-	eventNotifier EventNotifier,
-	supRuntimeName string,
-	terminateCh <-chan error,
 	tm *terminationManager,
-	stopingTime time.Time,
 ) (bool, error) {
-
-	if terminatedVal, terminateErrVal := tm.getTerminateErr(); terminatedVal {
-		return terminatedVal, terminateErrVal
-	}
-
 	if block {
-		terminateErr := <-terminateCh
-		storeTerminationErr(
-			eventNotifier,
-			supRuntimeName,
-			tm,
-			terminateErr,
-			stopingTime,
-		)
-		return true, terminateErr
-	}
-
-	select {
-	case terminateErr := <-terminateCh:
-		storeTerminationErr(
-			eventNotifier,
-			supRuntimeName,
-			tm,
-			terminateErr,
-			time.Time{},
-		)
-		return true, terminateErr
-
-	default:
-		return false, nil
+		<-tm.doneCh
 	}
+	return tm.getTerminateErr()
 }
 
-// GetCrashError is a non-blocking function that returns a crash error if there
-// is one, the first parameter indicates if the supervisor is running or not. If
+// GetCrashError is a function that returns a crash error if there is one, the
+// first result indicates if the supervisor finished its execution or not. If
 // the returned error is not nil, the first result will always be true.
+//
+// When the block argument is true, this function blocks until the supervisor
+// finishes its execution, otherwise it returns immediately.
 func (sup Supervisor) GetCrashError(block bool) (bool, error) {
 	return getCrashError(
-		false, /* block */
-		sup.spec.eventNotifier,
-		sup.runtimeName,
-		sup.terminateCh,
+		block,
 		sup.terminateManager,
-		time.Time{},
 	)
 }
+
+// Done returns a channel that gets closed when the supervision tree finishes
+// its execution, either because it was terminated or because it crashed.
+//
+// This function allows to use a Supervisor on select statements, alongside
+// other channels (e.g. a context.Context):
+//
+//   select {
+//   case <-ctx.Done():
+//     // ...
+//   case <-sup.Done():
+//     // the supervision tree finished, check sup.Err() for failures
+//   }
+//
+func (sup Supervisor) Done() <-chan struct{} {
+	return sup.terminateManager.doneCh
+}
+
+// Err returns the error that made the supervision tree finish its execution.
+// It returns nil if the channel returned by Done is not closed yet, or when
+// the supervision tree was terminated without errors.
+func (sup Supervisor) Err() error {
+	_, terminateErr := sup.terminateManager.getTerminateErr()
+	return terminateErr
+}
+
+// GetLeakedNodes returns the nodes of the supervision tree that did not stop
+// within their shutdown timeout and are still running. A node stops being
+// reported once its goroutine finishes, which also emits an Event with an
+// EventTag of ProcessLeakFinished.
+//
+// A node that got restarted after it leaked may leak again, in which case it is
+// reported once per leak. The nodes of a finished supervision tree may still be
+// leaked, this method can be used to detect goroutine leaks after a call to
+// Terminate.
+func (sup Supervisor) GetLeakedNodes() []LeakedNode {
+	return sup.leakedNodes.list()
+}
+
+// GetRunningNodes returns the runtime names of the nodes of the supervision
+// tree that have started and have not finished yet, in lexicographical order.
+//
+// The running nodes are tracked from the events of the supervision tree, this
+// method reports the state of the tree at the moment of the call.
+func (sup Supervisor) GetRunningNodes() []string {
+	return sup.runningNodes.list()
+}
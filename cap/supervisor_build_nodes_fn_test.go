@@ -2,7 +2,7 @@ package cap_test
 
 //
 // NOTE: If you feel it is counter-intuitive to have workers start before
-// supervisors in the assertions bellow, check stest/README.md
+// supervisors in the assertions bellow, check internal/stest/README.md
 //
 
 import (
@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
 	. "github.com/capatazlib/go-capataz/internal/stest"
 )
 
@@ -0,0 +1,133 @@
+package cap_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+func TestTerminateContextWithStuckChild(t *testing.T) {
+	ctx := context.TODO()
+	evManager := NewEventManager()
+	evManager.StartCollector(ctx)
+
+	stuck, unblock := StuckWorker("child0", cap.WithShutdown(cap.Indefinitely))
+	defer unblock()
+
+	supSpec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(stuck, WaitDoneWorker("child1")),
+		cap.WithNotifier(evManager.EventCollector(ctx)),
+	)
+
+	sup, startErr := supSpec.Start(ctx)
+	assert.NoError(t, startErr)
+
+	evIt := evManager.Iterator()
+	evIt.SkipTill(SupervisorStarted("root"))
+
+	terminateCtx, cancelFn := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelFn()
+
+	err := sup.TerminateContext(terminateCtx)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	var pendingErr *cap.TerminationPendingError
+	if assert.True(t, errors.As(err, &pendingErr)) {
+		assert.Equal(t, "root", pendingErr.GetRuntimeName())
+		assert.Equal(t, []string{"root", "root/child0"}, pendingErr.GetPendingNodes())
+	}
+
+	// once the stuck child finishes, the termination finishes as well and the
+	// final result is still available
+	unblock()
+	assert.NoError(t, sup.Wait())
+	assert.NoError(t, sup.Terminate())
+
+	evIt.SkipTill(SupervisorTerminated("root"))
+
+	AssertExactMatch(t, evManager.Snapshot(),
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerStarted("root/child1"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child1"),
+			WorkerTerminated("root/child0"),
+			SupervisorTerminated("root"),
+		},
+	)
+}
+
+func TestWaitContextOnRunningSupervisor(t *testing.T) {
+	ctx := context.TODO()
+
+	supSpec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(WaitDoneWorker("child0"), WaitDoneWorker("child1")),
+	)
+
+	sup, startErr := supSpec.Start(ctx)
+	assert.NoError(t, startErr)
+
+	waitCtx, cancelFn := context.WithCancel(ctx)
+	cancelFn()
+
+	err := sup.WaitContext(waitCtx)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	var pendingErr *cap.TerminationPendingError
+	if assert.True(t, errors.As(err, &pendingErr)) {
+		assert.Equal(
+			t,
+			[]string{"root", "root/child0", "root/child1"},
+			pendingErr.GetPendingNodes(),
+		)
+	}
+
+	// the supervisor is not affected by the expired wait
+	crashed, crashErr := sup.GetCrashError(false)
+	assert.False(t, crashed)
+	assert.NoError(t, crashErr)
+
+	assert.NoError(t, sup.Terminate())
+}
+
+func TestWaitContextKeepsTerminationError(t *testing.T) {
+	ctx := context.TODO()
+
+	supSpec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(
+			FailTerminationWorker("child0", errors.New("child0 failed")),
+		),
+	)
+
+	sup, startErr := supSpec.Start(ctx)
+	assert.NoError(t, startErr)
+
+	waitCtx, cancelFn := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelFn()
+
+	errCh := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			errCh <- sup.WaitContext(waitCtx)
+		}()
+	}
+
+	terminateErr := sup.TerminateContext(waitCtx)
+	assert.Error(t, terminateErr)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, terminateErr, <-errCh)
+	}
+	assert.Equal(t, terminateErr, sup.Wait())
+}
@@ -0,0 +1,170 @@
+package cap_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// drainLog keeps track of the order in which workers get drained and
+// terminated
+type drainLog struct {
+	mux     sync.Mutex
+	entries []string
+}
+
+func (dl *drainLog) add(entry string) {
+	dl.mux.Lock()
+	defer dl.mux.Unlock()
+	dl.entries = append(dl.entries, entry)
+}
+
+func (dl *drainLog) get() []string {
+	dl.mux.Lock()
+	defer dl.mux.Unlock()
+	return append(dl.entries[:0:0], dl.entries...)
+}
+
+func drainWorker(name string, dl *drainLog) cap.Node {
+	return cap.NewWorkerWithDrain(
+		name,
+		func(ctx context.Context, notifyStart cap.NotifyStartFn, drainCh cap.DrainSignal) error {
+			notifyStart(nil)
+			select {
+			case <-drainCh:
+				dl.add(name + " drained")
+			case <-ctx.Done():
+				dl.add(name + " cancelled without drain")
+				return nil
+			}
+			<-ctx.Done()
+			dl.add(name + " cancelled")
+			return nil
+		},
+	)
+}
+
+func TestDrainBeforeTermination(t *testing.T) {
+	dl := &drainLog{}
+
+	b0 := cap.NewSupervisorSpec(
+		"branch0",
+		cap.WithNodes(drainWorker("child0", dl), drainWorker("child1", dl)),
+	)
+
+	start := time.Now()
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			cap.Subtree(b0),
+			drainWorker("child2", dl),
+		),
+		[]cap.Opt{
+			cap.WithDrainTimeout(50 * time.Millisecond),
+		},
+		func(EventManager) {},
+	)
+	elapsed := time.Since(start)
+
+	assert.NoError(t, err)
+	assert.True(t, elapsed >= 50*time.Millisecond)
+
+	entries := dl.get()
+	if assert.Len(t, entries, 6) {
+		// all workers get drained before any of them gets cancelled
+		assert.ElementsMatch(
+			t,
+			[]string{"child0 drained", "child1 drained", "child2 drained"},
+			entries[:3],
+		)
+		// the cancellation happens in the regular termination order
+		assert.Equal(
+			t,
+			[]string{"child2 cancelled", "child1 cancelled", "child0 cancelled"},
+			entries[3:],
+		)
+	}
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/branch0/child0"),
+			WorkerStarted("root/branch0/child1"),
+			SupervisorStarted("root/branch0"),
+			WorkerStarted("root/child2"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child2"),
+			WorkerTerminated("root/branch0/child1"),
+			WorkerTerminated("root/branch0/child0"),
+			SupervisorTerminated("root/branch0"),
+			SupervisorTerminated("root"),
+		})
+}
+
+func TestNoDrainByDefault(t *testing.T) {
+	dl := &drainLog{}
+
+	_, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(drainWorker("child0", dl)),
+		[]cap.Opt{},
+		func(EventManager) {},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"child0 cancelled without drain"}, dl.get())
+}
+
+func TestDrainFinishesWhenChildrenFinish(t *testing.T) {
+	// the worker finishes as soon as it gets drained
+	child0 := cap.NewWorkerWithDrain(
+		"child0",
+		func(ctx context.Context, notifyStart cap.NotifyStartFn, drainCh cap.DrainSignal) error {
+			notifyStart(nil)
+			select {
+			case <-drainCh:
+			case <-ctx.Done():
+			}
+			return nil
+		},
+	)
+
+	start := time.Now()
+	_, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(child0),
+		[]cap.Opt{cap.WithDrainTimeout(time.Minute)},
+		func(EventManager) {},
+	)
+
+	assert.NoError(t, err)
+	// the supervisor does not wait the whole drain period
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestNoDrainOnStartFailure(t *testing.T) {
+	dl := &drainLog{}
+
+	start := time.Now()
+	_, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(drainWorker("child0", dl), FailStartWorker("child1")),
+		[]cap.Opt{cap.WithDrainTimeout(time.Minute)},
+		func(EventManager) {},
+	)
+
+	assert.Error(t, err)
+	// the started worker is terminated right away
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.Equal(t, []string{"child0 cancelled without drain"}, dl.get())
+}
@@ -1,5 +1,10 @@
 package cap
 
+import (
+	"fmt"
+	"time"
+)
+
 // Opt is a type used to configure a SupervisorSpec
 type Opt func(*SupervisorSpec)
 
@@ -51,12 +56,173 @@ func WithStrategy(s Strategy) Opt {
 // the systems, and it is a great place to hook in monitoring services like
 // logging, error tracing and metrics gatherers
 //
+// * Warning
+//
+// The callback may be called after the root supervisor finished: the Event
+// with an EventTag of ProcessLeakFinished is reported whenever the goroutine of
+// a node that did not stop in time finishes. The callback must not assume the
+// resources it uses are alive after the termination of the supervisor (e.g.
+// it must not send to a closed channel).
+//
 func WithNotifier(en EventNotifier) Opt {
 	return func(spec *SupervisorSpec) {
 		spec.eventNotifier = en
 	}
 }
 
+// WithShutdownTimeout is an Opt that specifies the maximum amount of time a
+// supervisor is going to wait for all its children nodes to terminate.
+//
+// Children are terminated in the order specified with WithStartOrder; each
+// child is given the time specified in its WithShutdown setting, bounded by
+// the time left before the deadline. When the deadline is reached, the
+// supervisor stops waiting for the node that is still running and terminates
+// the remaining siblings in order, giving each of them a short grace period to
+// stop. Sub-trees get the same deadline for the termination of their own
+// children (unless their own WithShutdownTimeout is shorter).
+//
+// The nodes that did not stop in time (including the ones of sub-trees) are
+// reported in a ShutdownDeadlineError, which is the cause of the termination
+// SupervisorError; the supervisor also emits an Event with an EventTag of
+// ProcessShutdownDeadlineReached.
+//
+// By default, supervisors do not have a shutdown deadline.
+//
+// * Warning
+//
+// Like it happens with the Timeout shutdown value, nodes that did not stop in
+// time may leave goroutines running in memory (e.g. memory leak).
+//
+func WithShutdownTimeout(d time.Duration) Opt {
+	return func(spec *SupervisorSpec) {
+		spec.shutdownTimeout = d
+	}
+}
+
+// WithDrainTimeout is an Opt that enables a two-phase termination of the
+// supervisor's children nodes.
+//
+// On the first phase, all children nodes get notified that they must stop
+// accepting new work, worker nodes receive this notification via the
+// DrainSignal given to them (check NewWorkerWithDrain), and sub-tree nodes
+// forward the notification to their own children. Then, the supervisor waits
+// for the given duration, or until all children nodes finished.
+//
+// On the second phase, the regular termination happens; the children nodes
+// get their context cancelled in the order specified with WithStartOrder.
+//
+// When the supervisor also has a WithShutdownTimeout setting, the drain
+// period is part of the time given to the termination. Children that started
+// are not drained when the start of the supervisor fails.
+//
+func WithDrainTimeout(d time.Duration) Opt {
+	return func(spec *SupervisorSpec) {
+		spec.drainTimeout = d
+	}
+}
+
+// WithoutPprofLabels is an Opt that disables the pprof labels on the
+// goroutines of the supervisor's children nodes.
+//
+// By default, every node of a supervision tree runs inside runtime/pprof.Do
+// with the following labels:
+//
+// * capataz.runtime_name -- the runtime name of the node (e.g. root/api/db)
+//
+// * capataz.node_tag -- either Worker or Supervisor
+//
+// * capataz.restart_count -- the number of times the node has been restarted
+// because of errors
+//
+// These labels are inherited by the goroutines spawned from a node, which
+// allows to slice goroutine dumps and CPU profiles by supervision tree path.
+//
+// These labels are also used to get the stack dump of a node that does not
+// stop within its shutdown timeout (check ShutdownTimeoutError), nodes without
+// labels report an empty stack dump.
+//
+// This setting does not propagate to sub-trees; the goroutines of a sub-tree
+// that has labels enabled still get their own labels.
+//
+func WithoutPprofLabels() Opt {
+	return func(spec *SupervisorSpec) {
+		spec.noPprofLabels = true
+	}
+}
+
+// WithParallelStart is an Opt that allows the supervisor to start up to the
+// given number of children nodes at the same time.
+//
+// Children that do not depend on each other (check WithDependsOn) are started
+// in parallel, a child is started only after all its dependencies started. In
+// case any child fails to start, the children that were not started yet are
+// never started, and the ones that got started are terminated, like it
+// happens with a sequential start. When many children fail to start at the
+// same time, the error of the first one (as specified with WithStartOrder) is
+// the cause of the returned SupervisorError, and the errors of all of them are
+// reported per child (check GetNodeErrors).
+//
+// A maxConcurrency of one is the same as the default sequential start. The
+// given maxConcurrency must be greater than zero, otherwise, the system will
+// panic.
+//
+// * Warning
+//
+// The EventNotifier given with WithNotifier is called from multiple goroutines
+// at the same time when this setting is used.
+//
+func WithParallelStart(maxConcurrency int) Opt {
+	if maxConcurrency < 1 {
+		panic(fmt.Sprintf("invalid parallel start concurrency %d", maxConcurrency))
+	}
+	return func(spec *SupervisorSpec) {
+		spec.startConcurrency = maxConcurrency
+	}
+}
+
+// WithParallelTermination is an Opt that allows the supervisor to terminate
+// its children nodes at the same time.
+//
+// Children that do not depend on each other (check WithDependsOn) are
+// terminated in parallel, a child is terminated only after all the children
+// that depend on it stopped. The termination errors of each child are
+// reported in the returned SupervisorError, like it happens with a sequential
+// termination.
+//
+// When the supervisor also has a WithShutdownTimeout setting, all the children
+// terminated at the same time share the same deadline.
+//
+// * Warning
+//
+// The EventNotifier given with WithNotifier is called from multiple goroutines
+// at the same time when this setting is used.
+//
+func WithParallelTermination() Opt {
+	return func(spec *SupervisorSpec) {
+		spec.parallelTermination = true
+	}
+}
+
+// WithClock is an Opt that specifies the Clock a supervisor uses to measure
+// time. The given Clock is used by the error tolerance windows, the shutdown
+// timeouts, the drain timeout and the creation time of events.
+//
+// Sub-trees that do not have a Clock of their own use the Clock of their
+// parent supervisor.
+//
+// This setting is meant for tests, it allows the use of a fake clock (e.g.
+// captest.FakeClock) to assert time-based behaviors without real delays. By
+// default, supervisors use the SystemClock.
+//
+func WithClock(clock Clock) Opt {
+	if clock == nil {
+		panic("invalid nil clock")
+	}
+	return func(spec *SupervisorSpec) {
+		spec.clock = clock
+	}
+}
+
 // WithNodes allows the registration of child nodes in a SupervisorSpec. Node
 // records passed to this function are going to be supervised by the Supervisor
 // created from a SupervisorSpec.
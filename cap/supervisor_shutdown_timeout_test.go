@@ -0,0 +1,197 @@
+package cap_test
+
+//
+// NOTE: If you feel it is counter-intuitive to have workers start before
+// supervisors in the assertions bellow, check internal/stest/README.md
+//
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+func TestShutdownTimeoutOnOneLevelTree(t *testing.T) {
+	stuck, unblock := StuckWorker("child0", cap.WithShutdown(cap.Indefinitely))
+	defer unblock()
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			stuck,
+			WaitDoneWorker("child1"),
+			WaitDoneWorker("child2"),
+		),
+		[]cap.Opt{
+			cap.WithShutdownTimeout(50 * time.Millisecond),
+		},
+		func(EventManager) {},
+	)
+
+	assert.Error(t, err)
+
+	var deadlineErr *cap.ShutdownDeadlineError
+	if assert.True(t, errors.As(err, &deadlineErr)) {
+		assert.Equal(t, "root", deadlineErr.GetRuntimeName())
+		assert.Equal(t, []string{"root/child0"}, deadlineErr.GetPendingNodes())
+	}
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerStarted("root/child1"),
+			WorkerStarted("root/child2"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child2"),
+			WorkerTerminated("root/child1"),
+			// NOTE: child0 never stops, and the supervisor gives up on it once
+			// the deadline is reached
+			WorkerFailed("root/child0"),
+			SupervisorShutdownDeadlineReached("root"),
+			SupervisorFailed("root"),
+		})
+}
+
+func TestShutdownTimeoutOnNestedTree(t *testing.T) {
+	stuck, unblock := StuckWorker("child1", cap.WithShutdown(cap.Indefinitely))
+	defer unblock()
+
+	b0 := cap.NewSupervisorSpec(
+		"branch0",
+		cap.WithNodes(WaitDoneWorker("child0")),
+	)
+	b1 := cap.NewSupervisorSpec(
+		"branch1",
+		cap.WithNodes(stuck, WaitDoneWorker("child2")),
+		cap.WithShutdownTimeout(50*time.Millisecond),
+	)
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			cap.Subtree(b0),
+			cap.Subtree(b1),
+		),
+		[]cap.Opt{},
+		func(EventManager) {},
+	)
+
+	assert.Error(t, err)
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/branch0/child0"),
+			SupervisorStarted("root/branch0"),
+			WorkerStarted("root/branch1/child1"),
+			WorkerStarted("root/branch1/child2"),
+			SupervisorStarted("root/branch1"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/branch1/child2"),
+			// NOTE: child1 never stops, branch1 gives up on it once the deadline is
+			// reached and it reports the failure to its parent
+			WorkerFailed("root/branch1/child1"),
+			SupervisorShutdownDeadlineReached("root/branch1"),
+			SupervisorFailed("root/branch1"),
+			// NOTE: the termination of the remaining nodes continues as usual
+			WorkerTerminated("root/branch0/child0"),
+			SupervisorTerminated("root/branch0"),
+			SupervisorFailed("root"),
+		})
+}
+
+func TestShutdownTimeoutBoundsSubtrees(t *testing.T) {
+	stuck, unblock := StuckWorker("child0", cap.WithShutdown(cap.Indefinitely))
+	defer unblock()
+
+	b0 := cap.NewSupervisorSpec("branch0", cap.WithNodes(stuck))
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			cap.Subtree(b0),
+			WaitDoneWorker("child1"),
+		),
+		[]cap.Opt{
+			// NOTE: the subtree does not have a shutdown timeout, the deadline of
+			// the root supervisor is the one that bounds its termination
+			cap.WithShutdownTimeout(50 * time.Millisecond),
+		},
+		func(EventManager) {},
+	)
+
+	assert.Error(t, err)
+
+	var deadlineErr *cap.ShutdownDeadlineError
+	if assert.True(t, errors.As(err, &deadlineErr)) {
+		// the sub-tree reports the node that is stuck
+		assert.Equal(
+			t,
+			[]string{"root/branch0", "root/branch0/child0"},
+			deadlineErr.GetPendingNodes(),
+		)
+	}
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/branch0/child0"),
+			SupervisorStarted("root/branch0"),
+			WorkerStarted("root/child1"),
+			SupervisorStarted("root"),
+			WorkerTerminated("root/child1"),
+			// NOTE: the sub-tree gets the deadline of the root supervisor
+			WorkerFailed("root/branch0/child0"),
+			SupervisorShutdownDeadlineReached("root/branch0"),
+			SupervisorFailed("root/branch0"),
+			SupervisorShutdownDeadlineReached("root"),
+			SupervisorFailed("root"),
+		})
+}
+
+func TestShutdownTimeoutGivesGraceToRemainingNodes(t *testing.T) {
+	stuck, unblock := StuckWorker("child1", cap.WithShutdown(cap.Indefinitely))
+	defer unblock()
+
+	// this worker needs some time to stop, it is terminated after the deadline
+	slowStop := cap.NewWorker("child0", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	events, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(slowStop, stuck),
+		[]cap.Opt{
+			cap.WithShutdownTimeout(50 * time.Millisecond),
+		},
+		func(EventManager) {},
+	)
+
+	var deadlineErr *cap.ShutdownDeadlineError
+	if assert.True(t, errors.As(err, &deadlineErr)) {
+		assert.Equal(t, []string{"root/child1"}, deadlineErr.GetPendingNodes())
+	}
+
+	AssertExactMatch(t, events,
+		[]EventP{
+			WorkerStarted("root/child0"),
+			WorkerStarted("root/child1"),
+			SupervisorStarted("root"),
+			WorkerFailed("root/child1"),
+			// NOTE: the remaining sibling still gets time to stop
+			WorkerTerminated("root/child0"),
+			SupervisorShutdownDeadlineReached("root"),
+			SupervisorFailed("root"),
+		})
+}
@@ -2,7 +2,7 @@ package cap_test
 
 //
 // NOTE: If you feel it is counter-intuitive to have workers start before
-// supervisors in the assertions bellow, check stest/README.md
+// supervisors in the assertions bellow, check internal/stest/README.md
 //
 
 import (
@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
 	. "github.com/capatazlib/go-capataz/internal/stest"
 )
 
@@ -343,3 +344,88 @@ func TestFailedTerminationOnOneLevelTree(t *testing.T) {
 			})
 	})
 }
+
+func TestGetCrashErrorBlocksUntilTermination(t *testing.T) {
+	ctx := context.TODO()
+
+	supSpec := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(
+			FailTerminationWorker("child0", fmt.Errorf("child0 failed")),
+		),
+	)
+
+	sup, startErr := supSpec.Start(ctx)
+	assert.NoError(t, startErr)
+
+	crashed, crashErr := sup.GetCrashError(false)
+	assert.False(t, crashed)
+	assert.NoError(t, crashErr)
+
+	go func() {
+		_ = sup.Terminate()
+	}()
+
+	crashed, crashErr = sup.GetCrashError(true)
+	assert.True(t, crashed)
+	assert.Error(t, crashErr)
+}
+
+func TestDoneAndErr(t *testing.T) {
+	t.Run("on successful termination", func(t *testing.T) {
+		ctx := context.TODO()
+
+		supSpec := cap.NewSupervisorSpec("root", cap.WithNodes(WaitDoneWorker("child0")))
+		sup, startErr := supSpec.Start(ctx)
+		assert.NoError(t, startErr)
+
+		select {
+		case <-sup.Done():
+			t.Fatal("supervisor is done before termination")
+		default:
+		}
+		assert.NoError(t, sup.Err())
+
+		assert.NoError(t, sup.Terminate())
+
+		<-sup.Done()
+		assert.NoError(t, sup.Err())
+	})
+
+	t.Run("on failed termination", func(t *testing.T) {
+		ctx := context.TODO()
+
+		supSpec := cap.NewSupervisorSpec(
+			"root",
+			cap.WithNodes(
+				FailTerminationWorker("child0", fmt.Errorf("child0 failed")),
+			),
+		)
+		sup, startErr := supSpec.Start(ctx)
+		assert.NoError(t, startErr)
+
+		go func() {
+			_ = sup.Terminate()
+		}()
+
+		<-sup.Done()
+		assert.Error(t, sup.Err())
+		assert.Equal(t, sup.Err(), sup.Wait())
+	})
+
+	t.Run("on dynamic supervisor", func(t *testing.T) {
+		ctx, cancelFn := context.WithCancel(context.TODO())
+
+		dyn, startErr := cap.NewDynSupervisor(ctx, "root")
+		assert.NoError(t, startErr)
+
+		_, spawnErr := dyn.Spawn(WaitDoneWorker("child0"))
+		assert.NoError(t, spawnErr)
+
+		// cancelling the parent context terminates the supervision tree
+		cancelFn()
+
+		<-dyn.Done()
+		assert.NoError(t, dyn.Err())
+	})
+}
@@ -0,0 +1,110 @@
+package cap_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	. "github.com/capatazlib/go-capataz/cap/captest"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// reasonWorker creates a worker that reports its termination reason on the
+// given channel once its context is done
+func reasonWorker(
+	name string,
+	reasonCh chan<- cap.TerminationReason,
+	opts ...cap.WorkerOpt,
+) cap.Node {
+	return cap.NewWorker(name, func(ctx context.Context) error {
+		<-ctx.Done()
+		reasonCh <- cap.GetTerminationReason(ctx)
+		return nil
+	}, opts...)
+}
+
+func TestTerminationReasonOnShutdown(t *testing.T) {
+	reasonCh := make(chan cap.TerminationReason, 2)
+
+	b0 := cap.NewSupervisorSpec("branch0", cap.WithNodes(reasonWorker("child0", reasonCh)))
+
+	_, err := ObserveSupervisor(
+		context.TODO(),
+		"root",
+		cap.WithNodes(
+			cap.Subtree(b0),
+			reasonWorker("child1", reasonCh),
+		),
+		[]cap.Opt{},
+		func(EventManager) {},
+	)
+
+	assert.NoError(t, err)
+	// NOTE: the worker on the sub-tree receives the reason of its parent
+	assert.Equal(t, cap.ShutdownReason, <-reasonCh)
+	assert.Equal(t, cap.ShutdownReason, <-reasonCh)
+}
+
+func TestTerminationReasonOnCancel(t *testing.T) {
+	reasonCh := make(chan cap.TerminationReason, 1)
+
+	dyn, err := cap.NewDynSupervisor(context.TODO(), "root")
+	assert.NoError(t, err)
+
+	cancelWorker, err := dyn.Spawn(reasonWorker("child0", reasonCh))
+	assert.NoError(t, err)
+
+	assert.NoError(t, cancelWorker())
+	assert.Equal(t, cap.CancelReason, <-reasonCh)
+
+	assert.NoError(t, dyn.Terminate())
+}
+
+func TestTerminationReasonOnParentFailure(t *testing.T) {
+	t.Run("when a sibling surpasses its error tolerance", func(t *testing.T) {
+		reasonCh := make(chan cap.TerminationReason, 1)
+
+		failingChild, failChild := FailOnSignalWorker(
+			1,
+			"child1",
+			cap.WithTolerance(0, 5*time.Second),
+		)
+
+		_, err := ObserveSupervisor(
+			context.TODO(),
+			"root",
+			cap.WithNodes(reasonWorker("child0", reasonCh), failingChild),
+			[]cap.Opt{},
+			func(em EventManager) {
+				evIt := em.Iterator()
+				failChild(true /* done */)
+				evIt.SkipTill(SupervisorFailed("root"))
+			},
+		)
+
+		assert.Error(t, err)
+		assert.Equal(t, cap.ParentFailureReason, <-reasonCh)
+	})
+
+	t.Run("when a sibling fails to start", func(t *testing.T) {
+		reasonCh := make(chan cap.TerminationReason, 1)
+
+		_, err := ObserveSupervisor(
+			context.TODO(),
+			"root",
+			cap.WithNodes(reasonWorker("child0", reasonCh), FailStartWorker("child1")),
+			[]cap.Opt{},
+			func(EventManager) {},
+		)
+
+		assert.Error(t, err)
+		assert.Equal(t, cap.ParentFailureReason, <-reasonCh)
+	})
+}
+
+func TestTerminationReasonOutsideOfWorker(t *testing.T) {
+	assert.Equal(t, cap.UnknownReason, cap.GetTerminationReason(context.TODO()))
+}
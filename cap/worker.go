@@ -14,6 +14,61 @@ import (
 // See the documentation of NewWorkerWithNotifyStart for more details
 type NotifyStartFn = c.NotifyStartFn
 
+// DrainSignal is a channel given to worker nodes that gets closed when the
+// parent supervisor starts a graceful termination.
+//
+// See the documentation of NewWorkerWithDrain for more details
+type DrainSignal = <-chan struct{}
+
+// TerminationReason specifies why a supervisor terminated one of its nodes.
+// Worker nodes may use the GetTerminationReason function to get it once their
+// context is done.
+type TerminationReason = c.TerminationReason
+
+// UnknownReason is a TerminationReason that indicates the node has not been
+// terminated by its supervisor
+var UnknownReason = c.UnknownReason
+
+// ShutdownReason is a TerminationReason that indicates the node got terminated
+// because the supervision tree is shutting down
+var ShutdownReason = c.ShutdownReason
+
+// RestartReason is a TerminationReason that indicates the node got terminated
+// so that it can be started again
+var RestartReason = c.RestartReason
+
+// CancelReason is a TerminationReason that indicates the node got terminated
+// via an explicit cancel request (e.g. the cancel callback returned by
+// DynSupervisor.Spawn)
+var CancelReason = c.CancelReason
+
+// ParentFailureReason is a TerminationReason that indicates the node got
+// terminated because its supervisor failed (e.g. a sibling surpassed its error
+// tolerance, or a sibling failed to start)
+var ParentFailureReason = c.ParentFailureReason
+
+// GetTerminationReason returns the reason why the worker running with the given
+// context got terminated by its supervisor. When a sub-tree gets terminated,
+// its reason is forwarded to all its descendants.
+//
+// This function is meant to be called after the context is done, it allows
+// workers to decide what to do before they finish (e.g. flush state to disk on
+// a ShutdownReason, or hand-off work on a RestartReason).
+//
+// Example:
+//
+//   cap.NewWorker("writer", func(ctx context.Context) error {
+//     <-ctx.Done()
+//     if cap.GetTerminationReason(ctx) == cap.ShutdownReason {
+//       return flushToDisk()
+//     }
+//     return nil
+//   })
+//
+func GetTerminationReason(ctx context.Context) TerminationReason {
+	return c.GetTerminationReason(ctx)
+}
+
 // childToNode transforms a c.ChildSpec into a Node.
 func childToNode(chSpec c.ChildSpec) Node {
 	return func(_ SupervisorSpec) c.ChildSpec {
@@ -79,3 +134,35 @@ func NewWorkerWithNotifyStart(
 ) Node {
 	return childToNode(c.NewWithNotifyStart(name, startFn, opts...))
 }
+
+// NewWorkerWithDrain accomplishes the same goal as NewWorkerWithNotifyStart
+// with the addition of passing an extra argument (drain signal) to the startFn
+// function parameter.
+//
+// The DrainSignal argument
+//
+// Some workers (e.g. HTTP servers, queue consumers) need to stop accepting new
+// work before they get terminated, so that the work they have in progress can
+// finish. The DrainSignal is a channel that gets closed when the parent
+// supervisor starts a graceful termination (check the WithDrainTimeout
+// supervisor option); once closed, the worker should stop accepting new work,
+// and wait for the given context.Context to be done.
+//
+// When the parent supervisor does not have a WithDrainTimeout setting, the
+// DrainSignal is never closed.
+//
+func NewWorkerWithDrain(
+	name string,
+	startFn func(context.Context, NotifyStartFn, DrainSignal) error,
+	opts ...WorkerOpt,
+) Node {
+	return childToNode(
+		c.NewWithNotifyStart(
+			name,
+			func(ctx context.Context, notifyStart c.NotifyStartFn) error {
+				return startFn(ctx, notifyStart, c.DrainSignal(ctx))
+			},
+			opts...,
+		),
+	)
+}
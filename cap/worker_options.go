@@ -55,6 +55,16 @@ var Indefinitely = c.Indefinitely
 // leaving the goroutine running in memory (e.g. memory leak)
 var Timeout = c.Timeout
 
+// Clock is the source of time of a supervision tree; it is used for error
+// tolerance windows, shutdown timeouts and the creation time of events.
+//
+// Check the documentation of WithClock for more details
+type Clock = c.Clock
+
+// SystemClock is the Clock supervisors use by default, it reports the time of
+// the operating system
+var SystemClock = c.SystemClock
+
 // NodeTag specifies the type of node that is running. This is a closed set
 // given we will only support workers and supervisors
 type NodeTag = c.ChildTag
@@ -100,6 +110,43 @@ var WithShutdown = c.WithShutdown
 // this worker should be treated as errors.
 var WithCapturePanic = c.WithCapturePanic
 
+// WithDependsOn is a WorkerOpt that specifies the names of the sibling nodes
+// this node depends on. This option may be used with both workers and
+// sub-trees (check Subtree).
+//
+// The parent supervisor uses the dependencies of its children nodes to:
+//
+// * Start a node only after all its dependencies have started; nodes without
+// dependencies between them are started in the order specified with
+// WithStartOrder
+//
+// * Stop a node before any of its dependencies get stopped (e.g. the reverse
+// of the start order)
+//
+// * Restart the nodes that depend on a node (directly or transitively) when
+// this one is restarted; the dependent nodes are stopped before the restart
+// of the node, and started again once the node is running. The restart of a
+// dependent node does not count against its error tolerance, and the node
+// receives a RestartReason termination reason.
+//
+// The dependencies must be names of sibling nodes, and they must not have
+// cycles, otherwise, the supervisor start fails with an UnknownDependencyError
+// or DependencyCycleError.
+//
+// Example:
+//
+//   // The http server depends on the cache and the db pool, but those two are
+//   // independent
+//   cap.NewSupervisorSpec("root",
+//     cap.WithNodes(
+//       cap.NewWorker("http-server", runServer, cap.WithDependsOn("cache", "db-pool")),
+//       cap.NewWorker("cache", runCache),
+//       cap.NewWorker("db-pool", runDBPool),
+//     ),
+//   )
+//
+var WithDependsOn = c.WithDependsOn
+
 // WithTag is a WorkerOpt that sets the given NodeTag on Worker.
 //
 // Do not use this function if you are not extending capataz' API.
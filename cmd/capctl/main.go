@@ -0,0 +1,247 @@
+// capctl inspects and operates a running supervision tree that serves its
+// tree on a unix socket (check admin.WithUnixSocket).
+//
+// Usage:
+//
+//   capctl [-socket path] tree
+//   capctl [-socket path] events [-limit N] [-follow]
+//   capctl [-socket path] health
+//   capctl [-socket path] restart <runtime-name>
+//   capctl [-socket path] terminate <runtime-name>
+//
+// The socket path may also be given with the CAPCTL_SOCKET environment
+// variable.
+//
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/capatazlib/go-capataz/cap/admin"
+)
+
+// socketEnvVar is the environment variable with the default socket path
+const socketEnvVar = "CAPCTL_SOCKET"
+
+// errUnhealthy is returned by the health command when the tree is unhealthy
+var errUnhealthy = errors.New("supervision tree is unhealthy")
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a followed events command runs until the user interrupts it
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "capctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run executes the command given on the arguments, and writes its output on
+// the given writer
+func run(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("capctl", flag.ContinueOnError)
+	socketPath := flags.String("socket", os.Getenv(socketEnvVar), "path of the unix socket of the supervision tree")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *socketPath == "" {
+		return fmt.Errorf("missing socket path, use -socket or %s", socketEnvVar)
+	}
+	if flags.NArg() == 0 {
+		return errors.New("missing command (tree, events, health, restart or terminate)")
+	}
+
+	command, cmdArgs := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case admin.TreeCommand:
+		return runTree(ctx, *socketPath, out)
+	case admin.EventsCommand:
+		return runEvents(ctx, *socketPath, cmdArgs, out)
+	case admin.HealthCommand:
+		return runHealth(ctx, *socketPath, out)
+	case admin.RestartCommand, admin.TerminateCommand:
+		return runNodeCommand(ctx, *socketPath, command, cmdArgs, out)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// runTree writes the supervision tree
+func runTree(ctx context.Context, socketPath string, out io.Writer) error {
+	return admin.SocketRequest(
+		ctx,
+		socketPath,
+		admin.Request{Command: admin.TreeCommand},
+		func(resp admin.Response) error {
+			if resp.Tree == nil {
+				return errors.New("response without tree")
+			}
+			var builder strings.Builder
+			builder.WriteString(fmt.Sprintf("%s %s\n", nodeMarker(*resp.Tree), nodeLabel(*resp.Tree)))
+			renderChildren(&builder, *resp.Tree, "")
+			_, err := io.WriteString(out, builder.String())
+			return err
+		},
+	)
+}
+
+// nodeMarker returns the marker of the node on the tree, `+` for supervisors
+// and "`" for workers
+func nodeMarker(node admin.Node) string {
+	if node.Tag == "Supervisor" {
+		return "+"
+	}
+	return "`"
+}
+
+// nodeLabel returns the text of the node on the tree
+func nodeLabel(node admin.Node) string {
+	label := fmt.Sprintf("%s [%s, %s", node.Name, node.Status, node.Health)
+	if node.Restarts > 0 {
+		label = fmt.Sprintf("%s, restarts: %d", label, node.Restarts)
+	}
+	return label + "]"
+}
+
+// renderChildren writes the children of the node on the given builder, every
+// line starts with the given prefix
+func renderChildren(builder *strings.Builder, node admin.Node, prefix string) {
+	for i, child := range node.Children {
+		childPrefix := prefix + "| "
+		if i == len(node.Children)-1 {
+			childPrefix = prefix + "  "
+		}
+		builder.WriteString(prefix + "|\n")
+		builder.WriteString(fmt.Sprintf("%s%s %s\n", prefix, nodeMarker(child), nodeLabel(child)))
+		renderChildren(builder, child, childPrefix)
+	}
+}
+
+// runEvents writes the most recent events, and the new ones when the -follow
+// flag is given
+func runEvents(ctx context.Context, socketPath string, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("capctl events", flag.ContinueOnError)
+	limit := flags.Int("limit", 0, "maximum number of recent events (0 for all of them)")
+	follow := flags.Bool("follow", false, "keep writing the new events")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	err := admin.SocketRequest(
+		ctx,
+		socketPath,
+		admin.Request{Command: admin.EventsCommand, Limit: *limit, Follow: *follow},
+		func(resp admin.Response) error {
+			if resp.Event == nil {
+				return errors.New("response without event")
+			}
+			_, err := fmt.Fprintln(out, eventLine(*resp.Event))
+			return err
+		},
+	)
+	// a followed events command stops when the user interrupts it
+	if *follow && errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// eventLine returns the text of the event
+func eventLine(ev admin.Event) string {
+	line := fmt.Sprintf(
+		"%s %s %s %s",
+		ev.Created.Format(time.RFC3339Nano),
+		ev.Tag,
+		ev.NodeTag,
+		ev.RuntimeName,
+	)
+	if ev.Error != "" {
+		line = fmt.Sprintf("%s err=%q", line, ev.Error)
+	}
+	return line
+}
+
+// runHealth writes the health of the tree, it fails when the tree is unhealthy
+func runHealth(ctx context.Context, socketPath string, out io.Writer) error {
+	var health admin.Health
+	err := admin.SocketRequest(
+		ctx,
+		socketPath,
+		admin.Request{Command: admin.HealthCommand},
+		func(resp admin.Response) error {
+			if resp.Health == nil {
+				return errors.New("response without health")
+			}
+			health = *resp.Health
+			return nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if health.Healthy {
+		fmt.Fprintln(out, "healthy")
+	} else {
+		fmt.Fprintln(out, "unhealthy")
+	}
+	for _, name := range health.FailedNodes {
+		fmt.Fprintf(out, "failed: %s\n", name)
+	}
+	for _, name := range health.DelayedRestartNodes {
+		fmt.Fprintf(out, "delayed restart: %s\n", name)
+	}
+	names := make([]string, 0, len(health.Restarts))
+	for name := range health.Restarts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "restarts: %s %d\n", name, health.Restarts[name])
+	}
+
+	if !health.Healthy {
+		return errUnhealthy
+	}
+	return nil
+}
+
+// runNodeCommand restarts or terminates the node given on the arguments
+func runNodeCommand(
+	ctx context.Context,
+	socketPath string,
+	command string,
+	args []string,
+	out io.Writer,
+) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%s expects the runtime name of a node (e.g. root/api/listener)", command)
+	}
+	return admin.SocketRequest(
+		ctx,
+		socketPath,
+		admin.Request{Command: command, Node: args[0]},
+		func(resp admin.Response) error {
+			_, err := fmt.Fprintf(out, "%s: %s\n", command, resp.Node)
+			return err
+		},
+	)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/capatazlib/go-capataz/cap"
+	"github.com/capatazlib/go-capataz/cap/admin"
+	. "github.com/capatazlib/go-capataz/internal/stest"
+)
+
+// startSupervisor starts a supervision tree that serves its tree on a unix
+// socket, it returns the socket path and a function that stops the tree
+func startSupervisor(t *testing.T, opts ...admin.Opt) (string, func()) {
+	dir, err := ioutil.TempDir("", "capctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socketPath := filepath.Join(dir, "capctl.sock")
+
+	rec := admin.NewRecorder()
+	b0 := cap.NewSupervisorSpec("net", cap.WithNodes(WaitDoneWorker("listener")))
+	sup, err := cap.NewSupervisorSpec(
+		"root",
+		cap.WithNodes(cap.Subtree(b0), WaitDoneWorker("cache")),
+		cap.WithNotifier(rec.HandleEvent),
+		admin.WithUnixSocket(socketPath, rec, opts...),
+	).Start(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return socketPath, func() {
+		_ = sup.Terminate()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestTree(t *testing.T) {
+	socketPath, stop := startSupervisor(t)
+	defer stop()
+
+	var out bytes.Buffer
+	err := run(context.TODO(), []string{"-socket", socketPath, "tree"}, &out)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		strings.Join(
+			[]string{
+				"+ root [Running, Healthy]",
+				"|",
+				"+ net [Running, Healthy]",
+				"| |",
+				"| ` listener [Running, Healthy]",
+				"|",
+				"` cache [Running, Healthy]",
+				"",
+			},
+			"\n",
+		),
+		out.String(),
+	)
+}
+
+func TestEventsAndHealth(t *testing.T) {
+	socketPath, stop := startSupervisor(t)
+	defer stop()
+
+	var out bytes.Buffer
+	err := run(context.TODO(), []string{"-socket", socketPath, "events", "-limit", "1"}, &out)
+	assert.NoError(t, err)
+	assert.True(
+		t,
+		strings.HasSuffix(out.String(), " ProcessStarted Supervisor root\n"),
+		"unexpected output: %s",
+		out.String(),
+	)
+
+	out.Reset()
+	err = run(context.TODO(), []string{"-socket", socketPath, "health"}, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "healthy\n", out.String())
+}
+
+func TestRestart(t *testing.T) {
+	restartedCh := make(chan string, 1)
+	socketPath, stop := startSupervisor(
+		t,
+		admin.WithRestartNode(func(runtimeName string) error {
+			if runtimeName != "root/net/listener" {
+				return errors.New("unknown node")
+			}
+			restartedCh <- runtimeName
+			return nil
+		}),
+	)
+	defer stop()
+
+	var out bytes.Buffer
+	err := run(context.TODO(), []string{"-socket", socketPath, "restart", "root/net/listener"}, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "restart: root/net/listener\n", out.String())
+	assert.Equal(t, "root/net/listener", <-restartedCh)
+
+	err = run(context.TODO(), []string{"-socket", socketPath, "restart", "root/other"}, &out)
+	assert.EqualError(t, err, "unknown node")
+
+	err = run(context.TODO(), []string{"-socket", socketPath, "restart"}, &out)
+	assert.Error(t, err)
+}
+
+func TestInvalidArguments(t *testing.T) {
+	var out bytes.Buffer
+	assert.Error(t, run(context.TODO(), []string{"tree"}, &out))
+	assert.Error(t, run(context.TODO(), []string{"-socket", "capctl.sock"}, &out))
+	assert.EqualError(
+		t,
+		run(context.TODO(), []string{"-socket", "capctl.sock", "unknown"}, &out),
+		`unknown command "unknown"`,
+	)
+}
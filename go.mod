@@ -2,7 +2,7 @@ module github.com/capatazlib/go-capataz
 
 require (
 	github.com/google/uuid v1.1.1 // indirect
-	github.com/leanovate/gopter v0.2.4 // indirect
+	github.com/leanovate/gopter v0.2.4
 	github.com/prometheus/client_golang v1.2.1
 	github.com/sirupsen/logrus v1.4.2
 	github.com/stretchr/testify v1.3.0
@@ -0,0 +1,28 @@
+package c
+
+import "time"
+
+// Clock is the source of time used by supervisors and their children (e.g.
+// for error tolerance windows and shutdown timeouts)
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After waits for the given duration to elapse and then sends the current
+	// time on the returned channel
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is a Clock that uses the functions of the time package
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SystemClock is the Clock used when none is specified, it reports the time of
+// the operating system
+var SystemClock Clock = systemClock{}
@@ -1,5 +1,7 @@
 package c
 
+import "time"
+
 ////////////////////////////////////////////////////////////////////////////////
 
 // GetName returns the specified name for a Child Spec
@@ -7,8 +9,64 @@ func (chSpec ChildSpec) GetName() string {
 	return chSpec.Name
 }
 
-// Terminate is a synchronous procedure that halts the execution of the child
-func (ch Child) Terminate() error {
+// Terminate is a synchronous procedure that halts the execution of the child,
+// the given reason is available to the child via GetTerminationReason
+func (ch Child) Terminate(reason TerminationReason) error {
+	ch.reason.set(reason)
 	ch.cancel()
 	return ch.wait(ch.spec.Shutdown)
 }
+
+// TerminateWithDeadline is a synchronous procedure that halts the execution of
+// the child; it waits for the child to stop as specified in its Shutdown
+// setting, but it never waits beyond the given deadline. When the deadline was
+// reached already (e.g. while the siblings of the child were terminating), the
+// child is waited for the given grace period at most.
+//
+// The deadline is available to the child via GetTerminationDeadline, this way
+// a sub-tree bounds the termination of its own children; sub-trees are waited
+// for the grace period after the deadline, so that they are able to report
+// the children that did not stop in time.
+func (ch Child) TerminateWithDeadline(
+	reason TerminationReason,
+	deadline time.Time,
+	grace time.Duration,
+) error {
+	ch.reason.set(reason)
+	ch.deadline.set(deadline)
+	ch.cancel()
+	timeLeft := deadline.Sub(ch.spec.GetClock().Now())
+	if timeLeft < 0 {
+		timeLeft = 0
+	}
+	if timeLeft == 0 || ch.spec.GetTag() == Supervisor {
+		timeLeft += grace
+	}
+	return ch.wait(ch.spec.Shutdown.boundBy(timeLeft))
+}
+
+// Drain signals the child that it should stop accepting new work, given its
+// termination is about to happen
+func (ch Child) Drain() {
+	ch.drain()
+}
+
+// Done returns a channel that is closed once the start function of the child
+// returns (e.g. the child finished after it got drained). The notification of
+// the child must still be read with Terminate.
+func (ch Child) Done() <-chan struct{} {
+	return ch.doneCh
+}
+
+// WaitLeaked blocks until the goroutine of a child that did not stop before
+// its shutdown timeout finishes, it returns the error the child finished with.
+//
+// This function must only be called after a Terminate call returned a
+// ShutdownTimeoutError.
+func (ch Child) WaitLeaked() error {
+	childNotification, ok := <-ch.terminateCh
+	if !ok {
+		return nil
+	}
+	return childNotification.Unwrap()
+}
@@ -0,0 +1,57 @@
+package c
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// terminationDeadlineKey is the key used to store the termination deadline of
+// a child in its context
+type terminationDeadlineKey struct{}
+
+// terminationDeadlineHolder is a concurrent-safe holder of the deadline a
+// supervisor gave to the termination of a child
+type terminationDeadlineHolder struct {
+	mux      sync.Mutex
+	deadline time.Time
+}
+
+// set registers the given deadline, only the first registered deadline is
+// kept.
+func (h *terminationDeadlineHolder) set(deadline time.Time) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if h.deadline.IsZero() {
+		h.deadline = deadline
+	}
+}
+
+// get returns the registered deadline, it is zero when there is none
+func (h *terminationDeadlineHolder) get() time.Time {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return h.deadline
+}
+
+// withTerminationDeadline returns a context that holds the termination
+// deadline of a child
+func withTerminationDeadline(
+	ctx context.Context,
+) (context.Context, *terminationDeadlineHolder) {
+	holder := &terminationDeadlineHolder{}
+	return context.WithValue(ctx, terminationDeadlineKey{}, holder), holder
+}
+
+// GetTerminationDeadline returns the deadline the supervisor gave to the
+// termination of the child running with the given context (check
+// TerminateWithDeadline). If the child has not been terminated with a
+// deadline or the context does not belong to a child, it returns false.
+func GetTerminationDeadline(ctx context.Context) (time.Time, bool) {
+	holder, ok := ctx.Value(terminationDeadlineKey{}).(*terminationDeadlineHolder)
+	if !ok {
+		return time.Time{}, false
+	}
+	deadline := holder.get()
+	return deadline, !deadline.IsZero()
+}
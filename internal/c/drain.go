@@ -0,0 +1,21 @@
+package c
+
+import "context"
+
+// drainSignalKey is the key used to store the drain signal of a child in its
+// context
+type drainSignalKey struct{}
+
+// withDrainSignal returns a context that holds the given drain signal
+func withDrainSignal(ctx context.Context, drainCh <-chan struct{}) context.Context {
+	return context.WithValue(ctx, drainSignalKey{}, drainCh)
+}
+
+// DrainSignal returns a channel that gets closed when the supervisor of the
+// child running with the given context starts a graceful termination. If the
+// context does not belong to a child, it returns a nil channel (e.g. it blocks
+// forever).
+func DrainSignal(ctx context.Context) <-chan struct{} {
+	drainCh, _ := ctx.Value(drainSignalKey{}).(<-chan struct{})
+	return drainCh
+}
@@ -0,0 +1,54 @@
+package c
+
+import (
+	"bytes"
+	"fmt"
+	"runtime/pprof"
+	"strings"
+)
+
+// goroutineDump returns the stacks of the goroutines that run with the pprof
+// labels of the child with the given runtime name (e.g. the goroutine of the
+// child and the goroutines it spawned), or with the labels of one of its
+// descendants when the child is a sub-tree. The result follows the format of
+// the goroutine profile with debug=1, filtered to the matching records.
+//
+// It returns an empty string when the child does not run with pprof labels.
+func goroutineDump(chSpec ChildSpec, chRuntimeName string) string {
+	if !chSpec.HasPprofLabels() {
+		return ""
+	}
+
+	var profile bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+		return ""
+	}
+
+	// records of the profile are separated by an empty line, and the labels of
+	// a record are reported in a line with the format:
+	//
+	//   # labels: {"capataz.runtime_name":"root/child0", ...}
+	//
+	label := fmt.Sprintf("%q:%q", pprofRuntimeNameLabel, chRuntimeName)
+	// the runtime names of descendants start with the one of the child, the
+	// closing quote is left out to match them
+	descendantLabel := strings.TrimSuffix(
+		fmt.Sprintf("%q:%q", pprofRuntimeNameLabel, chRuntimeName+"/"),
+		`"`,
+	)
+	records := strings.Split(profile.String(), "\n\n")
+
+	matches := make([]string, 0, 1)
+	for _, record := range records {
+		for _, line := range strings.Split(record, "\n") {
+			if !strings.HasPrefix(line, "# labels: ") {
+				continue
+			}
+			if strings.Contains(line, label) || strings.Contains(line, descendantLabel) {
+				matches = append(matches, strings.TrimSpace(record))
+				break
+			}
+		}
+	}
+	return strings.Join(matches, "\n\n")
+}
@@ -29,17 +29,21 @@ type ErrTolerance struct {
 	ErrWindow   time.Duration
 }
 
-func (et ErrTolerance) isWithinErrorWindow(createdAt time.Time) bool {
+func (et ErrTolerance) isWithinErrorWindow(clock Clock, createdAt time.Time) bool {
 	// when errWindow is 0, it means we never forget errors happened
-	return time.Since(createdAt) < et.ErrWindow || et.ErrWindow == 0
+	return clock.Now().Sub(createdAt) < et.ErrWindow || et.ErrWindow == 0
 }
 
 func (et ErrTolerance) didSurpassErrorCount(restartCount uint32) bool {
 	return et.MaxErrCount < restartCount
 }
 
-func (et ErrTolerance) check(restartCount uint32, createdAt time.Time) errToleranceResult {
-	if et.isWithinErrorWindow(createdAt) {
+func (et ErrTolerance) check(
+	clock Clock,
+	restartCount uint32,
+	createdAt time.Time,
+) errToleranceResult {
+	if et.isWithinErrorWindow(clock, createdAt) {
 		if et.didSurpassErrorCount(restartCount + 1) {
 			return errToleranceSurpassed
 		}
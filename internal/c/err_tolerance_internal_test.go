@@ -52,7 +52,7 @@ func TestErrTolerance(t *testing.T) {
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
 			et := ErrTolerance{MaxErrCount: tc.maxErrCount, ErrWindow: tc.errWindow}
-			result := et.check(tc.errCount, tc.createdAt)
+			result := et.check(SystemClock, tc.errCount, tc.createdAt)
 			require.True(t, tc.result == result, result.String())
 		})
 	}
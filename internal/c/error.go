@@ -34,3 +34,80 @@ func (err *ErrorToleranceReached) Error() string {
 func (err *ErrorToleranceReached) Unwrap() error {
 	return err.err
 }
+
+// ShutdownTimeoutError is an error that gets reported when a child does not
+// stop within the time specified in its Shutdown setting. Given there is no way
+// to kill a goroutine, the goroutine of the child may still be running in
+// memory (e.g. it leaked).
+type ShutdownTimeoutError struct {
+	childName string
+	timeout   time.Duration
+	stackDump string
+}
+
+// GetRuntimeName returns the runtime name of the child that did not stop in
+// time
+func (err *ShutdownTimeoutError) GetRuntimeName() string {
+	return err.childName
+}
+
+// GetTimeout returns the duration the supervisor waited for the child to stop
+func (err *ShutdownTimeoutError) GetTimeout() time.Duration {
+	return err.timeout
+}
+
+// GetStackDump returns the stacks of the goroutines of the child at the moment
+// the timeout was reached; this dump is useful to find where the child is
+// stuck. The dump contains the goroutines that run with the pprof labels of
+// the child (or of its descendants, when the child is a sub-tree), it is empty
+// when the child runs without pprof labels.
+func (err *ShutdownTimeoutError) GetStackDump() string {
+	return err.stackDump
+}
+
+// KVs returns a data bag map that may be used in structured logging
+func (err *ShutdownTimeoutError) KVs() map[string]interface{} {
+	kvs := make(map[string]interface{})
+	kvs["child.name"] = err.childName
+	kvs["child.shutdown.timeout"] = err.timeout
+	if err.stackDump != "" {
+		kvs["child.shutdown.stack_dump"] = err.stackDump
+	}
+	return kvs
+}
+
+func (err *ShutdownTimeoutError) Error() string {
+	return "child shutdown timeout"
+}
+
+// StartAbortedError is an error that gets reported when a child notifies its
+// start after the supervisor gave up on it; the child got terminated before
+// the error is reported.
+type StartAbortedError struct {
+	childName string
+	err       error
+}
+
+// GetRuntimeName returns the runtime name of the child that got terminated
+func (err *StartAbortedError) GetRuntimeName() string {
+	return err.childName
+}
+
+// Unwrap returns the error of the context that aborted the start
+func (err *StartAbortedError) Unwrap() error {
+	return err.err
+}
+
+// KVs returns a data bag map that may be used in structured logging
+func (err *StartAbortedError) KVs() map[string]interface{} {
+	kvs := make(map[string]interface{})
+	kvs["child.name"] = err.childName
+	if err.err != nil {
+		kvs["child.error"] = err.err.Error()
+	}
+	return kvs
+}
+
+func (err *StartAbortedError) Error() string {
+	return "child start aborted"
+}
@@ -81,6 +81,9 @@ func NewWithNotifyStart(
 
 		// All panics are going to be supervised by default
 		CapturePanic: true,
+
+		// All goroutines are going to be identified on profiles by default
+		PprofLabels: true,
 	}
 
 	if name == "" {
@@ -18,6 +18,38 @@ func WithCapturePanic(capture bool) Opt {
 	}
 }
 
+// WithPprofLabels specifies if the goroutine of this worker should run with
+// pprof labels that identify it on goroutine dumps and CPU profiles.
+func WithPprofLabels(enabled bool) Opt {
+	return func(spec *ChildSpec) {
+		spec.PprofLabels = enabled
+	}
+}
+
+// WithDependsOn specifies the names of the siblings this worker depends on.
+// Dependencies are added to the ones already specified.
+func WithDependsOn(names ...string) Opt {
+	return func(spec *ChildSpec) {
+		spec.DependsOn = append(spec.DependsOn[:len(spec.DependsOn):len(spec.DependsOn)], names...)
+	}
+}
+
+// WithClock specifies the Clock the worker uses to measure time (e.g. for its
+// error tolerance window and its shutdown timeout).
+func WithClock(clock Clock) Opt {
+	return func(spec *ChildSpec) {
+		spec.Clock = clock
+	}
+}
+
+// WithFaultReporting specifies if the worker runs with a context that allows
+// it to report the faults injected on it.
+func WithFaultReporting(enabled bool) Opt {
+	return func(spec *ChildSpec) {
+		spec.FaultReporting = enabled
+	}
+}
+
 // WithShutdown specifies how the shutdown of the worker is going to be handled.
 // Read `Indefinitely` and `Timeout` shutdown values documentation for details.
 func WithShutdown(s Shutdown) Opt {
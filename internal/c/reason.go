@@ -0,0 +1,90 @@
+package c
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// TerminationReason specifies why a supervisor terminated one of its children
+type TerminationReason uint32
+
+const (
+	// UnknownReason is used when a child has not been terminated by its
+	// supervisor (e.g. the child finished on its own)
+	UnknownReason TerminationReason = iota
+
+	// ShutdownReason is used when the child got terminated because the
+	// supervision tree is shutting down
+	ShutdownReason
+
+	// RestartReason is used when the child got terminated so that it can be
+	// started again
+	RestartReason
+
+	// CancelReason is used when the child got terminated via an explicit
+	// cancel request (e.g. a DynSupervisor cancel callback)
+	CancelReason
+
+	// ParentFailureReason is used when the child got terminated because its
+	// supervisor failed (e.g. a sibling surpassed its error tolerance, or a
+	// sibling failed to start)
+	ParentFailureReason
+)
+
+func (r TerminationReason) String() string {
+	switch r {
+	case UnknownReason:
+		return "Unknown"
+	case ShutdownReason:
+		return "Shutdown"
+	case RestartReason:
+		return "Restart"
+	case CancelReason:
+		return "Cancel"
+	case ParentFailureReason:
+		return "ParentFailure"
+	default:
+		return "<Unknown>"
+	}
+}
+
+// terminationReasonKey is the key used to store the termination reason of a
+// child in its context
+type terminationReasonKey struct{}
+
+// terminationReasonHolder is a concurrent-safe holder of a TerminationReason
+type terminationReasonHolder struct {
+	reason uint32
+}
+
+// set registers the given TerminationReason, only the first registered reason
+// is kept.
+func (h *terminationReasonHolder) set(reason TerminationReason) {
+	atomic.CompareAndSwapUint32(&h.reason, uint32(UnknownReason), uint32(reason))
+}
+
+// get returns the registered TerminationReason
+func (h *terminationReasonHolder) get() TerminationReason {
+	return TerminationReason(atomic.LoadUint32(&h.reason))
+}
+
+// withTerminationReason returns a context that holds the termination reason
+// of a child
+func withTerminationReason(
+	ctx context.Context,
+) (context.Context, *terminationReasonHolder) {
+	holder := &terminationReasonHolder{}
+	return context.WithValue(ctx, terminationReasonKey{}, holder), holder
+}
+
+// GetTerminationReason returns the reason why the child running with the
+// given context got terminated by its supervisor. If the child has not been
+// terminated by its supervisor or the context does not belong to a child, it
+// returns UnknownReason.
+func GetTerminationReason(ctx context.Context) TerminationReason {
+	holder, ok := ctx.Value(terminationReasonKey{}).(*terminationReasonHolder)
+	if !ok {
+		return UnknownReason
+	}
+	return holder.get()
+}
@@ -1,8 +1,10 @@
 package c
 
+import "context"
+
 func (ch Child) assertErrorTolerance() (uint32, *ErrorToleranceReached) {
 	errTolerance := ch.spec.ErrTolerance
-	switch errTolerance.check(ch.restartCount, ch.createdAt) {
+	switch errTolerance.check(ch.spec.GetClock(), ch.restartCount, ch.createdAt) {
 	case errToleranceSurpassed:
 		return 0, &ErrorToleranceReached{
 			failedChildName:        ch.GetRuntimeName(),
@@ -19,33 +21,65 @@ func (ch Child) assertErrorTolerance() (uint32, *ErrorToleranceReached) {
 	}
 }
 
-// Restart spawns a new Child and keeps track of the restart count.
+// failedRestart returns the record of a child that failed to start on a
+// restart; it keeps the restart count of the failed attempt, this way, the
+// error tolerance of the child is asserted on the next restart.
+func (ch Child) failedRestart(restartCount uint32) Child {
+	ch.restartCount = restartCount
+	ch.createdAt = ch.spec.GetClock().Now()
+	return ch
+}
+
+// Restart spawns a new Child and keeps track of the restart count. The start
+// of the new Child is cancelled once the given context is done (check
+// DoStartContext).
+//
+// When the child fails to start, the returned Child must only be used to
+// restart the child again; the error tolerance of the child is asserted on
+// that restart. When the start got cancelled and the child did not stop in
+// time, the returned Child only allows to wait for its leaked goroutine (check
+// WaitLeaked).
 func (ch Child) Restart(
+	startCtx context.Context,
 	supParentName string,
 	supNotifyCh chan<- ChildNotification,
 	wasComplete bool,
 ) (Child, error) {
 	chSpec := ch.GetSpec()
 
-	var newCh Child
-	var startErr error
-
-	if wasComplete {
-		newCh, startErr = chSpec.DoStart(supParentName, supNotifyCh)
-		if startErr != nil {
-			return Child{}, startErr
-		}
-	} else {
-		restartCount, toleranceErr := ch.assertErrorTolerance()
+	var restartCount uint32
+	if !wasComplete {
+		var toleranceErr *ErrorToleranceReached
+		restartCount, toleranceErr = ch.assertErrorTolerance()
 		if toleranceErr != nil {
 			return Child{}, toleranceErr
 		}
-		newCh, startErr = chSpec.DoStart(supParentName, supNotifyCh)
-		if startErr != nil {
-			return Child{}, startErr
+	}
+
+	newCh, startErr := chSpec.doStart(startCtx, supParentName, supNotifyCh, restartCount)
+	if startErr != nil {
+		if startCtx.Err() != nil {
+			return newCh, startErr
 		}
-		newCh.restartCount = restartCount
+		return ch.failedRestart(restartCount), startErr
 	}
 
 	return newCh, nil
 }
+
+// StartAgain spawns a new Child that keeps the restart count of this one; it
+// does not assert the error tolerance of the child, this way, an explicit
+// restart does not count against it, nor forgets the errors that happened
+// before. The start of the new Child is cancelled once the given context is
+// done (check DoStartContext).
+//
+// When the child fails to start, the returned Child must be ignored, unless
+// the start got cancelled and the child did not stop in time, in which case it
+// only allows to wait for its leaked goroutine (check WaitLeaked).
+func (ch Child) StartAgain(
+	startCtx context.Context,
+	supParentName string,
+	supNotifyCh chan<- ChildNotification,
+) (Child, error) {
+	return ch.GetSpec().doStart(startCtx, supParentName, supNotifyCh, ch.restartCount)
+}
@@ -2,6 +2,7 @@ package c
 
 import (
 	"context"
+	"fmt"
 	"time"
 )
 
@@ -100,6 +101,30 @@ func Timeout(d time.Duration) Shutdown {
 	}
 }
 
+// String returns a string representation of the current Shutdown value
+func (s Shutdown) String() string {
+	switch s.tag {
+	case indefinitelyT:
+		return "Indefinitely"
+	case timeoutT:
+		return fmt.Sprintf("Timeout(%v)", s.duration)
+	default:
+		return "<Unknown>"
+	}
+}
+
+// boundBy returns a Shutdown value that never waits longer than the given
+// duration
+func (s Shutdown) boundBy(d time.Duration) Shutdown {
+	if d < 0 {
+		d = 0
+	}
+	if s.tag == indefinitelyT || s.duration > d {
+		return Timeout(d)
+	}
+	return s
+}
+
 // startError is the error reported back to a Supervisor when the start of a
 // Child fails
 type startError = error
@@ -134,6 +159,17 @@ type ChildSpec struct {
 	Restart      Restart
 	ErrTolerance ErrTolerance
 	CapturePanic bool
+	PprofLabels  bool
+	DependsOn    []string
+	Clock        Clock
+	// FaultReporting is set when the child runs with a context that allows it
+	// to report the faults injected on it
+	FaultReporting bool
+
+	// Subtree contains the specification of the supervision tree that a child
+	// with a Supervisor tag runs; it is opaque to this package, and it is only
+	// used to introspect the supervision tree.
+	Subtree interface{}
 
 	Start func(context.Context, NotifyStartFn) error
 }
@@ -157,3 +193,28 @@ func (chSpec ChildSpec) GetRestart() Restart {
 func (chSpec ChildSpec) DoesCapturePanic() bool {
 	return chSpec.CapturePanic
 }
+
+// GetDependencies returns the names of the siblings this child depends on
+func (chSpec ChildSpec) GetDependencies() []string {
+	return chSpec.DependsOn
+}
+
+// GetClock returns the Clock this child uses to measure time, SystemClock is
+// returned when none was specified
+func (chSpec ChildSpec) GetClock() Clock {
+	if chSpec.Clock == nil {
+		return SystemClock
+	}
+	return chSpec.Clock
+}
+
+// ReportsFaults indicates if this child runs with a context that allows it to
+// report injected faults
+func (chSpec ChildSpec) ReportsFaults() bool {
+	return chSpec.FaultReporting
+}
+
+// HasPprofLabels indicates if this child runs with pprof labels
+func (chSpec ChildSpec) HasPprofLabels() bool {
+	return chSpec.PprofLabels
+}
@@ -4,13 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime/pprof"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // waitTimeout is the internal function used by Child to wait for the execution
 // of it's thread to stop.
 func waitTimeout(
+	chSpec ChildSpec,
+	chRuntimeName string,
 	terminateCh <-chan ChildNotification,
 ) func(Shutdown) error {
 	return func(shutdown Shutdown) error {
@@ -32,8 +38,13 @@ func waitTimeout(
 				}
 				// A child may have terminated with an error
 				return childNotification.Unwrap()
-			case <-time.After(shutdown.duration):
-				return errors.New("child shutdown timeout")
+			case <-chSpec.GetClock().After(shutdown.duration):
+				return &ShutdownTimeoutError{
+					childName: chRuntimeName,
+					timeout:   shutdown.duration,
+					// we capture where the child is stuck before giving up on it
+					stackDump: goroutineDump(chSpec, chRuntimeName),
+				}
 			}
 		default:
 			// This should never happen if we use the already defined Shutdown types
@@ -50,6 +61,7 @@ func sendNotificationToSup(
 	chRuntimeName string,
 	supNotifyCh chan<- ChildNotification,
 	terminateCh chan<- ChildNotification,
+	terminateRequested func() bool,
 ) {
 
 	chNotification := ChildNotification{
@@ -72,6 +84,16 @@ func sendNotificationToSup(
 	// function, which calls the `child.Terminate` method for each of the supervised
 	// internally, this function reads the `terminateCh`.
 	//
+	// Once the supervisor requested the termination of this child, the
+	// notification must only go to the `terminateCh`; if the child did not stop
+	// in time, the supervisor may be back in its supervision loop and it must
+	// not handle the notification of a child that is not supervised anymore.
+	//
+	if terminateRequested() {
+		terminateCh <- chNotification
+		return
+	}
+
 	select {
 	// (1)
 	case supNotifyCh <- chNotification:
@@ -80,6 +102,35 @@ func sendNotificationToSup(
 	}
 }
 
+// Labels used to identify the goroutine of a child on pprof profiles
+const (
+	pprofRuntimeNameLabel  = "capataz.runtime_name"
+	pprofNodeTagLabel      = "capataz.node_tag"
+	pprofRestartCountLabel = "capataz.restart_count"
+)
+
+// runWithPprofLabels executes the given function with pprof labels that
+// identify the child goroutine, if the child spec has them enabled. Goroutines
+// spawned by the given function inherit these labels.
+func runWithPprofLabels(
+	ctx context.Context,
+	chSpec ChildSpec,
+	chRuntimeName string,
+	restartCount uint32,
+	runFn func(context.Context),
+) {
+	if !chSpec.HasPprofLabels() {
+		runFn(ctx)
+		return
+	}
+	labels := pprof.Labels(
+		pprofRuntimeNameLabel, chRuntimeName,
+		pprofNodeTagLabel, chSpec.GetTag().String(),
+		pprofRestartCountLabel, strconv.FormatUint(uint64(restartCount), 10),
+	)
+	pprof.Do(ctx, labels, runFn)
+}
+
 // DoStart spawns a new goroutine that will execute the `Start` attribute of the
 // ChildSpec, this function will block until the spawned goroutine notifies it
 // has been initialized.
@@ -100,12 +151,69 @@ func (chSpec ChildSpec) DoStart(
 	supName string,
 	supNotifyCh chan<- ChildNotification,
 ) (Child, error) {
+	return chSpec.doStart(context.Background(), supName, supNotifyCh, 0)
+}
+
+// DoStartContext works like DoStart, but it stops waiting for the child to
+// start once the given context is done. When this happens, the child gets
+// terminated (with the termination reason of the given context, or
+// ShutdownReason if there is none), and an error is returned; this error is
+// either the start error the child reported, or the error of the given
+// context.
+//
+// When the child notifies its start while it is being terminated, a
+// StartAbortedError is returned once the child stops.
+//
+// The terminated child is waited as specified in its Shutdown setting; if it
+// does not stop in time, a ShutdownTimeoutError is returned along with a Child
+// record that only allows to wait for its leaked goroutine (check WaitLeaked).
+func (chSpec ChildSpec) DoStartContext(
+	startCtx context.Context,
+	supName string,
+	supNotifyCh chan<- ChildNotification,
+) (Child, error) {
+	return chSpec.doStart(startCtx, supName, supNotifyCh, 0)
+}
+
+// doStart is the implementation of DoStart, it receives the number of times the
+// child has been restarted, which is registered on the returned Child.
+func (chSpec ChildSpec) doStart(
+	startCtx context.Context,
+	supName string,
+	supNotifyCh chan<- ChildNotification,
+	restartCount uint32,
+) (Child, error) {
 
 	chRuntimeName := strings.Join([]string{supName, chSpec.GetName()}, "/")
 	childCtx, cancelFn := context.WithCancel(context.Background())
 
-	startCh := make(chan startError)
+	// drainCh is closed when the supervisor starts a graceful termination
+	drainCh := make(chan struct{})
+	childCtx = withDrainSignal(childCtx, drainCh)
+
+	// reason is set by the supervisor before cancelling the childCtx
+	childCtx, reason := withTerminationReason(childCtx)
+
+	// deadline is set by the supervisor when it terminates the child with a
+	// deadline
+	childCtx, deadline := withTerminationDeadline(childCtx)
+
+	// terminateRequestedFlag is set when the supervisor cancels the childCtx
+	var terminateRequestedFlag int32
+	terminateRequested := func() bool {
+		return atomic.LoadInt32(&terminateRequestedFlag) == 1
+	}
+	var drainOnce sync.Once
+	drainFn := func() {
+		drainOnce.Do(func() { close(drainCh) })
+	}
+
+	// startCh has room for the start error, this way a child that reports it
+	// after the supervisor gave up on its start does not block forever
+	startCh := make(chan startError, 1)
 	terminateCh := make(chan ChildNotification)
+	// doneCh is closed once the client logic returns
+	doneCh := make(chan struct{})
 
 	// Child Goroutine is bootstraped
 	go func() {
@@ -134,6 +242,7 @@ func (chSpec ChildSpec) DoStart(
 					chRuntimeName,
 					supNotifyCh,
 					terminateCh,
+					terminateRequested,
 				)
 			}
 		}()
@@ -141,12 +250,20 @@ func (chSpec ChildSpec) DoStart(
 		// client logic starts here, despite the call here being a "start", we will
 		// block and wait here until an error (or lack of) is reported from the
 		// client code
-		err := chSpec.Start(childCtx, func(err error) {
-			// we tell the spawner this child thread has started running
-			if err != nil {
-				startCh <- err
-			}
-			close(startCh)
+		var err error
+		runWithPprofLabels(childCtx, chSpec, chRuntimeName, restartCount, func(ctx context.Context) {
+			defer close(doneCh)
+			err = chSpec.Start(ctx, func(err error) {
+				// we tell the spawner this child thread has started running
+				if err != nil {
+					// a child that fails to start is never supervised, the
+					// notification of its termination must not reach the supervisor
+					// (check the discard of the terminateCh below)
+					atomic.StoreInt32(&terminateRequestedFlag, 1)
+					startCh <- err
+				}
+				close(startCh)
+			})
 		})
 
 		sendNotificationToSup(
@@ -155,20 +272,135 @@ func (chSpec ChildSpec) DoStart(
 			chRuntimeName,
 			supNotifyCh,
 			terminateCh,
+			terminateRequested,
 		)
 	}()
 
 	// Wait until child thread notifies it has started or failed with an error
-	err := <-startCh
+	var err error
+	select {
+	case err = <-startCh:
+	case <-startCtx.Done():
+		// the supervisor does not want this child anymore, we terminate it
+		reasonVal := GetTerminationReason(startCtx)
+		if reasonVal == UnknownReason {
+			reasonVal = ShutdownReason
+		}
+		reason.set(reasonVal)
+		atomic.StoreInt32(&terminateRequestedFlag, 1)
+		cancelFn()
+		abortErr := abortStart(startCtx, chSpec, chRuntimeName, startCh, terminateCh)
+		var timeoutErr *ShutdownTimeoutError
+		if errors.As(abortErr, &timeoutErr) {
+			// the child did not stop in time, the returned record allows the
+			// supervisor to keep track of its leaked goroutine (check WaitLeaked)
+			return Child{
+				runtimeName:  chRuntimeName,
+				restartCount: restartCount,
+				spec:         chSpec,
+				doneCh:       doneCh,
+				terminateCh:  terminateCh,
+			}, abortErr
+		}
+		return Child{}, abortErr
+	}
+
 	if err != nil {
+		// we discard the notification the failed child sends once its goroutine
+		// finishes, this way the goroutine does not leak
+		go discardNotifications(terminateCh)
 		return Child{}, err
 	}
 
 	return Child{
-		runtimeName: chRuntimeName,
-		createdAt:   time.Now(),
-		spec:        chSpec,
-		cancel:      cancelFn,
-		wait:        waitTimeout(terminateCh),
+		runtimeName:  chRuntimeName,
+		restartCount: restartCount,
+		createdAt:    chSpec.GetClock().Now(),
+		spec:         chSpec,
+		cancel: func() {
+			atomic.StoreInt32(&terminateRequestedFlag, 1)
+			cancelFn()
+		},
+		drain:       drainFn,
+		reason:      reason,
+		deadline:    deadline,
+		wait:        waitTimeout(chSpec, chRuntimeName, terminateCh),
+		doneCh:      doneCh,
+		terminateCh: terminateCh,
 	}, nil
 }
+
+// abortStart waits for a child that got terminated before it notified its
+// start, as specified in its Shutdown setting. It returns the start error
+// reported by the child (if any), a StartAbortedError when the child notified
+// its start while it was being terminated, a ShutdownTimeoutError when the
+// child did not stop in time, or the error of the given context.
+//
+// When a ShutdownTimeoutError is returned, the notification of the child is
+// not discarded; it must be read with WaitLeaked.
+func abortStart(
+	startCtx context.Context,
+	chSpec ChildSpec,
+	chRuntimeName string,
+	startCh <-chan startError,
+	terminateCh <-chan ChildNotification,
+) error {
+	var timeoutCh <-chan time.Time
+	if chSpec.Shutdown.tag == timeoutT {
+		timeoutCh = chSpec.GetClock().After(chSpec.Shutdown.duration)
+	}
+
+	started := false
+	select {
+	case err, ok := <-startCh:
+		if ok && err != nil {
+			go discardNotifications(terminateCh)
+			return err
+		}
+		// the child notified it started after it got terminated, we wait for it
+		// during the rest of its Shutdown setting
+		started = true
+		select {
+		case <-terminateCh:
+		case <-timeoutCh:
+			return newAbortStartTimeoutError(chSpec, chRuntimeName)
+		}
+	case <-terminateCh:
+		// the startCh is closed before the child finishes, the child may have
+		// notified its start right before it finished
+		select {
+		case err, ok := <-startCh:
+			if ok && err != nil {
+				go discardNotifications(terminateCh)
+				return err
+			}
+			started = !ok
+		default:
+		}
+	case <-timeoutCh:
+		return newAbortStartTimeoutError(chSpec, chRuntimeName)
+	}
+	go discardNotifications(terminateCh)
+	if started {
+		return &StartAbortedError{childName: chRuntimeName, err: startCtx.Err()}
+	}
+	return startCtx.Err()
+}
+
+// newAbortStartTimeoutError returns the error reported when a child that got
+// terminated while it was starting does not stop within its Shutdown setting
+func newAbortStartTimeoutError(chSpec ChildSpec, chRuntimeName string) error {
+	return &ShutdownTimeoutError{
+		childName: chRuntimeName,
+		timeout:   chSpec.Shutdown.duration,
+		// we capture where the child is stuck before giving up on it
+		stackDump: goroutineDump(chSpec, chRuntimeName),
+	}
+}
+
+// discardNotifications reads the notifications of a child that is not
+// supervised (e.g. it failed to start) until its goroutine finishes
+func discardNotifications(terminateCh <-chan ChildNotification) {
+	for range terminateCh {
+	}
+}
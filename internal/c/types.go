@@ -11,7 +11,12 @@ type Child struct {
 	restartCount uint32
 	createdAt    time.Time
 	cancel       func()
+	drain        func()
+	reason       *terminationReasonHolder
+	deadline     *terminationDeadlineHolder
 	wait         func(Shutdown) error
+	doneCh       <-chan struct{}
+	terminateCh  <-chan ChildNotification
 }
 
 // GetRuntimeName returns the name of this child (once started). It will have a
@@ -3,6 +3,7 @@ package stest
 import (
 	"context"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -11,14 +12,14 @@ import (
 
 // WaitDoneWorker creates a `cap.Node` that runs a goroutine that blocks until
 // the `context.Done` channel indicates a supervisor termination
-func WaitDoneWorker(name string) cap.Node {
+func WaitDoneWorker(name string, opts ...cap.WorkerOpt) cap.Node {
 	cspec := cap.NewWorker(name, func(ctx context.Context) error {
 		// In real-world code, here we would have some business logic. For this
 		// particular scenario, we want to block until we get a stop notification
 		// from our parent supervisor and return `nil`
 		<-ctx.Done()
 		return nil
-	})
+	}, opts...)
 	return cspec
 }
 
@@ -172,6 +173,26 @@ func FailTerminationWorker(
 		// from our parent supervisor and return an error
 		<-ctx.Done()
 		return err
-	})
+	}, opts...)
 	return cspec
 }
+
+// StuckWorker creates a cap.Node that runs a goroutine that ignores the
+// context.Done channel, it only stops once the returned unblock function gets
+// called.
+func StuckWorker(name string, opts ...cap.WorkerOpt) (cap.Node, func()) {
+	var once sync.Once
+	unblockCh := make(chan struct{})
+	unblock := func() {
+		once.Do(func() { close(unblockCh) })
+	}
+	cspec := cap.NewWorker(
+		name,
+		func(ctx context.Context) error {
+			<-unblockCh
+			return nil
+		},
+		opts...,
+	)
+	return cspec, unblock
+}